@@ -0,0 +1,122 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"sync"
+	"time"
+)
+
+// AccessLogEntry describes a single handled request.
+type AccessLogEntry struct {
+	// Time is when the request was received.
+	Time       time.Time
+	RemoteAddr string
+	URL        string
+	Status     StatusCode
+	Meta       string
+	// Bytes is the number of body bytes written to the client.
+	Bytes    int64
+	Duration time.Duration
+	// Country is set when Server.GeoLocator is configured.
+	Country string
+	// Err is the first error writing the response or recorded by the
+	// handler with RecordError, typically *PartialWriteError.
+	Err error
+}
+
+// AccessLogger receives an entry for every request handled by a Server.
+type AccessLogger interface {
+	LogAccess(AccessLogEntry)
+}
+
+// AccessLogFunc is an adapter to allow the use of ordinary functions as
+// access loggers.
+type AccessLogFunc func(AccessLogEntry)
+
+// LogAccess calls f(e).
+func (f AccessLogFunc) LogAccess(e AccessLogEntry) {
+	f(e)
+}
+
+// NewAccessLog returns an AccessLogger writing one line per request to l.
+// If l is nil, the standard logger is used.
+func NewAccessLog(l *log.Logger) AccessLogger {
+	if l == nil {
+		l = log.Default()
+	}
+	return AccessLogFunc(func(e AccessLogEntry) {
+		l.Printf("%s %q %d %q %d %v", e.RemoteAddr, e.URL, e.Status, e.Meta, e.Bytes, e.Duration)
+	})
+}
+
+// NewCombinedLog returns an AccessLogger writing one line per request to
+// w in the style of the Combined Log Format, with the request URL in
+// place of the request line and the response meta in place of the
+// referer:
+//
+//	192.0.2.1 - - [02/Jan/2006:15:04:05 -0700] "gemini://example.org/" 20 1024 "text/gemini" "-"
+//
+// Writes are serialized, so w may be shared with other writers only if
+// it is safe for concurrent use.
+func NewCombinedLog(w io.Writer) AccessLogger {
+	var mu sync.Mutex
+	return AccessLogFunc(func(e AccessLogEntry) {
+		host := e.RemoteAddr
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if host == "" {
+			host = "-"
+		}
+		line := fmt.Sprintf("%s - - [%s] %q %d %d %q \"-\"\n",
+			host, e.Time.Format("02/Jan/2006:15:04:05 -0700"), e.URL, e.Status, e.Bytes, e.Meta)
+		mu.Lock()
+		defer mu.Unlock()
+		io.WriteString(w, line)
+	})
+}
+
+type jsonLogEntry struct {
+	Time       time.Time  `json:"time"`
+	RemoteAddr string     `json:"remote_addr"`
+	URL        string     `json:"url"`
+	Status     StatusCode `json:"status"`
+	Meta       string     `json:"meta"`
+	Bytes      int64      `json:"bytes"`
+	DurationMS float64    `json:"duration_ms"`
+	Country    string     `json:"country,omitempty"`
+	Err        string     `json:"error,omitempty"`
+}
+
+// NewJSONLog returns an AccessLogger writing one JSON object per request
+// to w (JSON Lines) with the fields time, remote_addr, url, status, meta,
+// bytes, duration_ms and, when set, country and error.
+func NewJSONLog(w io.Writer) AccessLogger {
+	var mu sync.Mutex
+	return AccessLogFunc(func(e AccessLogEntry) {
+		je := jsonLogEntry{
+			Time:       e.Time,
+			RemoteAddr: e.RemoteAddr,
+			URL:        e.URL,
+			Status:     e.Status,
+			Meta:       e.Meta,
+			Bytes:      e.Bytes,
+			DurationMS: float64(e.Duration) / float64(time.Millisecond),
+			Country:    e.Country,
+		}
+		if e.Err != nil {
+			je.Err = e.Err.Error()
+		}
+		b, err := json.Marshal(je)
+		if err != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(append(b, '\n'))
+	})
+}
@@ -0,0 +1,124 @@
+package gemini
+
+import (
+	"archive/zip"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ArchiveHandler serves a directory tree like FileServer and additionally
+// lets clients browse into zip based archives, such as .zip and .epub
+// files, without unpacking them. Create it with ArchiveServer.
+//
+// A request for "/books/novel.epub" serves the archive itself, while
+// "/books/novel.epub/" lists its members as text/gemini and
+// "/books/novel.epub/OEBPS/ch1.xhtml" streams a single member.
+type ArchiveHandler struct {
+	// Files serves paths outside of archives.
+	Files *FileHandler
+
+	// Extensions lists the file name extensions treated as archives.
+	// Defaults to ".zip" and ".epub".
+	Extensions []string
+
+	root string
+}
+
+// ArchiveServer returns an ArchiveHandler for the directory tree at root.
+func ArchiveServer(root string) *ArchiveHandler {
+	return &ArchiveHandler{Files: FileServer(root), root: root}
+}
+
+// ServeGemini serves the file or archive member named by the request path.
+func (h *ArchiveHandler) ServeGemini(w ResponseWriter, r *Request) {
+	if hasDotDot(r.URL.Path) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	p := path.Clean("/" + r.URL.Path)
+	archive, member, ok := h.split(p)
+	if !ok || (member == "" && !strings.HasSuffix(r.URL.Path, "/")) {
+		h.Files.ServeGemini(w, r)
+		return
+	}
+	if !h.Files.confined(archive) {
+		NotFound(w, r)
+		return
+	}
+	zr, err := zip.OpenReader(filepath.Join(h.root, filepath.FromSlash(archive)))
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	defer zr.Close()
+
+	name := strings.TrimPrefix(member, "/")
+	if name == "" {
+		name = "."
+	}
+	if fi, err := fs.Stat(zr, name); err == nil && fi.IsDir() && !strings.HasSuffix(r.URL.Path, "/") {
+		redirectToDir(w, r)
+		return
+	}
+	inner := FileServerFS(zr)
+	inner.Listing = true
+	inner.MIMETypes = h.Files.MIMETypes
+	if strings.HasSuffix(r.URL.Path, "/") {
+		member += "/"
+	}
+	r2 := new(Request)
+	*r2 = *r
+	r2.URL = new(url.URL)
+	*r2.URL = *r.URL
+	r2.URL.Path = member
+	r2.URL.RawPath = ""
+	inner.ServeGemini(w, r2)
+}
+
+// split finds the first archive file along the cleaned path p and returns
+// its path and the remainder. Archives outside the root once symbolic
+// links are followed are returned without being looked at, if
+// Files.ConfineSymlinks is set.
+func (h *ArchiveHandler) split(p string) (archive, member string, ok bool) {
+	segs := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i, seg := range segs {
+		if !h.isArchive(seg) {
+			continue
+		}
+		archive = "/" + strings.Join(segs[:i+1], "/")
+		if i+1 < len(segs) {
+			member = "/" + strings.Join(segs[i+1:], "/")
+		}
+		if !h.Files.confined(archive) {
+			// Leave it to ServeGemini to refuse.
+			return archive, member, true
+		}
+		fi, err := os.Stat(filepath.Join(h.root, filepath.FromSlash(archive)))
+		if err != nil {
+			return "", "", false
+		}
+		if fi.Mode().IsRegular() {
+			return archive, member, true
+		}
+		member = ""
+	}
+	return "", "", false
+}
+
+func (h *ArchiveHandler) isArchive(name string) bool {
+	exts := h.Extensions
+	if exts == nil {
+		exts = []string{".zip", ".epub"}
+	}
+	ext := path.Ext(name)
+	for _, e := range exts {
+		if strings.EqualFold(e, ext) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package gemini_test
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+// writeZip creates a zip archive with the given members.
+func writeZip(t *testing.T, name string, members map[string]string) {
+	t.Helper()
+	f, err := os.Create(name)
+	require.NoError(t, err)
+	zw := zip.NewWriter(f)
+	for name, content := range members {
+		fw, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = fw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	require.NoError(t, f.Close())
+}
+
+func TestArchiveServer(t *testing.T) {
+	root := writeTree(t, map[string]string{"index.gmi": "# Library"})
+	writeZip(t, filepath.Join(root, "book.epub"), map[string]string{
+		"mimetype":        "application/epub+zip",
+		"OEBPS/ch1.gmi":   "# Chapter 1",
+		"OEBPS/style.css": "p {}",
+		"META-INF/a.xml":  "<a/>",
+	})
+	h := gemini.ArchiveServer(root)
+
+	w := serve(t, h, "gemini://localhost/")
+	require.Equal(t, "# Library", w.body.String())
+
+	w = serve(t, h, "gemini://localhost/book.epub")
+	require.Equal(t, "application/epub+zip", w.meta)
+	require.Equal(t, "PK", w.body.String()[:2])
+
+	w = serve(t, h, "gemini://localhost/book.epub/")
+	require.Equal(t, "text/gemini", w.meta)
+	require.Contains(t, w.body.String(), "=> OEBPS/ OEBPS/\n")
+	require.Contains(t, w.body.String(), "=> mimetype mimetype (20 B)\n")
+
+	w = serve(t, h, "gemini://localhost/book.epub/OEBPS")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "./OEBPS/", w.meta)
+
+	w = serve(t, h, "gemini://localhost/book.epub/OEBPS/ch1.gmi")
+	require.Equal(t, "# Chapter 1", w.body.String())
+
+	w = serve(t, h, "gemini://localhost/book.epub/missing")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+}
+
+func TestArchiveServerConfineSymlinks(t *testing.T) {
+	root := writeTree(t, map[string]string{"capsule/index.gmi": "# Home"})
+	writeZip(t, filepath.Join(root, "secret.zip"), map[string]string{"key.gmi": "secret"})
+	capsule := filepath.Join(root, "capsule")
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret.zip"), filepath.Join(capsule, "leak.zip")))
+	h := gemini.ArchiveServer(capsule)
+
+	w := serve(t, h, "gemini://localhost/leak.zip/key.gmi")
+	require.Equal(t, "secret", w.body.String())
+
+	h.Files.ConfineSymlinks = true
+	for _, p := range []string{"/leak.zip", "/leak.zip/", "/leak.zip/key.gmi"} {
+		w = serve(t, h, "gemini://localhost"+p)
+		require.Equal(t, gemini.StatusNotFound, w.status, p)
+	}
+}
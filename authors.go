@@ -0,0 +1,153 @@
+package gemini
+
+import (
+	"io/fs"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// Author is the profile of a content author.
+type Author struct {
+	// Name is the display name. Defaults to the author's ID.
+	Name string
+
+	// Bio is shown on the author page.
+	Bio string
+
+	// Fingerprints lists the client certificate fingerprints (see
+	// Fingerprint) identifying the author.
+	Fingerprints []string
+}
+
+// AuthorIndex serves author pages built from the "author" or "authors"
+// front matter keys of the .gmi, .gemini and .md files of a content tree.
+// Front matter names authors by their ID in the registry passed to
+// NewAuthorIndex; IDs missing from it get a page without a profile.
+// Mount it under the desired prefix:
+//
+//	authors := gemini.NewAuthorIndex(os.DirFS("/var/gemini"), map[string]gemini.Author{
+//		"alice": {Name: "Alice", Fingerprints: []string{"5d4f..."}},
+//	})
+//	mux.Mount("/authors/", authors)
+//
+// The root lists all authors. Each author page shows the profile and
+// lists the author's documents newest first as date prefixed link lines,
+// so clients can subscribe to it as a Gemini feed. Draft documents and
+// documents dated in the future are left out, like in TagIndex.
+type AuthorIndex struct {
+	// ContentPrefix is prepended to document paths in links.
+	// Defaults to "/".
+	ContentPrefix string
+
+	// Now returns the current time for scheduled publishing. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	// Preview lists the fingerprints (see Fingerprint) of client
+	// certificates that also see drafts and future-dated documents.
+	Preview []string
+
+	authors map[string]Author
+	content contentIndex
+}
+
+// NewAuthorIndex returns an AuthorIndex for the content tree fsys with
+// the author registry authors, keyed by author ID.
+func NewAuthorIndex(fsys fs.FS, authors map[string]Author) *AuthorIndex {
+	return &AuthorIndex{authors: authors, content: contentIndex{fsys: fsys}}
+}
+
+// AuthorOf returns the ID of the author identified by the client
+// certificate r was sent with, so handlers accepting content can
+// attribute it.
+func (a *AuthorIndex) AuthorOf(r *Request) (string, bool) {
+	for id, author := range a.authors {
+		if hasFingerprint(r, author.Fingerprints) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// ServeGemini serves the author list or the page of the author named by
+// the request path.
+func (a *AuthorIndex) ServeGemini(w ResponseWriter, r *Request) {
+	files, err := a.content.refresh(a.Now, hasFingerprint(r, a.Preview))
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	id := strings.Trim(r.URL.Path, "/")
+	byAuthor := map[string][]string{}
+	for name, f := range files {
+		for _, au := range authorsOf(f.meta) {
+			byAuthor[au] = append(byAuthor[au], name)
+		}
+	}
+
+	var b strings.Builder
+	gw := gemtext.NewWriter(&b)
+	if id == "" {
+		ids := make([]string, 0, len(a.authors))
+		for au := range a.authors {
+			ids = append(ids, au)
+		}
+		for au := range byAuthor {
+			if _, ok := a.authors[au]; !ok {
+				ids = append(ids, au)
+			}
+		}
+		sort.Strings(ids)
+		gw.Heading(1, Message(r, "Authors"))
+		gw.Text("")
+		for _, au := range ids {
+			gw.Link((&url.URL{Path: au}).EscapedPath(), a.name(au)+" ("+strconv.Itoa(len(byAuthor[au]))+")")
+		}
+	} else {
+		author, ok := a.authors[id]
+		names := byAuthor[id]
+		if !ok && len(names) == 0 {
+			NotFound(w, r)
+			return
+		}
+		gw.Heading(1, a.name(id))
+		gw.Text("")
+		if author.Bio != "" {
+			gw.Text(author.Bio)
+			gw.Text("")
+		}
+		writeFeed(gw, a.ContentPrefix, files, names)
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+func (a *AuthorIndex) name(id string) string {
+	if name := a.authors[id].Name; name != "" {
+		return name
+	}
+	return id
+}
+
+// authorsOf returns the author IDs named by meta.
+func authorsOf(meta gemtext.Metadata) []string {
+	var ids []string
+	for _, key := range []string{"author", "authors"} {
+		switch v := meta.Params[key].(type) {
+		case string:
+			ids = append(ids, v)
+		case []interface{}:
+			for _, id := range v {
+				if s, ok := id.(string); ok {
+					ids = append(ids, s)
+				}
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,53 @@
+package gemini_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthorIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"a.gmi":     {Data: []byte("---\ntitle: A\ndate: 2024-01-01\nauthor: alice\n---\n")},
+		"b.md":      {Data: []byte("+++\ntitle = \"B\"\ndate = 2024-02-01\nauthors = [\"alice\", \"bob\"]\n+++\n")},
+		"draft.gmi": {Data: []byte("---\nauthor: alice\ndraft: true\n---\n")},
+	}
+	idx := gemini.NewAuthorIndex(fsys, map[string]gemini.Author{
+		"alice": {Name: "Alice", Bio: "Writes things.", Fingerprints: []string{testcert.Client().Fingerprint()}},
+		"carol": {},
+	})
+
+	w := serve(t, idx, "gemini://localhost/")
+	require.Equal(t, "# Authors\n\n=> alice Alice (2)\n=> bob bob (1)\n=> carol carol (0)\n", w.body.String())
+
+	w = serve(t, idx, "gemini://localhost/alice")
+	require.Equal(t, "# Alice\n\nWrites things.\n\n"+
+		"=> /b.md 2024-02-01 B\n"+
+		"=> /a.gmi 2024-01-01 A\n", w.body.String())
+	require.Equal(t, "# bob\n\n=> /b.md 2024-02-01 B\n", serve(t, idx, "gemini://localhost/bob").body.String())
+	require.Equal(t, gemini.StatusNotFound, serve(t, idx, "gemini://localhost/dave").status)
+}
+
+func TestAuthorIndexAuthorOf(t *testing.T) {
+	idx := gemini.NewAuthorIndex(fstest.MapFS{}, map[string]gemini.Author{
+		"alice": {Fingerprints: []string{testcert.Client().Fingerprint()}},
+	})
+	addr := startServer(t, &gemini.Server{Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		id, ok := idx.AuthorOf(r)
+		if !ok {
+			w.WriteStatusMsg(gemini.StatusCertNotAuthorized, "Unknown Author")
+			return
+		}
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		w.WriteBody([]byte(id))
+	})})
+
+	header, body := roundTrip(t, addr, "gemini://localhost/", testcert.Client().TLSCertificate())
+	require.Equal(t, "20 text/gemini\r\n", header)
+	require.Equal(t, "alice", body)
+	header, _ = roundTrip(t, addr, "gemini://localhost/", testcert.ExpiredClient().TLSCertificate())
+	require.Equal(t, "61 Unknown Author\r\n", header)
+}
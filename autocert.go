@@ -0,0 +1,80 @@
+package gemini
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/kulak/gemini/certs"
+)
+
+// createCert generates and saves a self-signed certificate for the
+// server's host names unless certFile and keyFile exist. Only one of them
+// existing is an error, so a key is never overwritten.
+func (srv *Server) createCert(addr, certFile, keyFile string) error {
+	certExists, err := fileExists(certFile)
+	if err != nil {
+		return err
+	}
+	keyExists, err := fileExists(keyFile)
+	if err != nil {
+		return err
+	}
+	if certExists && keyExists {
+		return nil
+	}
+	if certExists || keyExists {
+		return fmt.Errorf("only one of %s and %s exists", certFile, keyFile)
+	}
+	hosts := srv.certHosts(addr)
+	cert, err := certs.NewServer(certs.Options{Hosts: hosts})
+	if err != nil {
+		return err
+	}
+	for _, f := range []string{certFile, keyFile} {
+		if err := os.MkdirAll(filepath.Dir(f), 0o755); err != nil {
+			return err
+		}
+	}
+	if err := certs.Save(cert, certFile, keyFile); err != nil {
+		return err
+	}
+	srv.logf("gemini: created self-signed certificate for %v in %s", hosts, certFile)
+	return nil
+}
+
+// certHosts returns the host names of Hosts or addr, without ports and
+// wildcard addresses.
+func (srv *Server) certHosts(addr string) []string {
+	names := srv.Hosts
+	if len(names) == 0 {
+		names = []string{addr}
+	}
+	var hosts []string
+	seen := map[string]bool{}
+	for _, name := range names {
+		if host, _, err := net.SplitHostPort(name); err == nil {
+			name = host
+		}
+		if ip := net.ParseIP(name); ip != nil && ip.IsUnspecified() {
+			continue
+		}
+		if name != "" && !seen[name] {
+			seen[name] = true
+			hosts = append(hosts, name)
+		}
+	}
+	if len(hosts) == 0 {
+		hosts = []string{"localhost"}
+	}
+	return hosts
+}
+
+func fileExists(name string) (bool, error) {
+	_, err := os.Stat(name)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,177 @@
+// Package certs creates self-signed certificates for Gemini client
+// identities and servers, and saves and loads them as PEM files.
+//
+// Gemini clients identify themselves with self-signed certificates and
+// servers commonly use self-signed certificates trusted on first use, so
+// neither needs a certificate authority:
+//
+//	cert, err := certs.NewClient(certs.Options{CommonName: "alice"})
+//	...
+//	err = certs.Save(cert, "alice.crt", "alice.key")
+package certs
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"time"
+)
+
+// KeyType selects the algorithm of a generated key.
+type KeyType int
+
+const (
+	// ECDSA keys use the P-256 curve. They are supported by all TLS
+	// versions Gemini allows.
+	ECDSA KeyType = iota
+
+	// Ed25519 keys are smaller and faster, but only usable with TLS 1.3.
+	Ed25519
+)
+
+// Options configure a generated certificate.
+type Options struct {
+	// CommonName is the subject common name. For client certificates it
+	// is the name the identity is shown with. Server certificates default
+	// to the first of Hosts.
+	CommonName string
+
+	// Hosts lists the DNS names and IP addresses a server certificate is
+	// valid for.
+	Hosts []string
+
+	// KeyType is the algorithm of the key. Defaults to ECDSA.
+	KeyType KeyType
+
+	// Duration is how long the certificate is valid. Since a changed
+	// certificate breaks trust on first use, it defaults to 10 years.
+	Duration time.Duration
+}
+
+// NewClient returns a self-signed client identity certificate.
+func NewClient(opts Options) (tls.Certificate, error) {
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: opts.CommonName},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	return create(tmpl, opts)
+}
+
+// NewServer returns a self-signed server certificate for opts.Hosts.
+func NewServer(opts Options) (tls.Certificate, error) {
+	if len(opts.Hosts) == 0 {
+		return tls.Certificate{}, errors.New("certs: no hosts for server certificate")
+	}
+	cn := opts.CommonName
+	if cn == "" {
+		cn = opts.Hosts[0]
+	}
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range opts.Hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	return create(tmpl, opts)
+}
+
+func create(tmpl *x509.Certificate, opts Options) (tls.Certificate, error) {
+	key, err := newKey(opts.KeyType)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	duration := opts.Duration
+	if duration <= 0 {
+		duration = 10 * 365 * 24 * time.Hour
+	}
+	// Backdate the start a little to tolerate clock skew.
+	now := time.Now()
+	tmpl.NotBefore, tmpl.NotAfter = now.Add(-time.Hour), now.Add(duration)
+	tmpl.BasicConstraintsValid = true
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
+
+func newKey(t KeyType) (crypto.Signer, error) {
+	switch t {
+	case ECDSA:
+		return ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	case Ed25519:
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		return key, err
+	}
+	return nil, errors.New("certs: unknown key type")
+}
+
+// Encode returns the PEM encoded certificate chain and private key of
+// cert.
+func Encode(cert tls.Certificate) (certPEM, keyPEM []byte, err error) {
+	if len(cert.Certificate) == 0 {
+		return nil, nil, errors.New("certs: empty certificate")
+	}
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})...)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// Save writes cert to certFile and its private key to keyFile as PEM.
+// The key file is readable by its owner only.
+func Save(cert tls.Certificate, certFile, keyFile string) error {
+	certPEM, keyPEM, err := Encode(cert)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(keyFile, keyPEM, 0600); err != nil {
+		return err
+	}
+	return os.WriteFile(certFile, certPEM, 0644)
+}
+
+// Load reads a certificate and its private key saved as PEM, with Leaf
+// set to the parsed certificate.
+func Load(certFile, keyFile string) (tls.Certificate, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	cert.Leaf, err = x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return cert, nil
+}
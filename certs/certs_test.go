@@ -0,0 +1,58 @@
+package certs_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/x509"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini/certs"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewClient(t *testing.T) {
+	cert, err := certs.NewClient(certs.Options{CommonName: "alice", KeyType: certs.Ed25519, Duration: time.Hour})
+	require.NoError(t, err)
+	require.Equal(t, "alice", cert.Leaf.Subject.CommonName)
+	require.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth}, cert.Leaf.ExtKeyUsage)
+	require.IsType(t, ed25519.PrivateKey{}, cert.PrivateKey)
+	require.WithinDuration(t, time.Now().Add(time.Hour), cert.Leaf.NotAfter, time.Minute)
+
+	cert, err = certs.NewClient(certs.Options{})
+	require.NoError(t, err)
+	require.IsType(t, &ecdsa.PrivateKey{}, cert.PrivateKey)
+	require.True(t, cert.Leaf.NotAfter.After(time.Now().AddDate(9, 0, 0)))
+}
+
+func TestNewServer(t *testing.T) {
+	cert, err := certs.NewServer(certs.Options{Hosts: []string{"example.org", "127.0.0.1"}})
+	require.NoError(t, err)
+	require.Equal(t, "example.org", cert.Leaf.Subject.CommonName)
+	require.NoError(t, cert.Leaf.VerifyHostname("example.org"))
+	require.NoError(t, cert.Leaf.VerifyHostname("127.0.0.1"))
+	require.Error(t, cert.Leaf.VerifyHostname("example.com"))
+
+	_, err = certs.NewServer(certs.Options{})
+	require.Error(t, err)
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "id.crt"), filepath.Join(dir, "id.key")
+	for _, kt := range []certs.KeyType{certs.ECDSA, certs.Ed25519} {
+		cert, err := certs.NewClient(certs.Options{CommonName: "bob", KeyType: kt})
+		require.NoError(t, err)
+		require.NoError(t, certs.Save(cert, certFile, keyFile))
+		loaded, err := certs.Load(certFile, keyFile)
+		require.NoError(t, err)
+		require.Equal(t, cert.Certificate, loaded.Certificate)
+		require.Equal(t, cert.PrivateKey, loaded.PrivateKey)
+		require.Equal(t, "bob", loaded.Leaf.Subject.CommonName)
+	}
+	fi, err := os.Stat(keyFile)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+}
@@ -0,0 +1,203 @@
+package gemini
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CGIHandler serves requests by running executables found under Root.
+// The request path selects the executable: the first path segments naming
+// a regular file under Root form SCRIPT_NAME and the remainder PATH_INFO.
+//
+// The executable writes a complete Gemini response, header line and
+// body, to its standard output, which is streamed to the client. A
+// missing or malformed header line is answered with 42 CGI ERROR.
+// Titan uploads are passed on standard input.
+//
+// Besides the conventional CGI variables the environment contains
+// GEMINI_URL and, when the client presented a certificate,
+// TLS_CLIENT_HASH (see Fingerprint) and TLS_CLIENT_SUBJECT.
+//
+// To serve scripts under a URL subtree, mount the handler:
+//
+//	mux.Mount("/cgi-bin/", &gemini.CGIHandler{Root: "/var/gemini/cgi-bin"})
+type CGIHandler struct {
+	// Root is the directory containing the executables.
+	Root string
+
+	// Env lists additional "KEY=value" environment variables.
+	Env []string
+
+	// Timeout limits the execution of a script. Defaults to 10 seconds.
+	Timeout time.Duration
+
+	// Stderr receives the standard error of scripts. If nil, os.Stderr
+	// is used.
+	Stderr io.Writer
+}
+
+// ServeTitan runs the script named by the request path with the upload
+// on its standard input.
+func (h *CGIHandler) ServeTitan(w ResponseWriter, r *Request) {
+	h.ServeGemini(w, r)
+}
+
+// ServeGemini runs the script named by the request path.
+func (h *CGIHandler) ServeGemini(w ResponseWriter, r *Request) {
+	upath := r.URL.Path
+	if hasDotDot(upath) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	script, scriptName, pathInfo, ok := h.lookup(path.Clean("/" + upath))
+	if !ok {
+		NotFound(w, r)
+		return
+	}
+
+	timeout := h.Timeout
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, script)
+	cmd.Dir = filepath.Dir(script)
+	cmd.Env = append(h.env(r, scriptName, pathInfo), h.Env...)
+	cmd.Stderr = h.Stderr
+	if cmd.Stderr == nil {
+		cmd.Stderr = os.Stderr
+	}
+	if r.URL.Scheme == SchemaTitan && r.Titan.Body != nil {
+		cmd.Stdin = r.Titan.Reader()
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		w.WriteStatusMsg(StatusCGIError, Message(r, "CGI Error"))
+		return
+	}
+	if err := cmd.Start(); err != nil {
+		w.WriteStatusMsg(StatusCGIError, Message(r, "CGI Error"))
+		return
+	}
+	defer cmd.Wait()
+	// Children of the script may keep stdout open after it was killed.
+	go func() {
+		<-ctx.Done()
+		stdout.Close()
+	}()
+
+	br := bufio.NewReader(stdout)
+	status, meta, ok := readCGIHeader(br)
+	if !ok {
+		cancel()
+		if ctx.Err() == context.DeadlineExceeded {
+			w.WriteStatusMsg(StatusCGIError, Message(r, "CGI Timeout"))
+			return
+		}
+		w.WriteStatusMsg(StatusCGIError, Message(r, "CGI Error"))
+		return
+	}
+	if w.WriteStatusMsg(status, meta) != nil {
+		cancel()
+		return
+	}
+	if _, err := CopyBody(ctx, w, br); err != nil {
+		RecordError(w, err)
+		cancel()
+	}
+}
+
+// lookup finds the executable for the cleaned request path p.
+func (h *CGIHandler) lookup(p string) (script, scriptName, pathInfo string, ok bool) {
+	segs := strings.Split(strings.TrimPrefix(p, "/"), "/")
+	for i := range segs {
+		name := filepath.Join(h.Root, filepath.FromSlash(strings.Join(segs[:i+1], "/")))
+		fi, err := os.Stat(name)
+		if err != nil {
+			return "", "", "", false
+		}
+		if fi.IsDir() {
+			continue
+		}
+		if !fi.Mode().IsRegular() || fi.Mode().Perm()&0111 == 0 {
+			return "", "", "", false
+		}
+		scriptName = "/" + strings.Join(segs[:i+1], "/")
+		if i+1 < len(segs) {
+			pathInfo = "/" + strings.Join(segs[i+1:], "/")
+		}
+		return name, scriptName, pathInfo, true
+	}
+	return "", "", "", false
+}
+
+func (h *CGIHandler) env(r *Request, scriptName, pathInfo string) []string {
+	host, port := r.URL.Hostname(), r.URL.Port()
+	if port == "" {
+		port = "1965"
+	}
+	env := []string{
+		"GATEWAY_INTERFACE=CGI/1.1",
+		"SERVER_PROTOCOL=GEMINI",
+		"SERVER_SOFTWARE=kulak/gemini",
+		"SERVER_NAME=" + host,
+		"SERVER_PORT=" + port,
+		"GEMINI_URL=" + r.URL.String(),
+		"SCRIPT_NAME=" + scriptName,
+		"PATH_INFO=" + pathInfo,
+		"QUERY_STRING=" + r.URL.RawQuery,
+	}
+	if addr, rport, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		env = append(env, "REMOTE_ADDR="+addr, "REMOTE_PORT="+rport)
+	} else {
+		env = append(env, "REMOTE_ADDR="+r.RemoteAddr)
+	}
+	if path := os.Getenv("PATH"); path != "" {
+		env = append(env, "PATH="+path)
+	}
+	if r.URL.Scheme == SchemaTitan {
+		env = append(env,
+			"CONTENT_LENGTH="+strconv.FormatInt(r.Titan.Size, 10),
+			"CONTENT_TYPE="+r.Titan.Mime,
+			"TITAN_TOKEN="+r.Titan.Token,
+		)
+	}
+	if cert := r.Certificate(); cert != nil {
+		env = append(env,
+			"AUTH_TYPE=CERTIFICATE",
+			"REMOTE_USER="+cert.Subject.CommonName,
+			"TLS_CLIENT_HASH="+Fingerprint(cert),
+			"TLS_CLIENT_SUBJECT="+cert.Subject.String(),
+		)
+	}
+	return env
+}
+
+// readCGIHeader reads and parses the response header line of a script.
+func readCGIHeader(br *bufio.Reader) (StatusCode, string, bool) {
+	line, err := br.ReadString('\n')
+	if err != nil || len(line) > 1024+5 {
+		return 0, "", false
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if len(line) < 2 {
+		return 0, "", false
+	}
+	code, err := strconv.Atoi(line[:2])
+	if err != nil || code < 10 || code > 69 {
+		return 0, "", false
+	}
+	meta := strings.TrimPrefix(line[2:], " ")
+	return StatusCode(code), meta, true
+}
@@ -0,0 +1,61 @@
+package gemini_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func writeScript(t *testing.T, dir, name, body string) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("#!/bin/sh\n"+body), 0755))
+}
+
+func TestCGIHandler(t *testing.T) {
+	root := t.TempDir()
+	writeScript(t, root, "env", `printf '20 text/plain\r\n'; echo "$GEMINI_URL|$SCRIPT_NAME|$PATH_INFO|$QUERY_STRING|$REMOTE_ADDR|$REMOTE_PORT"`)
+	writeScript(t, root, "broken", `echo hello`)
+	writeScript(t, root, "slow", `exec sleep 5; printf '20 text/plain\r\n'`)
+	require.NoError(t, os.WriteFile(filepath.Join(root, "data.txt"), []byte("x"), 0644))
+	h := &gemini.CGIHandler{Root: root, Timeout: 200 * time.Millisecond}
+
+	r := &gemini.Request{RemoteAddr: "192.0.2.1:5000"}
+	require.NoError(t, r.Reset(nil, "gemini://localhost/env/extra/path?q=1"))
+	w := &recorder{}
+	h.ServeGemini(w, r)
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/plain", w.meta)
+	require.Equal(t, "gemini://localhost/env/extra/path?q=1|/env|/extra/path|q=1|192.0.2.1|5000\n", w.body.String())
+
+	w = serve(t, h, "gemini://localhost/broken")
+	require.Equal(t, gemini.StatusCGIError, w.status)
+	require.Equal(t, "CGI Error", w.meta)
+
+	w = serve(t, h, "gemini://localhost/slow")
+	require.Equal(t, gemini.StatusCGIError, w.status)
+	require.Equal(t, "CGI Timeout", w.meta)
+
+	for _, p := range []string{"/missing", "/data.txt", "/"} {
+		w = serve(t, h, "gemini://localhost"+p)
+		require.Equal(t, gemini.StatusNotFound, w.status, p)
+	}
+}
+
+func TestCGIHandlerTitan(t *testing.T) {
+	root := t.TempDir()
+	writeScript(t, root, "upload", `printf '20 text/plain\r\n'; echo "$CONTENT_TYPE $CONTENT_LENGTH"; cat`)
+	h := &gemini.CGIHandler{Root: root}
+
+	w := &recorder{}
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/upload;mime=text/plain;size=5"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("hello, and more"))
+	h.ServeGemini(w, r)
+	require.Equal(t, "text/plain 5\nhello", w.body.String())
+}
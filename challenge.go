@@ -0,0 +1,237 @@
+package gemini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Challenge asks anonymous clients to answer a question before their
+// input is accepted, to keep out automated spam:
+//
+//	ch := &gemini.Challenge{}
+//	mux.Handle("/challenge/", ch)
+//	mux.Handle("/guestbook", ch.Middleware(guestbook))
+//
+// Requests with a query and without a client certificate, from clients
+// that have not recently answered a challenge, are redirected to a
+// challenge URL below Path holding a short-lived token. There the client
+// is asked the question with 10 INPUT and, once it answers correctly, is
+// redirected back to the original request. To challenge only suspicious
+// submissions, use the middleware as a SpamFilter's OnChallenge:
+//
+//	filter.OnChallenge = ch.Middleware(guestbook)
+//
+// Titan uploads are not challenged, since they cannot be redirected
+// with their body.
+type Challenge struct {
+	// Path is where the challenge is mounted. Defaults to "/challenge/".
+	Path string
+
+	// Question returns a question and its answer. Answers are compared
+	// ignoring case and surrounding space. Defaults to adding two numbers.
+	Question func(r *Request) (question, answer string)
+
+	// TTL is how long a challenge may be answered. Defaults to 5 minutes.
+	TTL time.Duration
+
+	// Verified is how long a client that answered a challenge is not
+	// challenged again. Defaults to an hour.
+	Verified time.Duration
+
+	// Attempts is the number of answers accepted for a challenge.
+	// Defaults to 3.
+	Attempts int
+
+	// Key identifies the client of a request. Defaults to KeyByIP.
+	Key func(*Request) string
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu       sync.Mutex
+	pending  map[string]*challengeState
+	verified map[string]time.Time
+}
+
+type challengeState struct {
+	key      string
+	question string
+	answer   string
+	target   string
+	expires  time.Time
+	attempts int
+}
+
+// ArithmeticQuestion asks for the sum of two random numbers below 10.
+func ArithmeticQuestion(r *Request) (question, answer string) {
+	a, b := randInt(9)+1, randInt(9)+1
+	return messagef(r, "What is %d plus %d?", a, b), fmt.Sprint(a + b)
+}
+
+func randInt(n int64) int64 {
+	v, err := rand.Int(rand.Reader, big.NewInt(n))
+	if err != nil {
+		panic(err)
+	}
+	return v.Int64()
+}
+
+// Middleware returns next wrapped in the challenge. Requests below Path
+// are served by the challenge itself.
+func (c *Challenge) Middleware(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			if strings.HasPrefix(r.URL.Path, c.path()) {
+				c.ServeGemini(w, r)
+				return
+			}
+			if r.URL.Scheme == SchemaTitan || r.URL.RawQuery == "" || r.Certificate() != nil {
+				next.ServeGemini(w, r)
+				return
+			}
+			key, now := c.key(r), c.now()
+			c.mu.Lock()
+			until, ok := c.verified[key]
+			c.mu.Unlock()
+			if ok && now.Before(until) {
+				next.ServeGemini(w, r)
+				return
+			}
+			token, err := newChallengeToken()
+			if err != nil {
+				w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+				return
+			}
+			question, answer := c.question(r)
+			c.mu.Lock()
+			c.prune(now)
+			if c.pending == nil {
+				c.pending = make(map[string]*challengeState)
+			}
+			c.pending[token] = &challengeState{
+				key:      key,
+				question: question,
+				answer:   answer,
+				target:   r.URL.String(),
+				expires:  now.Add(c.ttl()),
+			}
+			c.mu.Unlock()
+			w.WriteStatusMsg(StatusTemporaryRedirect, c.path()+token)
+		}
+	})
+}
+
+// ServeGemini serves the challenge URLs below Path.
+func (c *Challenge) ServeGemini(w ResponseWriter, r *Request) {
+	token := strings.TrimPrefix(r.URL.Path, c.path())
+	now := c.now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	st, ok := c.pending[token]
+	if !ok || !now.Before(st.expires) || st.key != c.key(r) {
+		w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Challenge expired"))
+		return
+	}
+	if r.URL.RawQuery == "" {
+		w.WriteStatusMsg(StatusPlainInput, st.question)
+		return
+	}
+	answer, err := r.Query()
+	if err != nil {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	if !strings.EqualFold(strings.TrimSpace(answer), strings.TrimSpace(st.answer)) {
+		st.attempts++
+		if st.attempts >= c.attempts() {
+			delete(c.pending, token)
+			w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Challenge failed"))
+			return
+		}
+		w.WriteStatusMsg(StatusPlainInput, messagef(r, "Wrong answer. %s", st.question))
+		return
+	}
+	delete(c.pending, token)
+	if c.verified == nil {
+		c.verified = make(map[string]time.Time)
+	}
+	c.verified[st.key] = now.Add(c.verifiedFor())
+	w.WriteStatusMsg(StatusTemporaryRedirect, st.target)
+}
+
+// prune removes expired challenges and verifications. It is called with
+// c.mu held.
+func (c *Challenge) prune(now time.Time) {
+	for token, st := range c.pending {
+		if !now.Before(st.expires) {
+			delete(c.pending, token)
+		}
+	}
+	for key, until := range c.verified {
+		if !now.Before(until) {
+			delete(c.verified, key)
+		}
+	}
+}
+
+func newChallengeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (c *Challenge) path() string {
+	if c.Path == "" {
+		return "/challenge/"
+	}
+	return c.Path
+}
+
+func (c *Challenge) question(r *Request) (string, string) {
+	if c.Question != nil {
+		return c.Question(r)
+	}
+	return ArithmeticQuestion(r)
+}
+
+func (c *Challenge) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return 5 * time.Minute
+	}
+	return c.TTL
+}
+
+func (c *Challenge) verifiedFor() time.Duration {
+	if c.Verified <= 0 {
+		return time.Hour
+	}
+	return c.Verified
+}
+
+func (c *Challenge) attempts() int {
+	if c.Attempts <= 0 {
+		return 3
+	}
+	return c.Attempts
+}
+
+func (c *Challenge) key(r *Request) string {
+	if c.Key != nil {
+		return c.Key(r)
+	}
+	return KeyByIP(r)
+}
+
+func (c *Challenge) now() time.Time {
+	if c.Now != nil {
+		return c.Now()
+	}
+	return time.Now()
+}
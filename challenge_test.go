@@ -0,0 +1,62 @@
+package gemini_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChallenge(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ch := &gemini.Challenge{
+		Question: func(*gemini.Request) (string, string) { return "Capital of France?", "Paris" },
+		Verified: time.Hour,
+		Now:      func() time.Time { return now },
+	}
+	h := ch.Middleware(text("ok"))
+
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/guestbook").status)
+
+	w := serve(t, h, "gemini://localhost/guestbook?hello")
+	require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+	require.True(t, strings.HasPrefix(w.meta, "/challenge/"), w.meta)
+	challenge := "gemini://localhost" + w.meta
+
+	w = serve(t, h, challenge)
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Equal(t, "Capital of France?", w.meta)
+	w = serve(t, h, challenge+"?Lyon")
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Equal(t, "Wrong answer. Capital of France?", w.meta)
+	w = serve(t, h, challenge+"?%20paris")
+	require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+	require.Equal(t, "gemini://localhost/guestbook?hello", w.meta)
+
+	require.Equal(t, "Challenge expired", serve(t, h, challenge+"?paris").meta)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/guestbook?hello").status)
+
+	now = now.Add(time.Hour)
+	require.Equal(t, gemini.StatusTemporaryRedirect, serve(t, h, "gemini://localhost/guestbook?again").status)
+}
+
+func TestChallengeAttempts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	ch := &gemini.Challenge{Attempts: 2, TTL: time.Minute, Now: func() time.Time { return now }}
+	h := ch.Middleware(text("ok"))
+
+	challenge := "gemini://localhost" + serve(t, h, "gemini://localhost/?hi").meta
+	w := serve(t, h, challenge)
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Regexp(t, `^What is \d plus \d\?$`, w.meta)
+	require.Equal(t, gemini.StatusPlainInput, serve(t, h, challenge+"?x").status)
+	w = serve(t, h, challenge+"?x")
+	require.Equal(t, gemini.StatusGeneralPermFail, w.status)
+	require.Equal(t, "Challenge failed", w.meta)
+
+	challenge = "gemini://localhost" + serve(t, h, "gemini://localhost/?hi").meta
+	now = now.Add(time.Minute)
+	require.Equal(t, "Challenge expired", serve(t, h, challenge).meta)
+}
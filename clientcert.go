@@ -0,0 +1,71 @@
+package gemini
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+)
+
+// Client certificate errors returned by Server.VerifyClientCertificate
+// implementations.
+var (
+	ErrCertificateRequired      = errors.New("Certificate Required")
+	ErrCertificateNotAuthorized = errors.New("Certificate Not Authorized")
+	ErrCertificateNotValid      = errors.New("Certificate Not Valid")
+)
+
+func certErrorStatus(err error) StatusCode {
+	switch {
+	case errors.Is(err, ErrCertificateRequired):
+		return StatusCertRequired
+	case errors.Is(err, ErrCertificateNotValid):
+		return StatusCertNotValid
+	default:
+		return StatusCertNotAuthorized
+	}
+}
+
+// Fingerprint returns hex encoded SHA-256 hash of the DER encoded cert.
+func Fingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+type certContextKey struct{}
+
+type fingerprintContextKey struct{}
+
+// RequireCertificate is a Middleware answering requests without a client
+// certificate with 60. Otherwise the certificate and its fingerprint are
+// stored in the request context; see ContextCertificate and
+// ContextFingerprint.
+func RequireCertificate(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			cert := r.Certificate()
+			if cert == nil {
+				w.WriteStatusMsg(StatusCertRequired, Message(r, ErrCertificateRequired.Error()))
+				return
+			}
+			ctx := context.WithValue(r.Context(), certContextKey{}, cert)
+			ctx = context.WithValue(ctx, fingerprintContextKey{}, Fingerprint(cert))
+			next.ServeGemini(w, r.WithContext(ctx))
+		}
+	})
+}
+
+// ContextCertificate returns the client certificate stored by
+// RequireCertificate, or nil.
+func ContextCertificate(ctx context.Context) *x509.Certificate {
+	cert, _ := ctx.Value(certContextKey{}).(*x509.Certificate)
+	return cert
+}
+
+// ContextFingerprint returns the client certificate fingerprint stored by
+// RequireCertificate, or an empty string.
+func ContextFingerprint(ctx context.Context) string {
+	fp, _ := ctx.Value(fingerprintContextKey{}).(string)
+	return fp
+}
@@ -0,0 +1,186 @@
+// Command aggregator serves a single subscribable page merging the
+// entries of several Gemini feeds, refreshed periodically:
+//
+//	aggregator -interval 1h gemini://example.org/gemlog/ gemini://example.net/posts/
+//
+// Feeds are fetched without verifying server certificates, as capsules
+// commonly use self-signed ones.
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/gemtext"
+)
+
+// maxFeedSize limits the size of fetched feeds.
+const maxFeedSize = 1 << 20
+
+type aggregator struct {
+	title string
+	feeds []string
+	fetch func(ctx context.Context, rawurl string) (*url.URL, []byte, error)
+
+	mu   sync.Mutex
+	page gemtext.Document
+}
+
+func newAggregator(title string, feeds []string) *aggregator {
+	return &aggregator{title: title, feeds: feeds, fetch: fetchGemini}
+}
+
+// refresh fetches all feeds and rebuilds the merged page. Feeds that fail
+// to load are logged and left out.
+func (a *aggregator) refresh(ctx context.Context) {
+	merged := gemtext.Feed{Title: a.title}
+	seen := map[string]bool{}
+	for _, rawurl := range a.feeds {
+		base, body, err := a.fetch(ctx, rawurl)
+		if err != nil {
+			log.Printf("aggregator: %s: %v", rawurl, err)
+			continue
+		}
+		feed := gemtext.ParseFeed(gemtext.ParseString(string(body)))
+		for _, e := range feed.Entries {
+			u, err := base.Parse(e.URL)
+			if err != nil || seen[u.String()] {
+				continue
+			}
+			seen[u.String()] = true
+			e.URL = u.String()
+			if feed.Title != "" {
+				e.Title = feed.Title + ": " + e.Title
+			}
+			merged.Entries = append(merged.Entries, e)
+		}
+	}
+	var b strings.Builder
+	gemtext.WriteFeed(&b, merged)
+	a.mu.Lock()
+	a.page = gemtext.ParseString(b.String())
+	a.mu.Unlock()
+}
+
+func (a *aggregator) ServeGemini(w gemini.ResponseWriter, r *gemini.Request) {
+	if r.URL.Path != "/" {
+		gemini.NotFound(w, r)
+		return
+	}
+	a.mu.Lock()
+	page := a.page
+	a.mu.Unlock()
+	(&gemini.Paginator{Lines: 100}).Serve(w, r, page)
+}
+
+// fetchGemini returns the text/gemini body at rawurl, following up to five
+// redirects, and the URL it was finally fetched from.
+func fetchGemini(ctx context.Context, rawurl string) (*url.URL, []byte, error) {
+	for redirects := 0; redirects <= 5; redirects++ {
+		u, err := url.Parse(rawurl)
+		if err != nil {
+			return nil, nil, err
+		}
+		status, meta, body, err := request(ctx, u)
+		if err != nil {
+			return nil, nil, err
+		}
+		switch {
+		case status == gemini.StatusTemporaryRedirect || status == gemini.StatusPermanentRedirect:
+			next, err := u.Parse(meta)
+			if err != nil {
+				return nil, nil, err
+			}
+			rawurl = next.String()
+		case status != gemini.StatusSuccess:
+			return nil, nil, fmt.Errorf("status %d %s", status, meta)
+		case !strings.HasPrefix(meta, "text/gemini"):
+			return nil, nil, fmt.Errorf("not a gemtext page: %s", meta)
+		default:
+			return u, body, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("too many redirects")
+}
+
+func request(ctx context.Context, u *url.URL) (gemini.StatusCode, string, []byte, error) {
+	host := u.Host
+	if u.Port() == "" {
+		host = net.JoinHostPort(u.Hostname(), "1965")
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	d := tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true, ServerName: u.Hostname()}}
+	conn, err := d.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return 0, "", nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	if _, err := io.WriteString(conn, u.String()+"\r\n"); err != nil {
+		return 0, "", nil, err
+	}
+	br := bufio.NewReader(conn)
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return 0, "", nil, fmt.Errorf("failed to read response header: %v", err)
+	}
+	var status int
+	var meta string
+	header = strings.TrimRight(header, "\r\n")
+	if _, err := fmt.Sscanf(header, "%d", &status); err != nil {
+		return 0, "", nil, fmt.Errorf("invalid response header: %q", header)
+	}
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		meta = header[i+1:]
+	}
+	body, err := io.ReadAll(io.LimitReader(br, maxFeedSize))
+	if err != nil && len(body) == 0 {
+		return 0, "", nil, err
+	}
+	return gemini.StatusCode(status), meta, body, nil
+}
+
+func main() {
+	var host, cert, key, title string
+	var interval time.Duration
+	flag.StringVar(&host, "host", ":1965", "listen on host and port.  Example: hostname:1965")
+	flag.StringVar(&cert, "cert", "server.crt.pem", "certificate file")
+	flag.StringVar(&key, "key", "server.key.pem", "private key associated with certificate file")
+	flag.StringVar(&title, "title", "Aggregated feeds", "title of the merged feed")
+	flag.DurationVar(&interval, "interval", time.Hour, "time between feed refreshes")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("aggregator: no feed URLs given")
+	}
+
+	agg := newAggregator(title, flag.Args())
+	agg.refresh(context.Background())
+	go func() {
+		for range time.Tick(interval) {
+			agg.refresh(context.Background())
+		}
+	}()
+
+	srv := &gemini.Server{
+		Addr:      host,
+		Handler:   gemini.TrapPanic(agg),
+		AccessLog: gemini.NewAccessLog(nil),
+	}
+	if err := srv.ListenAndServeTLS(cert, key); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/kulak/gemini/testkit"
+)
+
+func startCapsule(t *testing.T, h gemini.Handler) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testcert.Leaf().TLSCertificate()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &gemini.Server{Handler: h}
+	go srv.Serve(ln)
+	t.Cleanup(func() { srv.Close() })
+	return "gemini://" + ln.Addr().String()
+}
+
+func page(body string) gemini.HandlerFunc {
+	return func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		w.WriteBody([]byte(body))
+	}
+}
+
+func TestAggregator(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/gemlog/", page("# Gemlog A\n\n=> first.gmi 2024-01-01 First\n=> /gemlog/second.gmi 2024-03-01 Second\n"))
+	mux.HandleFunc("/moved", func(w gemini.ResponseWriter, r *gemini.Request) {
+		gemini.Redirect(w, "/gemlog/", true)
+	})
+	a := startCapsule(t, mux)
+	b := startCapsule(t, page("# Posts B\n\n=> gemini://example.org/post 2024-02-01 - Middle\n"))
+
+	agg := newAggregator("Merged", []string{a + "/moved", b + "/", a + "/missing"})
+	agg.refresh(context.Background())
+
+	scenarios, err := testkit.Load("testdata/scenarios.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testkit.Report(t, testkit.RunHandler(agg, scenarios))
+}
@@ -0,0 +1,54 @@
+// Command blog serves a gemlog from a directory of .gmi and .md posts with
+// front matter, with tag pages and a capsule manifest:
+//
+//	blog -root /var/gemini/blog -title "My gemlog"
+//
+// Posts marked as drafts or dated in the future are hidden until their
+// date has passed.
+package main
+
+import (
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+
+	"github.com/kulak/gemini"
+)
+
+func newHandler(fsys fs.FS, title string) gemini.Handler {
+	files := gemini.FileServerFS(fsys)
+	files.Markdown = true
+	files.FrontMatter = true
+	files.Listing = true
+	files.TOC = 3
+
+	mux := gemini.NewServeMux()
+	mux.Use(gemini.TrapPanic)
+	mux.Mount("/tags/", gemini.NewTagIndex(fsys))
+	mux.Handle(gemini.ManifestPath, gemini.ManifestHandler(&gemini.Manifest{
+		Name:  title,
+		Feeds: []string{"/posts/"},
+	}, mux))
+	mux.Handle("/", files)
+	return mux
+}
+
+func main() {
+	var host, cert, key, root, title string
+	flag.StringVar(&host, "host", ":1965", "listen on host and port.  Example: hostname:1965")
+	flag.StringVar(&cert, "cert", "server.crt.pem", "certificate file")
+	flag.StringVar(&key, "key", "server.key.pem", "private key associated with certificate file")
+	flag.StringVar(&root, "root", ".", "directory holding the gemlog")
+	flag.StringVar(&title, "title", "Gemlog", "gemlog title for the capsule manifest")
+	flag.Parse()
+
+	srv := &gemini.Server{
+		Addr:      host,
+		Handler:   newHandler(os.DirFS(root), title),
+		AccessLog: gemini.NewAccessLog(nil),
+	}
+	if err := srv.ListenAndServeTLS(cert, key); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/kulak/gemini/testkit"
+)
+
+func TestBlog(t *testing.T) {
+	scenarios, err := testkit.Load("testdata/scenarios.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newHandler(os.DirFS("testdata/capsule"), "Test gemlog")
+	testkit.Report(t, testkit.RunHandler(h, scenarios))
+}
@@ -12,48 +12,56 @@ import (
 	gemini "github.com/kulak/gemini"
 )
 
-type ExampleHandler struct {
+func hello(w gemini.ResponseWriter, req *gemini.Request) {
+	if req.URL.Path != "/" {
+		notFound(w, req)
+		return
+	}
+	err := w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	requireNoError(err)
+	_, err = w.WriteBody([]byte("Hello, world!"))
+	requireNoError(err)
 }
 
-func (h ExampleHandler) ServeGemini(w gemini.ResponseWriter, req *gemini.Request) {
-	log.Printf("request: %s, user: %v", req.URL.Path, strings.Join(userName(req), " "))
-	switch req.URL.Path {
-	case "/":
-		err := w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
-		requireNoError(err)
-		_, err = w.WriteBody([]byte("Hello, world!"))
-		requireNoError(err)
-	case "/user":
-		if req.Certificate() == nil {
-			w.WriteStatusMsg(gemini.StatusCertRequired, "Authentication Required")
-			return
-		}
-		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
-		w.WriteBody([]byte(req.Certificate().Subject.CommonName))
-	case "/die":
-		requireNoError(errors.New("must die"))
-	case "/file":
-		gemini.ServeFileName("cmd/example/hello.gmi", "text/gemini")(w, req)
-	case "/post":
-		if req.URL.Scheme != gemini.SchemaTitan {
-			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
-			w.WriteBody([]byte("Use titan scheme to upload data"))
-			return
-		}
-		payload, err := req.ReadTitanPayload()
-		requireNoError(err)
-		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
-		w.WriteBody([]byte("Titan Upload Parameters\r\n"))
-		w.WriteBody([]byte(fmt.Sprintf("Upload MIME Type: %s\r\n", req.Titan.Mime)))
-		w.WriteBody([]byte(fmt.Sprintf("Token: %s\r\n", req.Titan.Token)))
-		w.WriteBody([]byte(fmt.Sprintf("Size: %v\r\n", req.Titan.Size)))
-		w.WriteBody([]byte("Payload:\r\n"))
-		w.WriteBody(payload)
-
-	default:
-		w.WriteStatusMsg(gemini.StatusNotFound, req.URL.Path)
+func user(w gemini.ResponseWriter, req *gemini.Request) {
+	if req.Certificate() == nil {
+		w.WriteStatusMsg(gemini.StatusCertRequired, "Authentication Required")
+		return
 	}
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(req.Certificate().Subject.CommonName))
+}
+
+func die(w gemini.ResponseWriter, req *gemini.Request) {
+	requireNoError(errors.New("must die"))
+}
+
+func postInfo(w gemini.ResponseWriter, req *gemini.Request) {
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	w.WriteBody([]byte("Use titan scheme to upload data"))
+}
+
+func post(w gemini.ResponseWriter, req *gemini.Request) {
+	payload, err := req.ReadTitanPayload()
+	requireNoError(err)
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	w.WriteBody([]byte("Titan Upload Parameters\r\n"))
+	w.WriteBody([]byte(fmt.Sprintf("Upload MIME Type: %s\r\n", req.Titan.Mime)))
+	w.WriteBody([]byte(fmt.Sprintf("Token: %s\r\n", req.Titan.Token)))
+	w.WriteBody([]byte(fmt.Sprintf("Size: %v\r\n", req.Titan.Size)))
+	w.WriteBody([]byte("Payload:\r\n"))
+	w.WriteBody(payload)
+}
 
+func notFound(w gemini.ResponseWriter, req *gemini.Request) {
+	w.WriteStatusMsg(gemini.StatusNotFound, req.URL.Path)
+}
+
+func logRequest(next gemini.Handler) gemini.Handler {
+	return gemini.HandlerFunc(func(w gemini.ResponseWriter, req *gemini.Request) {
+		log.Printf("request: %s, user: %v", req.URL.Path, strings.Join(userName(req), " "))
+		next.ServeGemini(w, req)
+	})
 }
 
 func requireNoError(err error) {
@@ -81,9 +89,23 @@ func main() {
 	flag.StringVar(&key, "key", "server.key.pem", "private key associated with certificate file")
 	flag.Parse()
 
-	handler := ExampleHandler{}
+	mux := gemini.NewServeMux()
+	mux.Use(gemini.TrapPanic, logRequest)
+	mux.HandleFunc("/", hello)
+	mux.HandleFunc("/user", user)
+	mux.HandleFunc("/die", die)
+	mux.Handle("/file", gemini.ServeFileName("cmd/example/hello.gmi", "text/gemini"))
+	mux.Mount("/static/", gemini.FileServer("cmd/example"))
+	mux.HandleFunc("/post", postInfo)
+	mux.HandleTitanFunc("/post", post)
 
-	err := gemini.ListenAndServe(host, cert, key, gemini.TrapPanic(handler.ServeGemini))
+	srv := &gemini.Server{
+		Addr:      host,
+		Handler:   mux,
+		AccessLog: gemini.NewAccessLog(nil),
+		AutoCert:  true,
+	}
+	err := srv.ListenAndServeTLS(cert, key)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,50 @@
+// Command gateway serves an HTTP service inside a capsule, forwarding
+// Gemini requests as GET and Titan uploads as PUT requests:
+//
+//	gateway -backend http://localhost:8080/ -rate 2 -timeout 10s
+//
+// Clients are rate limited by IP address, and backend responses taking
+// longer than the timeout are answered with 41.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/kulak/gemini"
+)
+
+func newHandler(backend *url.URL, rate float64, timeout time.Duration) gemini.Handler {
+	limit := &gemini.RateLimit{Rate: rate, Burst: 5}
+	gw := &gemini.HTTPGateway{Backend: backend}
+	return gemini.Chain(gemini.TrapPanic, limit.Middleware)(
+		gemini.TimeoutHandler(gw, timeout, "Backend timeout"))
+}
+
+func main() {
+	var host, cert, key, backend string
+	var rate float64
+	var timeout time.Duration
+	flag.StringVar(&host, "host", ":1965", "listen on host and port.  Example: hostname:1965")
+	flag.StringVar(&cert, "cert", "server.crt.pem", "certificate file")
+	flag.StringVar(&key, "key", "server.key.pem", "private key associated with certificate file")
+	flag.StringVar(&backend, "backend", "http://localhost:8080/", "base URL of the HTTP service")
+	flag.Float64Var(&rate, "rate", 2, "requests per second allowed per client")
+	flag.DurationVar(&timeout, "timeout", 10*time.Second, "maximum time to wait for the backend")
+	flag.Parse()
+
+	u, err := url.Parse(backend)
+	if err != nil {
+		log.Fatal(err)
+	}
+	srv := &gemini.Server{
+		Addr:      host,
+		Handler:   newHandler(u, rate, timeout),
+		AccessLog: gemini.NewAccessLog(nil),
+	}
+	if err := srv.ListenAndServeTLS(cert, key); err != nil {
+		log.Fatal(err)
+	}
+}
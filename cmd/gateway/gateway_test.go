@@ -0,0 +1,41 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini/testkit"
+)
+
+func TestGateway(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/":
+			w.Header().Set("Content-Type", "text/gemini")
+			io.WriteString(w, "# Backend\n")
+		case "/echo":
+			body, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			io.WriteString(w, r.Method+" "+string(body)+" "+r.URL.RawQuery)
+		case "/slow":
+			time.Sleep(200 * time.Millisecond)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+	u, err := url.Parse(backend.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scenarios, err := testkit.Load("testdata/scenarios.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testkit.Report(t, testkit.RunHandler(newHandler(u, 0.001, 50*time.Millisecond), scenarios))
+}
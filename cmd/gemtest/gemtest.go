@@ -0,0 +1,38 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/kulak/gemini/testkit"
+)
+
+func main() {
+	var addr string
+	flag.StringVar(&addr, "addr", "localhost:1965", "server host and port")
+	flag.Parse()
+	if flag.NArg() == 0 {
+		log.Fatal("usage: gemtest [-addr host:port] scenarios.yaml...")
+	}
+
+	failed := 0
+	for _, name := range flag.Args() {
+		scenarios, err := testkit.Load(name)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, r := range testkit.RunServer(addr, scenarios) {
+			if r.Err != nil {
+				failed++
+				fmt.Printf("FAIL %s: %v\n", r.Scenario.Name, r.Err)
+			} else {
+				fmt.Printf("ok   %s\n", r.Scenario.Name)
+			}
+		}
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,101 @@
+// Command wiki serves a wiki of gemtext pages that are created, edited
+// and deleted with Titan uploads authorized by a token:
+//
+//	wiki -root /var/gemini/wiki -token secret
+//
+// Uploading to titan://host/page.gmi;token=secret stores the page, and an
+// empty upload deletes it. Pages with link lines lacking a URL or an
+// unterminated preformatted block are rejected; accepted pages are
+// normalized, see gemtext.Normalize.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/gemtext"
+)
+
+type wiki struct {
+	root string
+}
+
+func newHandler(root, token string) gemini.Handler {
+	wk := &wiki{root: root}
+	pages := gemini.FileServer(root)
+	pages.Listing = true
+
+	mux := gemini.NewServeMux()
+	mux.Use(gemini.TrapPanic)
+	mux.Handle("/", pages)
+	mux.HandleTitan("/", gemini.RequireToken(gemini.StaticTokens{token})(gemini.HandlerFunc(wk.save)))
+	return mux
+}
+
+func (wk *wiki) save(w gemini.ResponseWriter, r *gemini.Request) {
+	name := path.Clean(r.URL.Path)
+	if path.Ext(name) != ".gmi" {
+		w.WriteStatusMsg(gemini.StatusBadRequest, "Pages must be named *.gmi")
+		return
+	}
+	if r.Titan.Mime != "" && r.Titan.Mime != "text/gemini" {
+		w.WriteStatusMsg(gemini.StatusBadRequest, "Pages must be text/gemini")
+		return
+	}
+	file := filepath.Join(wk.root, filepath.FromSlash(name))
+	if r.Titan.IsDelete() {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			w.WriteStatusMsg(gemini.StatusUnspecified, "Failed to delete page")
+			return
+		}
+		gemini.RedirectAfterUpload(w, r, "./")
+		return
+	}
+	body, err := r.ReadTitanPayload()
+	if err != nil {
+		w.WriteStatusMsg(gemini.StatusBadRequest, "Failed to read upload")
+		return
+	}
+	issues, err := (&gemtext.Linter{}).Lint(bytes.NewReader(body))
+	if err == nil && len(issues) > 0 {
+		w.WriteStatusMsg(gemini.StatusBadRequest, "Invalid gemtext: "+issues[0].String())
+		return
+	}
+	if body, err = gemtext.Normalize(body); err == nil {
+		if err = os.MkdirAll(filepath.Dir(file), 0o755); err == nil {
+			err = os.WriteFile(file, body, 0o644)
+		}
+	}
+	if err != nil {
+		w.WriteStatusMsg(gemini.StatusUnspecified, "Failed to save page")
+		return
+	}
+	gemini.RedirectAfterUpload(w, r, "")
+}
+
+func main() {
+	var host, cert, key, root, token string
+	flag.StringVar(&host, "host", ":1965", "listen on host and port.  Example: hostname:1965")
+	flag.StringVar(&cert, "cert", "server.crt.pem", "certificate file")
+	flag.StringVar(&key, "key", "server.key.pem", "private key associated with certificate file")
+	flag.StringVar(&root, "root", ".", "directory holding the wiki pages")
+	flag.StringVar(&token, "token", "", "token authorizing edits")
+	flag.Parse()
+	if token == "" {
+		log.Fatal("wiki: -token is required")
+	}
+
+	srv := &gemini.Server{
+		Addr:      host,
+		Handler:   newHandler(root, token),
+		AccessLog: gemini.NewAccessLog(nil),
+	}
+	if err := srv.ListenAndServeTLS(cert, key); err != nil {
+		log.Fatal(err)
+	}
+}
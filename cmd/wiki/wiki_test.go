@@ -0,0 +1,15 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini/testkit"
+)
+
+func TestWiki(t *testing.T) {
+	scenarios, err := testkit.Load("testdata/scenarios.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	testkit.Report(t, testkit.RunHandler(newHandler(t.TempDir(), "secret"), scenarios))
+}
@@ -0,0 +1,59 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// PartialWriteError reports a response body that stopped before all of the
+// content was sent.
+type PartialWriteError struct {
+	// Written is the number of bytes sent before the failure.
+	Written int64
+	Err     error
+}
+
+func (e *PartialWriteError) Error() string {
+	return fmt.Sprintf("response body stopped after %d bytes: %v", e.Written, e.Err)
+}
+
+func (e *PartialWriteError) Unwrap() error {
+	return e.Err
+}
+
+// CopyBody copies src to the response body until EOF, a write error or
+// cancellation of ctx. It returns the number of bytes written; a copy that
+// stops early returns *PartialWriteError. Write errors that already are
+// one, as returned by the server's ResponseWriter, are passed through.
+func CopyBody(ctx context.Context, w ResponseWriter, src io.Reader) (int64, error) {
+	buf := make([]byte, 32*1024)
+	var written int64
+	for {
+		if err := ctx.Err(); err != nil {
+			return written, &PartialWriteError{Written: written, Err: err}
+		}
+		nr, rerr := src.Read(buf)
+		if nr > 0 {
+			nw, werr := w.WriteBody(buf[:nr])
+			written += int64(nw)
+			if werr == nil && nw != nr {
+				werr = io.ErrShortWrite
+			}
+			if werr != nil {
+				var pw *PartialWriteError
+				if errors.As(werr, &pw) {
+					return written, werr
+				}
+				return written, &PartialWriteError{Written: written, Err: werr}
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, &PartialWriteError{Written: written, Err: rerr}
+		}
+	}
+}
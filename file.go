@@ -0,0 +1,87 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+)
+
+// ServeFile replies to the request with the content of file, which the
+// caller remains responsible for closing.
+func ServeFile(file *os.File, mimeType string) HandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		w.WriteStatusMsg(StatusSuccess, mimeType)
+		if _, err := CopyBody(r.Context(), w, file); err != nil {
+			RecordError(w, err)
+		}
+	}
+}
+
+// ServeFileName replies to the request with the content of the named file.
+// Missing files and directories are answered with 51, files the server may
+// not read with 50 and other failures with 40.
+func ServeFileName(name string, mimeType string) HandlerFunc {
+	return func(w ResponseWriter, r *Request) {
+		f, err := os.Open(name)
+		if err != nil {
+			fileError(w, r, err)
+			return
+		}
+		defer f.Close()
+		fi, err := f.Stat()
+		if err != nil {
+			fileError(w, r, err)
+			return
+		}
+		if fi.IsDir() {
+			NotFound(w, r)
+			return
+		}
+		ServeFile(f, mimeType)(w, r)
+	}
+}
+
+// ServeContent replies to the request with the content of the
+// ReadSeeker, starting from its beginning. If mimeType is empty it is
+// derived from the extension of name using DefaultMIMETypes.
+//
+// It lets generated or database backed content share the file server's
+// MIME detection and streaming.
+func ServeContent(w ResponseWriter, r *Request, name, mimeType string, content io.ReadSeeker) {
+	if mimeType == "" {
+		mimeType = TypeByExtension(path.Ext(name))
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	w.WriteStatusMsg(StatusSuccess, mimeType)
+	if _, err := CopyBody(r.Context(), w, content); err != nil {
+		RecordError(w, err)
+	}
+}
+
+// fileError replies to the request with a status describing err.
+func fileError(w ResponseWriter, r *Request, err error) {
+	switch fileErrorStatus(err) {
+	case StatusNotFound:
+		NotFound(w, r)
+	case StatusGeneralPermFail:
+		w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Permission Denied"))
+	default:
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+	}
+}
+
+func fileErrorStatus(err error) StatusCode {
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return StatusNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return StatusGeneralPermFail
+	default:
+		return StatusUnspecified
+	}
+}
@@ -0,0 +1,15 @@
+package gemini
+
+import (
+	"errors"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileErrorStatus(t *testing.T) {
+	require.Equal(t, StatusNotFound, fileErrorStatus(&fs.PathError{Op: "open", Path: "x", Err: fs.ErrNotExist}))
+	require.Equal(t, StatusGeneralPermFail, fileErrorStatus(&fs.PathError{Op: "open", Path: "x", Err: fs.ErrPermission}))
+	require.Equal(t, StatusUnspecified, fileErrorStatus(errors.New("i/o error")))
+}
@@ -0,0 +1,132 @@
+package gemini_test
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServeFileName(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "hello.gmi")
+	require.NoError(t, os.WriteFile(name, []byte("# Hello"), 0644))
+
+	w := serve(t, gemini.ServeFileName(name, "text/gemini"), "gemini://localhost/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini", w.meta)
+	require.Equal(t, "# Hello", w.body.String())
+
+	w = serve(t, gemini.ServeFileName(filepath.Join(dir, "missing.gmi"), "text/gemini"), "gemini://localhost/")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+
+	w = serve(t, gemini.ServeFileName(dir, "text/gemini"), "gemini://localhost/")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+
+	if os.Geteuid() != 0 {
+		locked := filepath.Join(dir, "locked.gmi")
+		require.NoError(t, os.WriteFile(locked, []byte("secret"), 0))
+		w = serve(t, gemini.ServeFileName(locked, "text/gemini"), "gemini://localhost/")
+		require.Equal(t, gemini.StatusGeneralPermFail, w.status)
+	}
+}
+
+func TestServeContent(t *testing.T) {
+	content := strings.NewReader("# Generated")
+	content.Seek(3, io.SeekStart)
+	h := gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		gemini.ServeContent(w, r, "page.gmi", "", content)
+	})
+
+	w := serve(t, h, "gemini://localhost/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini; charset=utf-8", w.meta)
+	require.Equal(t, "# Generated", w.body.String())
+
+	h = gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		gemini.ServeContent(w, r, "report", "text/csv", content)
+	})
+	w = serve(t, h, "gemini://localhost/")
+	require.Equal(t, "text/csv", w.meta)
+}
+
+// brokenContent fails where its content should end.
+type brokenContent struct {
+	*strings.Reader
+}
+
+var errBrokenContent = errors.New("content broken")
+
+func (c brokenContent) Read(p []byte) (int, error) {
+	n, err := c.Reader.Read(p)
+	if err == io.EOF {
+		err = errBrokenContent
+	}
+	return n, err
+}
+
+func TestServeContentRecordsError(t *testing.T) {
+	sr := gemini.NewStatusRecorder(&recorder{})
+	gemini.ServeContent(sr, &gemini.Request{}, "page.gmi", "", brokenContent{strings.NewReader("# Gen")})
+	require.Equal(t, int64(5), sr.BytesWritten())
+	var pw *gemini.PartialWriteError
+	require.True(t, errors.As(sr.Err(), &pw))
+	require.Equal(t, int64(5), pw.Written)
+	require.True(t, errors.Is(sr.Err(), errBrokenContent))
+}
+
+func TestServeFileClientDisconnect(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "big.bin")
+	require.NoError(t, os.WriteFile(name, make([]byte, 64<<20), 0644))
+	entries := make(chan gemini.AccessLogEntry, 1)
+	addr := startServer(t, &gemini.Server{
+		Handler:   gemini.ServeFileName(name, "application/octet-stream"),
+		AccessLog: gemini.AccessLogFunc(func(e gemini.AccessLogEntry) { entries <- e }),
+	})
+	disconnectAfterHeader(t, addr, "gemini://localhost/big.bin")
+
+	e := <-entries
+	require.Equal(t, gemini.StatusSuccess, e.Status)
+	var pw *gemini.PartialWriteError
+	require.True(t, errors.As(e.Err, &pw), "%v", e.Err)
+	require.Less(t, e.Bytes, int64(64<<20))
+}
+
+func TestCopyBodyClientDisconnect(t *testing.T) {
+	errs := make(chan error, 1)
+	addr := startServer(t, &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "application/octet-stream")
+			_, err := gemini.CopyBody(r.Context(), w, bytes.NewReader(make([]byte, 64<<20)))
+			errs <- err
+		}),
+	})
+	disconnectAfterHeader(t, addr, "gemini://localhost/")
+
+	err := <-errs
+	var pw *gemini.PartialWriteError
+	require.True(t, errors.As(err, &pw), "%v", err)
+	require.False(t, errors.As(pw.Err, new(*gemini.PartialWriteError)), "%v", err)
+}
+
+// disconnectAfterHeader requests url and closes the connection as soon
+// as the response header has been read.
+func disconnectAfterHeader(t *testing.T, addr, url string) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, url+"\r\n")
+	require.NoError(t, err)
+	header, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "20 application/octet-stream\r\n", header)
+}
@@ -0,0 +1,427 @@
+package gemini
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// FileHandler serves files from a directory tree. Create it with
+// FileServer or FileServerFS.
+type FileHandler struct {
+	// IndexName is the file served for directory requests.
+	// Defaults to "index.gmi".
+	IndexName string
+
+	// Listing enables generated text/gemini listings for directories
+	// without an index file. A directory containing a file named
+	// ".nolisting" is never listed; dot files are left out of listings.
+	Listing bool
+
+	// MIMETypes resolves file MIME types. If nil, DefaultMIMETypes is
+	// used.
+	MIMETypes *MIMETypes
+
+	// ConfineSymlinks answers requests for files reached through a
+	// symbolic link pointing outside of the root with 51. It only applies
+	// to handlers created with FileServer.
+	ConfineSymlinks bool
+
+	// Markdown serves .md files converted to text/gemini, see
+	// gemtext.FromMarkdown. Directories without an index file are served
+	// from index.md. Conversions are cached until the file's
+	// modification time or size changes.
+	Markdown bool
+
+	// FrontMatter strips YAML or TOML front matter from .gmi, .gemini
+	// and .md files before serving them, see gemtext.SplitFrontMatter.
+	// Drafts and files dated in the future are answered with 51 and left
+	// out of listings until their date has passed.
+	FrontMatter bool
+
+	// TOC, when positive, inserts a table of contents after the first
+	// heading of .gmi and .gemini files, and of .md files served as
+	// text/gemini, having at least TOC headings. See gemtext.BuildTOC.
+	TOC int
+
+	// Strict answers requests for .gmi and .gemini files, and for .md
+	// files served as text/gemini, with 50 naming the first problem
+	// reported by gemtext.Linter, if any.
+	Strict bool
+
+	// Preview lists the fingerprints (see Fingerprint) of client
+	// certificates allowed to see drafts and future-dated files.
+	Preview []string
+
+	// Now returns the current time for scheduled publishing. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	root string
+	fsys fs.FS
+
+	mu    sync.Mutex
+	cache map[string]contentEntry
+}
+
+// contentEntry is a converted content file and its front matter.
+type contentEntry struct {
+	modTime time.Time
+	size    int64
+	meta    gemtext.Metadata
+	body    []byte
+	issues  []gemtext.Issue
+}
+
+// FileServer returns a handler that serves requests with the contents of
+// the file system rooted at root.
+//
+// Request paths are resolved under root, so they can not refer to files
+// outside of it; paths with ".." segments are answered with 59. Symbolic
+// links are followed unless ConfineSymlinks is set. Directories are
+// served by their index.gmi file; requests for a directory without the
+// trailing slash are redirected to add it. Missing files are answered
+// with 51.
+//
+// MIME types are detected by file extension, see MIMETypes.
+//
+// To use the operating system's file system implementation and serve a
+// subtree of a capsule, combine it with StripPrefix or ServeMux.Mount:
+//
+//	mux.Mount("/files/", gemini.FileServer("/var/gemini/files"))
+func FileServer(root string) *FileHandler {
+	h := FileServerFS(os.DirFS(root))
+	h.root = root
+	return h
+}
+
+// FileServerFS returns a handler that serves requests with the contents
+// of the file system fsys, such as an embed.FS:
+//
+//	//go:embed capsule
+//	var capsule embed.FS
+//
+//	sub, _ := fs.Sub(capsule, "capsule")
+//	mux.Mount("/", gemini.FileServerFS(sub))
+//
+// It behaves like FileServer otherwise.
+func FileServerFS(fsys fs.FS) *FileHandler {
+	return &FileHandler{fsys: fsys}
+}
+
+// ServeGemini serves the file named by the request path.
+func (h *FileHandler) ServeGemini(w ResponseWriter, r *Request) {
+	upath := r.URL.Path
+	if !strings.HasPrefix(upath, "/") {
+		upath = "/" + upath
+	}
+	if hasDotDot(upath) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	name := strings.TrimPrefix(path.Clean(upath), "/")
+	if name == "" {
+		name = "."
+	}
+
+	fi, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	if !h.confined(name) {
+		NotFound(w, r)
+		return
+	}
+	if fi.IsDir() {
+		if !strings.HasSuffix(r.URL.Path, "/") {
+			redirectToDir(w, r)
+			return
+		}
+		index := path.Join(name, h.indexName())
+		fi, err = fs.Stat(h.fsys, index)
+		if (err != nil || fi.IsDir()) && h.Markdown {
+			index = path.Join(name, "index.md")
+			fi, err = fs.Stat(h.fsys, index)
+		}
+		if err != nil || fi.IsDir() {
+			if h.Listing && h.listable(name) {
+				h.serveListing(w, r, name)
+				return
+			}
+			NotFound(w, r)
+			return
+		}
+		name = index
+	}
+	h.serveFile(w, r, name)
+}
+
+// hasDotDot reports whether p has a ".." segment. Backslashes count as
+// separators so they can not be used to smuggle one past path.Clean.
+func hasDotDot(p string) bool {
+	for _, seg := range strings.FieldsFunc(p, func(r rune) bool { return r == '/' || r == '\\' }) {
+		if seg == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// confined reports whether name resolves within the root once symbolic
+// links are followed.
+func (h *FileHandler) confined(name string) bool {
+	if !h.ConfineSymlinks || h.root == "" {
+		return true
+	}
+	root, err := filepath.EvalSymlinks(h.root)
+	if err != nil {
+		return false
+	}
+	real, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(name)))
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, real)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+func (h *FileHandler) listable(dir string) bool {
+	_, err := fs.Stat(h.fsys, path.Join(dir, ".nolisting"))
+	return err != nil
+}
+
+func (h *FileHandler) serveListing(w ResponseWriter, r *Request, dir string) {
+	entries, err := fs.ReadDir(h.fsys, dir)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", messagef(r, "Index of %s", r.URL.Path))
+	if dir != "." {
+		b.WriteString("=> ../ " + Message(r, "Parent directory") + "\n")
+	}
+	for _, e := range entries {
+		name := e.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		link := (&url.URL{Path: name}).EscapedPath()
+		if e.IsDir() {
+			fmt.Fprintf(&b, "=> %s/ %s/\n", link, name)
+			continue
+		}
+		if !h.listed(r, path.Join(dir, name)) {
+			continue
+		}
+		label := name
+		if info, err := e.Info(); err == nil {
+			label = fmt.Sprintf("%s (%s)", name, formatSize(info.Size()))
+		}
+		fmt.Fprintf(&b, "=> %s %s\n", link, label)
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+func (h *FileHandler) indexName() string {
+	if h.IndexName != "" {
+		return h.IndexName
+	}
+	return "index.gmi"
+}
+
+func (h *FileHandler) serveFile(w ResponseWriter, r *Request, name string) {
+	if !h.confined(name) {
+		NotFound(w, r)
+		return
+	}
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	defer f.Close()
+	if h.isContent(name) {
+		e, err := h.content(name, f)
+		if err != nil {
+			fileError(w, r, err)
+			return
+		}
+		if !h.published(r, e.meta) {
+			NotFound(w, r)
+			return
+		}
+		ext := path.Ext(name)
+		if h.Markdown && strings.EqualFold(ext, ".md") {
+			ext = ".gmi"
+		}
+		if h.Strict && len(e.issues) > 0 {
+			w.WriteStatusMsg(StatusGeneralPermFail, messagef(r, "Invalid gemtext: %s", e.issues[0]))
+			return
+		}
+		w.WriteStatusMsg(StatusSuccess, h.mimeTypes().TypeByExtension(ext))
+		w.WriteBody(e.body)
+		return
+	}
+	w.WriteStatusMsg(StatusSuccess, h.mimeTypes().TypeByExtension(path.Ext(name)))
+	if _, err := CopyBody(r.Context(), w, f); err != nil {
+		RecordError(w, err)
+	}
+}
+
+// isContent reports whether name is served through the content cache.
+func (h *FileHandler) isContent(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md":
+		return h.Markdown || h.FrontMatter
+	case ".gmi", ".gemini":
+		return h.FrontMatter || h.Strict || h.TOC > 0
+	}
+	return false
+}
+
+// content returns the converted content of the open file name, which is
+// cached until the file's modification time or size changes.
+func (h *FileHandler) content(name string, f fs.File) (contentEntry, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return contentEntry{}, err
+	}
+	h.mu.Lock()
+	e, ok := h.cache[name]
+	h.mu.Unlock()
+	if ok && e.modTime.Equal(fi.ModTime()) && e.size == fi.Size() {
+		return e, nil
+	}
+	src, err := io.ReadAll(f)
+	if err != nil {
+		return contentEntry{}, err
+	}
+	e = contentEntry{modTime: fi.ModTime(), size: fi.Size(), body: src}
+	if h.FrontMatter {
+		// Files with malformed front matter are served unchanged.
+		if meta, body, err := gemtext.SplitFrontMatter(src); err == nil {
+			e.meta, e.body = meta, body
+		}
+	}
+	markdown := strings.EqualFold(path.Ext(name), ".md")
+	if h.Markdown && markdown {
+		e.body = gemtext.FromMarkdown(e.body)
+	}
+	if h.TOC > 0 && (h.Markdown || !markdown) {
+		e.body = insertTOC(e.body, h.TOC)
+	}
+	if h.Strict && (h.Markdown || !markdown) {
+		e.issues, _ = (&gemtext.Linter{}).Lint(bytes.NewReader(e.body))
+	}
+	h.mu.Lock()
+	if h.cache == nil {
+		h.cache = make(map[string]contentEntry)
+	}
+	h.cache[name] = e
+	h.mu.Unlock()
+	return e, nil
+}
+
+// insertTOC inserts the table of contents of the gemtext body after its
+// first heading if it has at least min headings.
+func insertTOC(body []byte, min int) []byte {
+	doc, err := gemtext.Parse(bytes.NewReader(body))
+	if err != nil {
+		return body
+	}
+	toc := gemtext.BuildTOC(doc)
+	if toc.Len() < min {
+		return body
+	}
+	pre, off := false, 0
+	for _, line := range bytes.SplitAfter(body, []byte("\n")) {
+		off += len(line)
+		if bytes.HasPrefix(line, []byte("```")) {
+			pre = !pre
+		} else if !pre && bytes.HasPrefix(line, []byte("#")) {
+			break
+		}
+	}
+	out := append([]byte(nil), body[:off]...)
+	if !bytes.HasSuffix(out, []byte("\n")) {
+		out = append(out, '\n')
+	}
+	out = append(out, toc.Document().String()...)
+	return append(out, body[off:]...)
+}
+
+// published reports whether content with meta may be served to r now.
+func (h *FileHandler) published(r *Request, meta gemtext.Metadata) bool {
+	if hasFingerprint(r, h.Preview) {
+		return true
+	}
+	now := time.Now
+	if h.Now != nil {
+		now = h.Now
+	}
+	return !meta.Draft && !meta.Date.After(now())
+}
+
+// hasFingerprint reports whether r was sent with a client certificate
+// whose fingerprint is one of fps.
+func hasFingerprint(r *Request, fps []string) bool {
+	cert := r.Certificate()
+	if cert == nil || len(fps) == 0 {
+		return false
+	}
+	fp := Fingerprint(cert)
+	for _, p := range fps {
+		if strings.EqualFold(p, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// listed reports whether the file name is shown in directory listings
+// sent to r.
+func (h *FileHandler) listed(r *Request, name string) bool {
+	if !h.FrontMatter || !h.isContent(name) {
+		return true
+	}
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	e, err := h.content(name, f)
+	return err == nil && h.published(r, e.meta)
+}
+
+func (h *FileHandler) mimeTypes() *MIMETypes {
+	if h.MIMETypes != nil {
+		return h.MIMETypes
+	}
+	return DefaultMIMETypes
+}
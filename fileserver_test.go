@@ -0,0 +1,297 @@
+package gemini_test
+
+import (
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		full := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(full), 0755))
+		require.NoError(t, os.WriteFile(full, []byte(content), 0644))
+	}
+	return root
+}
+
+func TestFileServer(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"index.gmi":        "# Home",
+		"docs/index.gmi":   "# Docs",
+		"docs/guide.txt":   "guide",
+		"empty/readme.gmi": "nothing",
+	})
+	outside := filepath.Join(filepath.Dir(root), "secret.gmi")
+	require.NoError(t, os.WriteFile(outside, []byte("secret"), 0644))
+	defer os.Remove(outside)
+	fsrv := gemini.FileServer(root)
+
+	w := serve(t, fsrv, "gemini://localhost/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini; charset=utf-8", w.meta)
+	require.Equal(t, "# Home", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/docs/")
+	require.Equal(t, "# Docs", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/docs")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "./docs/", w.meta)
+
+	w = serve(t, fsrv, "gemini://localhost/docs/guide.txt")
+	require.Equal(t, "guide", w.body.String())
+	require.Contains(t, w.meta, "text/plain")
+
+	for _, p := range []string{"/missing.gmi", "/empty/"} {
+		w = serve(t, fsrv, "gemini://localhost"+p)
+		require.Equal(t, gemini.StatusNotFound, w.status, p)
+	}
+	for _, p := range []string{"/../secret.gmi", "/docs/../../secret.gmi", "/%2e%2e/secret.gmi", "/docs/..%5C..%5Csecret.gmi"} {
+		w = serve(t, fsrv, "gemini://localhost"+p)
+		require.Equal(t, gemini.StatusBadRequest, w.status, p)
+	}
+}
+
+func TestFileServerMountRedirect(t *testing.T) {
+	root := writeTree(t, map[string]string{"sub/index.gmi": "# Sub"})
+	mux := gemini.NewServeMux()
+	mux.Mount("/files/", gemini.FileServer(root))
+
+	w := serve(t, mux, "gemini://localhost/files/sub")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	base, err := url.Parse("gemini://localhost/files/sub")
+	require.NoError(t, err)
+	ref, err := url.Parse(w.meta)
+	require.NoError(t, err)
+	target := base.ResolveReference(ref).String()
+	require.Equal(t, "gemini://localhost/files/sub/", target)
+	w = serve(t, mux, target)
+	require.Equal(t, "# Sub", w.body.String())
+}
+
+func TestFileServerConfineSymlinks(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"capsule/index.gmi":  "# Home",
+		"capsule/docs/a.gmi": "a",
+		"secret/key.gmi":     "secret",
+	})
+	capsule := filepath.Join(root, "capsule")
+	require.NoError(t, os.Symlink(filepath.Join(root, "secret"), filepath.Join(capsule, "leak")))
+	require.NoError(t, os.Symlink("docs", filepath.Join(capsule, "alias")))
+	fsrv := gemini.FileServer(capsule)
+
+	w := serve(t, fsrv, "gemini://localhost/leak/key.gmi")
+	require.Equal(t, "secret", w.body.String())
+
+	fsrv.ConfineSymlinks = true
+	w = serve(t, fsrv, "gemini://localhost/leak/key.gmi")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+	w = serve(t, fsrv, "gemini://localhost/alias/a.gmi")
+	require.Equal(t, "a", w.body.String())
+}
+
+func TestFileServerListing(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"pub/a file.gmi":     "a",
+		"pub/big.bin":        strings.Repeat("x", 2048),
+		"pub/.hidden":        "h",
+		"pub/sub/x.txt":      "x",
+		"private/.nolisting": "",
+		"private/secret.gmi": "s",
+	})
+	fsrv := gemini.FileServer(root)
+
+	w := serve(t, fsrv, "gemini://localhost/pub/")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+
+	fsrv.Listing = true
+	w = serve(t, fsrv, "gemini://localhost/pub/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini", w.meta)
+	require.Equal(t, "# Index of /pub/\n\n"+
+		"=> ../ Parent directory\n"+
+		"=> a%20file.gmi a file.gmi (1 B)\n"+
+		"=> big.bin big.bin (2.0 KiB)\n"+
+		"=> sub/ sub/\n", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/private/")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+}
+
+func TestFileServerFS(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"index.gmi":      {Data: []byte("# Embedded")},
+		"img/logo.png":   {Data: []byte("png")},
+		"img/.nolisting": {},
+	})
+
+	w := serve(t, fsrv, "gemini://localhost/")
+	require.Equal(t, "text/gemini; charset=utf-8", w.meta)
+	require.Equal(t, "# Embedded", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/img/logo.png")
+	require.Equal(t, "image/png", w.meta)
+	require.Equal(t, "png", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/img/")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+}
+
+func TestFileServerMIMETypes(t *testing.T) {
+	types := gemini.NewMIMETypes()
+	types.Add(".GMI", "text/gemini; lang=de")
+	types.Add(".log", "text/plain")
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"index.gmi":  {Data: []byte("# Hallo")},
+		"server.log": {Data: []byte("ok")},
+		"blob.xyz1":  {Data: []byte{0}},
+	})
+	fsrv.MIMETypes = types
+
+	require.Equal(t, "text/gemini; lang=de", serve(t, fsrv, "gemini://localhost/").meta)
+	require.Equal(t, "text/plain", serve(t, fsrv, "gemini://localhost/server.log").meta)
+	require.Equal(t, "application/octet-stream", serve(t, fsrv, "gemini://localhost/blob.xyz1").meta)
+	require.Equal(t, "text/gemini; charset=utf-8", gemini.TypeByExtension(".Gemini"))
+	require.Panics(t, func() { types.Add(".bad", "not a type") })
+	require.Equal(t, ".log", types.ExtensionByType("text/plain; charset=utf-8"))
+	require.Equal(t, ".gmi", gemini.DefaultMIMETypes.ExtensionByType("text/gemini"))
+}
+
+func TestFileServerMarkdown(t *testing.T) {
+	root := writeTree(t, map[string]string{
+		"docs/index.md": "Intro\n=====",
+		"post.md":       "## Post\n\nSee [home](/).",
+	})
+	fsrv := gemini.FileServer(root)
+
+	w := serve(t, fsrv, "gemini://localhost/post.md")
+	require.Equal(t, "## Post\n\nSee [home](/).", w.body.String())
+
+	fsrv.Markdown = true
+	w = serve(t, fsrv, "gemini://localhost/post.md")
+	require.Equal(t, "text/gemini; charset=utf-8", w.meta)
+	require.Equal(t, "## Post\n\nSee home.\n=> / home\n", w.body.String())
+
+	w = serve(t, fsrv, "gemini://localhost/docs/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Contains(t, w.body.String(), "Intro")
+
+	later := time.Now().Add(time.Hour)
+	name := filepath.Join(root, "post.md")
+	require.NoError(t, os.WriteFile(name, []byte("# Edited"), 0644))
+	require.NoError(t, os.Chtimes(name, later, later))
+	w = serve(t, fsrv, "gemini://localhost/post.md")
+	require.Equal(t, "# Edited\n", w.body.String())
+}
+
+func TestFileServerFrontMatter(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"post.gmi": {Data: []byte("---\ntitle: Post\n---\n# Post\n")},
+		"note.md":  {Data: []byte("+++\ntitle = \"Note\"\n+++\nSome *note*.\n")},
+		"bad.gmi":  {Data: []byte("---\nunterminated\n")},
+	})
+	fsrv.FrontMatter = true
+	fsrv.Markdown = true
+
+	require.Equal(t, "# Post\n", serve(t, fsrv, "gemini://localhost/post.gmi").body.String())
+	require.Equal(t, "Some note.\n", serve(t, fsrv, "gemini://localhost/note.md").body.String())
+	require.Equal(t, "---\nunterminated\n", serve(t, fsrv, "gemini://localhost/bad.gmi").body.String())
+}
+
+func TestFileServerScheduledPublishing(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"now.gmi":   {Data: []byte("---\ndate: 2024-01-01\n---\nnow")},
+		"later.gmi": {Data: []byte("---\ndate: 2024-06-01T12:00:00Z\n---\nlater")},
+		"plain.gmi": {Data: []byte("plain")},
+		"notes.txt": {Data: []byte("txt")},
+		"index.md":  {Data: []byte("+++\ndate = 2030-01-01\n+++\n")},
+	})
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	fsrv.FrontMatter = true
+	fsrv.Listing = true
+	fsrv.Now = func() time.Time { return now }
+
+	require.Equal(t, "now", serve(t, fsrv, "gemini://localhost/now.gmi").body.String())
+	require.Equal(t, gemini.StatusNotFound, serve(t, fsrv, "gemini://localhost/later.gmi").status)
+	w := serve(t, fsrv, "gemini://localhost/")
+	require.NotContains(t, w.body.String(), "later.gmi")
+	require.NotContains(t, w.body.String(), "index.md")
+	require.Contains(t, w.body.String(), "=> now.gmi")
+	require.Contains(t, w.body.String(), "=> notes.txt")
+
+	now = now.Add(24 * time.Hour)
+	require.Equal(t, "later", serve(t, fsrv, "gemini://localhost/later.gmi").body.String())
+	require.Contains(t, serve(t, fsrv, "gemini://localhost/").body.String(), "=> later.gmi")
+}
+
+func TestFileServerPreview(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"draft.gmi":  {Data: []byte("---\ndraft: true\n---\ndraft")},
+		"future.gmi": {Data: []byte("---\ndate: 2999-01-01\n---\nfuture")},
+	})
+	fsrv.FrontMatter = true
+	fsrv.Listing = true
+	fsrv.Preview = []string{testcert.Client().Fingerprint()}
+	addr := startServer(t, &gemini.Server{Handler: fsrv})
+
+	header, _ := roundTrip(t, addr, "gemini://localhost/draft.gmi")
+	require.Equal(t, "51 404 Resource Not Found\r\n", header)
+	header, _ = roundTrip(t, addr, "gemini://localhost/future.gmi", testcert.ExpiredClient().TLSCertificate())
+	require.Equal(t, "51 404 Resource Not Found\r\n", header)
+	_, body := roundTrip(t, addr, "gemini://localhost/")
+	require.NotContains(t, body, "draft.gmi")
+
+	header, body = roundTrip(t, addr, "gemini://localhost/draft.gmi", testcert.Client().TLSCertificate())
+	require.Equal(t, "20 text/gemini; charset=utf-8\r\n", header)
+	require.Equal(t, "draft", body)
+	_, body = roundTrip(t, addr, "gemini://localhost/", testcert.Client().TLSCertificate())
+	require.Contains(t, body, "=> draft.gmi")
+	require.Contains(t, body, "=> future.gmi")
+}
+
+func TestFileServerStrict(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"ok.gmi":   {Data: []byte("# OK\n```\npre\n```\n")},
+		"bad.gmi":  {Data: []byte("# Bad\n=>\n")},
+		"open.md":  {Data: []byte("```\ncode\n")},
+		"data.txt": {Data: []byte("=>\n")},
+	})
+	fsrv.Strict = true
+
+	require.Equal(t, "# OK\n```\npre\n```\n", serve(t, fsrv, "gemini://localhost/ok.gmi").body.String())
+	w := serve(t, fsrv, "gemini://localhost/bad.gmi")
+	require.Equal(t, gemini.StatusGeneralPermFail, w.status)
+	require.Equal(t, "Invalid gemtext: 2:1: link line without URL", w.meta)
+	require.Equal(t, "=>\n", serve(t, fsrv, "gemini://localhost/data.txt").body.String())
+	require.Equal(t, gemini.StatusSuccess, serve(t, fsrv, "gemini://localhost/open.md").status)
+
+	// FromMarkdown closes open code fences.
+	fsrv = gemini.FileServerFS(fstest.MapFS{"open.md": {Data: []byte("```\ncode\n")}})
+	fsrv.Strict = true
+	fsrv.Markdown = true
+	require.Equal(t, gemini.StatusSuccess, serve(t, fsrv, "gemini://localhost/open.md").status)
+}
+
+func TestFileServerTOC(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{
+		"long.gmi":  {Data: []byte("```\n# pre\n```\n# Title\n## One\ntext\n## Two")},
+		"short.gmi": {Data: []byte("# Title\n## One\n")},
+	})
+	fsrv.TOC = 3
+
+	require.Equal(t, "```\n# pre\n```\n# Title\n"+
+		"=> #title 1 Title\n=> #one 1.1 One\n=> #two 1.2 Two\n"+
+		"## One\ntext\n## Two", serve(t, fsrv, "gemini://localhost/long.gmi").body.String())
+	require.Equal(t, "# Title\n## One\n", serve(t, fsrv, "gemini://localhost/short.gmi").body.String())
+}
@@ -0,0 +1,155 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FileStore is a handler for a writable area of a capsule: titan uploads
+// are stored as files below a directory and gemini requests are served
+// from it by the embedded FileHandler. Create it with NewFileStore:
+//
+//	store := gemini.NewFileStore("/var/gemini/uploads")
+//	store.Prefix = "/uploads"
+//	store.MaxSize = 10 << 20
+//	store.Tokens = gemini.StaticTokens{secret}
+//	mux.Mount("/uploads/", store)
+//
+// Uploads are written to a temporary file renamed into place once
+// complete, so readers never see partial files. An upload to a path
+// without an extension gets the extension of its MIME type; an upload to
+// a path whose extension has a different type is answered with 59.
+// Uploads declaring size=0 delete the file. Paths of directories and
+// with segments starting with a dot are answered with 59, as dot files
+// are used for the store's temporary files and listing configuration.
+// Successful uploads and deletes are answered with a redirect to the
+// gemini URL of the file or its directory.
+type FileStore struct {
+	*FileHandler
+
+	// Prefix is the path the store is mounted at, used in redirects.
+	Prefix string
+
+	// MaxSize limits the size of uploads. Zero means no limit.
+	MaxSize int64
+
+	// Types optionally lists the MIME types that may be uploaded, see
+	// UploadPolicy.
+	Types []string
+
+	// Tokens, if set, authorizes uploads by their token parameter.
+	Tokens TokenValidator
+
+	dir string
+}
+
+// NewFileStore returns a FileStore for the directory dir.
+func NewFileStore(dir string) *FileStore {
+	return &FileStore{FileHandler: FileServer(dir), dir: dir}
+}
+
+// ServeTitan stores the upload. Gemini requests are served by the
+// FileHandler's ServeGemini.
+func (s *FileStore) ServeTitan(w ResponseWriter, r *Request) {
+	if s.Tokens != nil {
+		if err := s.Tokens.ValidateToken(s.Prefix+r.URL.Path, r.Titan.Token); err != nil {
+			w.WriteStatusMsg(StatusBadRequest, Message(r, err.Error()))
+			return
+		}
+	}
+	if err := checkUpload(r, s.MaxSize, s.Types); err != nil {
+		rejectUpload(w, r, err, s.MaxSize)
+		return
+	}
+	if r.Titan.Edit {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	name := path.Clean("/" + r.URL.Path)
+	if name == "/" || strings.HasSuffix(r.URL.Path, "/") || hasDotDot(r.URL.Path) || strings.Contains(name, "/.") {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	file := filepath.Join(s.dir, filepath.FromSlash(name))
+
+	if r.Titan.IsDelete() {
+		if err := os.Remove(file); err != nil {
+			fileError(w, r, err)
+			return
+		}
+		dir := path.Dir(name)
+		if dir != "/" {
+			dir += "/"
+		}
+		s.redirect(w, r, dir)
+		return
+	}
+
+	mt := uploadType(r)
+	if ext := path.Ext(name); ext == "" {
+		name += s.mimeTypes().ExtensionByType(mt)
+		file = filepath.Join(s.dir, filepath.FromSlash(name))
+	} else if typ := s.mimeTypes().TypeByExtension(ext); typ != "application/octet-stream" && !sameMediaType(typ, mt) {
+		w.WriteStatusMsg(StatusBadRequest, messagef(r, "Upload type %s not allowed", mt))
+		return
+	}
+	if err := s.store(file, r); err != nil {
+		if errors.Is(err, ErrChecksumMismatch) || errors.Is(err, io.ErrUnexpectedEOF) {
+			w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+			return
+		}
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	s.redirect(w, r, name)
+}
+
+// store writes the upload to a temporary file in the directory of file
+// and renames it into place.
+func (s *FileStore) store(file string, r *Request) error {
+	dir := filepath.Dir(file)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	n, err := io.Copy(tmp, r.Titan.Reader())
+	if err == nil && n < r.Titan.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if c, ok := r.Titan.Body.(*checksumReader); ok && err == nil {
+		err = c.err
+	}
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func (s *FileStore) redirect(w ResponseWriter, r *Request, name string) {
+	u := *r.URL
+	u.Scheme = SchemaGemini
+	u.Path = s.Prefix + name
+	u.RawPath = ""
+	Redirect(w, u.String(), false)
+}
+
+func (s *FileStore) mimeTypes() *MIMETypes {
+	if s.MIMETypes != nil {
+		return s.MIMETypes
+	}
+	return DefaultMIMETypes
+}
@@ -0,0 +1,56 @@
+package gemini_test
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	store := gemini.NewFileStore(dir)
+	store.Prefix = "/files"
+	store.MaxSize = 16
+	store.Tokens = gemini.StaticTokens{"secret"}
+	store.Listing = true
+	mux := gemini.NewServeMux()
+	mux.Mount("/files/", store)
+	addr := startServer(t, &gemini.Server{Handler: mux})
+	upload := func(path, params, body string) string {
+		t.Helper()
+		header, _ := roundTrip(t, addr, "titan://localhost/files"+path+";token=secret;size="+strconv.Itoa(len(body))+params+"\r\n"+body)
+		return header
+	}
+
+	require.Equal(t, "30 gemini://localhost/files/notes/a.gmi\r\n", upload("/notes/a.gmi", "", "# A\n"))
+	header, body := roundTrip(t, addr, "gemini://localhost/files/notes/a.gmi")
+	require.Equal(t, "20 text/gemini; charset=utf-8\r\n", header)
+	require.Equal(t, "# A\n", body)
+
+	require.Equal(t, "30 gemini://localhost/files/b.gmi\r\n", upload("/b", ";mime=text/gemini", "# B\n"))
+	require.Equal(t, "30 gemini://localhost/files/c.txt\r\n", upload("/c.txt", ";mime=text/plain", "c"))
+	require.Equal(t, "59 Upload type text/plain not allowed\r\n", upload("/d.gmi", ";mime=text/plain", "d"))
+	require.Equal(t, "59 Upload exceeds 16 bytes\r\n", upload("/big.txt", ";mime=text/plain", "seventeen bytes!!"))
+	require.Equal(t, "59 Bad Request\r\n", upload("/.nolisting", "", "x"))
+	require.Equal(t, "59 Bad Request\r\n", upload("/notes/", "", "x"))
+	header, _ = roundTrip(t, addr, "titan://localhost/files/e.gmi;token=wrong;size=1\r\nx")
+	require.Equal(t, "59 Invalid Token\r\n", header)
+
+	require.Equal(t, "30 gemini://localhost/files/notes/\r\n", upload("/notes/a.gmi", "", ""))
+	_, err := os.Stat(filepath.Join(dir, "notes", "a.gmi"))
+	require.True(t, os.IsNotExist(err))
+	require.Equal(t, "51 404 Resource Not Found\r\n", upload("/notes/a.gmi", "", ""))
+	require.Equal(t, "30 gemini://localhost/files/\r\n", upload("/c.txt", "", ""))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	require.Equal(t, []string{"b.gmi", "notes"}, names)
+}
@@ -0,0 +1,155 @@
+package gemini
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Form asks the client for several inputs in turn, one 10 INPUT prompt
+// per request, and passes the collected values to Done:
+//
+//	signup := &gemini.Form{
+//		Key: key,
+//		Fields: []gemini.FormField{
+//			{Name: "name", Prompt: "Your name"},
+//			{Name: "age", Prompt: "Your age", Validate: validAge},
+//		},
+//		Done: func(w gemini.ResponseWriter, r *gemini.Request, values url.Values) {
+//			...
+//		},
+//	}
+//	mux.Handle("/signup/", signup)
+//
+// The values answered so far travel in the last path segment, as a token
+// signed with Key, and each answer is redirected to the URL holding the
+// next token. Since the token is readable by the client and may end up in
+// its history, sensitive values should not be asked for in a form.
+type Form struct {
+	// Key signs the form state. It must be set.
+	Key []byte
+
+	Fields []FormField
+
+	// Done is called with the values once all fields are answered.
+	Done func(w ResponseWriter, r *Request, values url.Values)
+
+	// TTL is how long a started form may be continued. Defaults to an
+	// hour.
+	TTL time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// FormField is an input asked for by a Form.
+type FormField struct {
+	Name   string
+	Prompt string
+
+	// Validate optionally checks an answer. Rejected answers are asked
+	// for again with the error.
+	Validate func(value string) error
+}
+
+// ServeGemini implements Handler.
+func (f *Form) ServeGemini(w ResponseWriter, r *Request) {
+	dir, token := r.URL.Path, ""
+	if i := strings.LastIndexByte(dir, '/'); i >= 0 {
+		dir, token = dir[:i+1], dir[i+1:]
+	}
+	values := url.Values{}
+	if token != "" {
+		var ok bool
+		if values, ok = f.decode(token); !ok {
+			w.WriteStatusMsg(StatusBadRequest, Message(r, "Form expired"))
+			return
+		}
+	}
+	step := 0
+	for step < len(f.Fields) && values.Get(f.Fields[step].Name) != "" {
+		step++
+	}
+	if step == len(f.Fields) {
+		f.Done(w, r, values)
+		return
+	}
+	field := f.Fields[step]
+	if r.URL.RawQuery == "" {
+		w.WriteStatusMsg(StatusPlainInput, field.Prompt)
+		return
+	}
+	answer, err := r.Query()
+	if err != nil || answer == "" {
+		w.WriteStatusMsg(StatusPlainInput, field.Prompt)
+		return
+	}
+	if field.Validate != nil {
+		if err := field.Validate(answer); err != nil {
+			w.WriteStatusMsg(StatusPlainInput, err.Error()+". "+field.Prompt)
+			return
+		}
+	}
+	values.Set(field.Name, answer)
+	w.WriteStatusMsg(StatusTemporaryRedirect, dir+f.encode(values))
+}
+
+// encode returns a token holding values, of the form
+// "<base64 payload>.<base64 HMAC-SHA256 of the payload>".
+func (f *Form) encode(values url.Values) string {
+	values.Set("_exp", strconv.FormatInt(f.now().Add(f.ttl()).Unix(), 10))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(values.Encode()))
+	values.Del("_exp")
+	return payload + "." + base64.RawURLEncoding.EncodeToString(f.sum(payload))
+}
+
+// decode returns the values of a valid token.
+func (f *Form) decode(token string) (url.Values, bool) {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return nil, false
+	}
+	payload := token[:i]
+	mac, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(mac, f.sum(payload)) {
+		return nil, false
+	}
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, false
+	}
+	values, err := url.ParseQuery(string(data))
+	if err != nil {
+		return nil, false
+	}
+	exp, err := strconv.ParseInt(values.Get("_exp"), 10, 64)
+	if err != nil || !f.now().Before(time.Unix(exp, 0)) {
+		return nil, false
+	}
+	values.Del("_exp")
+	return values, true
+}
+
+func (f *Form) sum(payload string) []byte {
+	m := hmac.New(sha256.New, f.Key)
+	m.Write([]byte(payload))
+	return m.Sum(nil)
+}
+
+func (f *Form) ttl() time.Duration {
+	if f.TTL <= 0 {
+		return time.Hour
+	}
+	return f.TTL
+}
+
+func (f *Form) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+	return time.Now()
+}
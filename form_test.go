@@ -0,0 +1,68 @@
+package gemini_test
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForm(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	form := &gemini.Form{
+		Key: []byte("secret"),
+		Fields: []gemini.FormField{
+			{Name: "name", Prompt: "Your name"},
+			{Name: "age", Prompt: "Your age", Validate: func(v string) error {
+				if strings.Trim(v, "0123456789") != "" {
+					return errors.New("Not a number")
+				}
+				return nil
+			}},
+		},
+		Done: func(w gemini.ResponseWriter, r *gemini.Request, values url.Values) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+			w.WriteBody([]byte(values.Get("name") + " is " + values.Get("age") + "\n"))
+		},
+		TTL: time.Hour,
+		Now: func() time.Time { return now },
+	}
+	mux := gemini.NewServeMux()
+	mux.Handle("/signup/", form)
+
+	w := serve(t, mux, "gemini://localhost/signup/")
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Equal(t, "Your name", w.meta)
+
+	w = serve(t, mux, "gemini://localhost/signup/?Ada%20Lovelace")
+	require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+	require.True(t, strings.HasPrefix(w.meta, "/signup/"), w.meta)
+	step := "gemini://localhost" + w.meta
+
+	w = serve(t, mux, step)
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Equal(t, "Your age", w.meta)
+	w = serve(t, mux, step+"?old")
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+	require.Equal(t, "Not a number. Your age", w.meta)
+
+	w = serve(t, mux, step+"?36")
+	require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+	done := "gemini://localhost" + w.meta
+	w = serve(t, mux, done)
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "Ada Lovelace is 36\n", w.body.String())
+
+	tampered := []byte(done)
+	tampered[len("gemini://localhost/signup/")] ^= 1
+	w = serve(t, mux, string(tampered))
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Form expired", w.meta)
+
+	now = now.Add(time.Hour)
+	require.Equal(t, "Form expired", serve(t, mux, done).meta)
+}
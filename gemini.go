@@ -4,9 +4,8 @@ import (
 	"bytes"
 	"errors"
 	"io"
-	"log"
-	"os"
-	"runtime/debug"
+	"net/url"
+	"strings"
 )
 
 // StatusCode is Gemini status codes as defined in the Gemini spec.
@@ -57,39 +56,82 @@ func (f HandlerFunc) ServeGemini(w ResponseWriter, r *Request) {
 	f(w, r)
 }
 
-func NotFound(w ResponseWriter, req *Request) {
-	w.WriteStatusMsg(StatusNotFound, "404 Resource Not Found")
+// TitanHandler is implemented by handlers accepting titan:// uploads.
+// ServeMux and Server pass titan requests to ServeTitan, so upload logic
+// can live apart from the handler's ServeGemini, which serves reads.
+type TitanHandler interface {
+	ServeTitan(ResponseWriter, *Request)
+}
+
+// TitanHandlerFunc adapts a function to a TitanHandler. As a Handler it
+// only supports titan requests: gemini requests are answered with 59.
+type TitanHandlerFunc func(ResponseWriter, *Request)
+
+// ServeTitan calls f(w, r).
+func (f TitanHandlerFunc) ServeTitan(w ResponseWriter, r *Request) {
+	f(w, r)
+}
+
+// ServeGemini answers r with 59.
+func (f TitanHandlerFunc) ServeGemini(w ResponseWriter, r *Request) {
+	w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
 }
 
-func TrapPanic(next HandlerFunc) HandlerFunc {
-	return func(w ResponseWriter, req *Request) {
-		defer func() {
-			if r := recover(); r != nil {
-				log.Printf("Trapped: %v", r)
-				debug.PrintStack()
-				w.WriteStatusMsg(StatusUnspecified, "Internal Server Error")
-			}
-		}()
-		next(w, req)
+// serveTitan serves the titan request r with h, answering it with 59 if h
+// does not implement TitanHandler.
+func serveTitan(h Handler, w ResponseWriter, r *Request) {
+	if th, ok := h.(TitanHandler); ok {
+		th.ServeTitan(w, r)
+		return
 	}
+	w.WriteStatusMsg(StatusBadRequest, Message(r, "Titan requests not supported"))
 }
 
-func ServeFile(file *os.File, mimeType string) HandlerFunc {
-	return func(w ResponseWriter, r *Request) {
-		w.WriteStatusMsg(StatusSuccess, mimeType)
-		rw := w.(*response)
-		_, _ = io.Copy(rw, file)
+func NotFound(w ResponseWriter, req *Request) {
+	w.WriteStatusMsg(StatusNotFound, Message(req, "404 Resource Not Found"))
+}
+
+// Redirect replies to the request with a redirect to url, which may be
+// relative to the request URL.
+func Redirect(w ResponseWriter, url string, permanent bool) error {
+	if permanent {
+		return w.WriteStatusMsg(StatusPermanentRedirect, url)
 	}
+	return w.WriteStatusMsg(StatusTemporaryRedirect, url)
 }
 
-func ServeFileName(name string, mimeType string) HandlerFunc {
-	return func(w ResponseWriter, r *Request) {
-		f, err := os.Open(name)
+// RedirectAfterUpload replies to a titan request with a temporary
+// redirect to the gemini:// URL of ref, resolved against the uploaded
+// resource, so the client shows the result of the edit. An empty ref
+// redirects to the resource itself and "./" to its directory, as after a
+// delete.
+func RedirectAfterUpload(w ResponseWriter, r *Request, ref string) error {
+	u := r.GeminiURL()
+	if ref != "" {
+		rel, err := url.Parse(ref)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		ServeFile(f, mimeType)(w, r)
+		u = u.ResolveReference(rel)
 	}
+	return w.WriteStatusMsg(StatusTemporaryRedirect, u.String())
+}
+
+// Input asks the client for a line of text to be sent as the query of a
+// follow up request. Sensitive input should not be echoed by the client.
+func Input(w ResponseWriter, prompt string, sensitive bool) error {
+	if sensitive {
+		return w.WriteStatusMsg(StatusSensitiveInput, prompt)
+	}
+	return w.WriteStatusMsg(StatusPlainInput, prompt)
+}
+
+// Error replies to the request with the specified failure code and
+// message. Line breaks in msg are replaced with spaces to keep the status
+// line well formed.
+func Error(w ResponseWriter, code StatusCode, msg string) error {
+	msg = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ").Replace(msg)
+	return w.WriteStatusMsg(code, msg)
 }
 
 var errorRequestTooLong = errors.New("request exceeds 1024 length")
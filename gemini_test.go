@@ -0,0 +1,314 @@
+package gemini_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseHelpers(t *testing.T) {
+	w := &recorder{}
+	require.NoError(t, gemini.Redirect(w, "/new", true))
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "/new", w.meta)
+
+	require.NoError(t, gemini.Redirect(w, "/tmp", false))
+	require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+
+	require.NoError(t, gemini.Input(w, "Password", true))
+	require.Equal(t, gemini.StatusSensitiveInput, w.status)
+	require.Equal(t, "Password", w.meta)
+
+	require.NoError(t, gemini.Input(w, "Search", false))
+	require.Equal(t, gemini.StatusPlainInput, w.status)
+
+	require.NoError(t, gemini.Error(w, gemini.StatusGone, "moved\r\naway"))
+	require.Equal(t, gemini.StatusGone, w.status)
+	require.Equal(t, "moved away", w.meta)
+}
+
+type geoTable map[string]string
+
+func (g geoTable) Country(ip net.IP) (string, error) {
+	return g[ip.String()], nil
+}
+
+func TestCountryPolicy(t *testing.T) {
+	loc := geoTable{"192.0.2.1": "NZ", "192.0.2.2": "XX"}
+	check := func(mw gemini.Middleware, remote string) gemini.StatusCode {
+		u, _ := url.Parse("gemini://localhost/")
+		w := &recorder{}
+		mw(text("ok")).ServeGemini(w, &gemini.Request{URL: u, RemoteAddr: remote})
+		return w.status
+	}
+
+	deny := gemini.CountryPolicy(loc, nil, []string{"xx"})
+	require.Equal(t, gemini.StatusSuccess, check(deny, "192.0.2.1:5000"))
+	require.Equal(t, gemini.StatusGeneralPermFail, check(deny, "192.0.2.2:5000"))
+	require.Equal(t, gemini.StatusSuccess, check(deny, "198.51.100.1:5000"))
+
+	allow := gemini.CountryPolicy(loc, []string{"NZ"}, nil)
+	require.Equal(t, gemini.StatusSuccess, check(allow, "192.0.2.1:5000"))
+	require.Equal(t, gemini.StatusGeneralPermFail, check(allow, "192.0.2.2:5000"))
+	require.Equal(t, gemini.StatusGeneralPermFail, check(allow, "198.51.100.1:5000"))
+}
+
+func TestTarpit(t *testing.T) {
+	var flagged []string
+	tp := &gemini.Tarpit{
+		Delay:    time.Millisecond,
+		Duration: 20 * time.Millisecond,
+		Flag:     func(r *gemini.Request) { flagged = append(flagged, r.URL.Path) },
+	}
+	w := serve(t, tp, "gemini://localhost/wp-admin")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.NotZero(t, w.body.Len())
+	require.Equal(t, []string{"/wp-admin"}, flagged)
+}
+
+type failingWriter struct {
+	recorder
+	limit int
+}
+
+func (w *failingWriter) WriteBody(b []byte) (int, error) {
+	if w.body.Len()+len(b) > w.limit {
+		n, _ := w.recorder.WriteBody(b[:w.limit-w.body.Len()])
+		return n, errors.New("connection reset")
+	}
+	return w.recorder.WriteBody(b)
+}
+
+func TestCopyBody(t *testing.T) {
+	content := strings.Repeat("x", 100*1024)
+
+	w := &recorder{}
+	n, err := gemini.CopyBody(context.Background(), w, strings.NewReader(content))
+	require.NoError(t, err)
+	require.Equal(t, int64(len(content)), n)
+
+	fw := &failingWriter{limit: 40000}
+	n, err = gemini.CopyBody(context.Background(), fw, strings.NewReader(content))
+	var perr *gemini.PartialWriteError
+	require.True(t, errors.As(err, &perr))
+	require.Equal(t, int64(40000), n)
+	require.Equal(t, int64(40000), perr.Written)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, err = gemini.CopyBody(ctx, w, strings.NewReader(content))
+	require.True(t, errors.Is(err, context.Canceled))
+	require.Zero(t, n)
+}
+
+func TestTimeoutHandler(t *testing.T) {
+	late := make(chan error, 1)
+	slow := gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		<-r.Context().Done()
+		late <- w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	})
+	w := serve(t, gemini.TimeoutHandler(slow, 10*time.Millisecond, "Too Slow"), "gemini://localhost/")
+	require.Equal(t, gemini.StatusServerUnavalable, w.status)
+	require.Equal(t, "Too Slow", w.meta)
+	require.Equal(t, gemini.ErrHandlerTimeout, <-late)
+
+	w = serve(t, gemini.TimeoutHandler(text("fast"), time.Second, "Too Slow"), "gemini://localhost/")
+	require.Equal(t, "fast", w.body.String())
+}
+
+// blockingWriter blocks body writes until release is closed.
+type blockingWriter struct {
+	recorder
+	release chan struct{}
+}
+
+func (w *blockingWriter) WriteBody(b []byte) (int, error) {
+	<-w.release
+	return len(b), nil
+}
+
+func TestTimeoutHandlerBlockedWrite(t *testing.T) {
+	w := &blockingWriter{release: make(chan struct{})}
+	defer close(w.release)
+	h := gemini.TimeoutHandler(gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		w.WriteBody([]byte("stuck"))
+	}), 10*time.Millisecond, "Too Slow")
+	done := make(chan struct{})
+	go func() {
+		h.ServeGemini(w, &gemini.Request{URL: &url.URL{Path: "/"}})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("timeout waited for a blocked write")
+	}
+	require.Equal(t, gemini.StatusSuccess, w.status)
+}
+
+type lockedRecorder struct {
+	mu sync.Mutex
+	failingWriter
+}
+
+func (w *lockedRecorder) WriteBody(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.failingWriter.WriteBody(b)
+}
+
+func TestHeartbeatWriter(t *testing.T) {
+	w := &lockedRecorder{failingWriter: failingWriter{limit: 3}}
+	hw, ctx := gemini.NewHeartbeatWriter(context.Background(), w, 5*time.Millisecond, []byte("\n"))
+	defer hw.Stop()
+	require.NoError(t, hw.WriteStatusMsg(gemini.StatusSuccess, "text/gemini"))
+	_, err := hw.WriteBody([]byte("a"))
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("dead client was not detected")
+	}
+	require.Error(t, hw.Err())
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	require.Equal(t, "a\n\n", w.body.String())
+}
+
+func TestHeartbeatWriterDisabled(t *testing.T) {
+	for _, interval := range []time.Duration{-time.Second, 0, 1} {
+		w := &lockedRecorder{failingWriter: failingWriter{limit: 1 << 20}}
+		hw, ctx := gemini.NewHeartbeatWriter(context.Background(), w, interval, []byte("\n"))
+		require.NoError(t, hw.WriteStatusMsg(gemini.StatusSuccess, "text/gemini"))
+		_, err := hw.WriteBody([]byte("a"))
+		require.NoError(t, err)
+		hw.Stop()
+		<-ctx.Done()
+		if interval <= 0 {
+			w.mu.Lock()
+			require.Equal(t, "a", w.body.String())
+			w.mu.Unlock()
+		}
+	}
+}
+
+// countingWriter is a middleware style wrapper counting body bytes.
+type countingWriter struct {
+	gemini.ResponseWriter
+	n int
+}
+
+func (c *countingWriter) WriteBody(b []byte) (int, error) {
+	n, err := c.ResponseWriter.WriteBody(b)
+	c.n += n
+	return n, err
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) { return c.WriteBody(b) }
+
+func (c *countingWriter) Unwrap() gemini.ResponseWriter { return c.ResponseWriter }
+
+type flushRecorder struct {
+	recorder
+	flushed bool
+}
+
+func (f *flushRecorder) Flush() error {
+	f.flushed = true
+	return nil
+}
+
+func TestWrappedResponseWriter(t *testing.T) {
+	inner := &flushRecorder{}
+	cw := &countingWriter{ResponseWriter: inner}
+	_, err := io.Copy(gemini.AsWriter(cw), strings.NewReader("hello"))
+	require.NoError(t, err)
+	require.Equal(t, 5, cw.n)
+	require.Equal(t, "hello", inner.body.String())
+
+	require.NoError(t, gemini.Flush(cw))
+	require.True(t, inner.flushed)
+
+	_, err = io.Copy(gemini.AsWriter(&recorder{}), strings.NewReader("plain"))
+	require.NoError(t, err)
+}
+
+func TestStatusRecorder(t *testing.T) {
+	inner := &recorder{}
+	sr := gemini.NewStatusRecorder(inner)
+	var info gemini.ResponseInfo = sr
+	text("hello")(sr, nil)
+	require.Equal(t, gemini.StatusSuccess, info.Status())
+	require.Equal(t, "text/gemini", info.Meta())
+	require.Equal(t, int64(5), info.BytesWritten())
+	require.Equal(t, "hello", inner.body.String())
+}
+
+func TestTokenValidators(t *testing.T) {
+	static := gemini.StaticTokens{"a", "b"}
+	require.NoError(t, static.ValidateToken("/x", "b"))
+	require.Equal(t, gemini.ErrInvalidToken, static.ValidateToken("/x", "c"))
+	require.Equal(t, gemini.ErrInvalidToken, static.ValidateToken("/x", ""))
+
+	paths := gemini.PathTokens{
+		"/wiki/":      {"editor"},
+		"/wiki/admin": {"admin"},
+	}
+	require.NoError(t, paths.ValidateToken("/wiki/page", "editor"))
+	require.NoError(t, paths.ValidateToken("/wiki/admin", "admin"))
+	require.Error(t, paths.ValidateToken("/wiki/admin", "editor"))
+	require.Error(t, paths.ValidateToken("/blog/post", "editor"))
+
+	now := time.Unix(1000, 0)
+	h := &gemini.HMACTokens{Key: []byte("secret"), Now: func() time.Time { return now }}
+	token := h.Token("/upload", now.Add(time.Minute))
+	require.NoError(t, h.ValidateToken("/upload", token))
+	require.Error(t, h.ValidateToken("/other", token))
+	require.Error(t, h.ValidateToken("/upload", "1060.00"))
+	now = now.Add(time.Hour)
+	require.Error(t, h.ValidateToken("/upload", token))
+}
+
+func TestRequireToken(t *testing.T) {
+	h := gemini.RequireToken(gemini.StaticTokens{"s3cret"})(text("ok"))
+	check := func(rawurl string) *recorder {
+		r := &gemini.Request{}
+		require.NoError(t, r.Reset(nil, rawurl))
+		w := &recorder{}
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	w := check("titan://localhost/up;size=0;token=s3cret")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+
+	w = check("titan://localhost/up;size=0;token=guess")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Invalid Token", w.meta)
+
+	w = check("gemini://localhost/up")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+}
+
+func TestCountryPolicyTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", gemini.CountryPolicy(geoTable{}, nil, []string{"XX"})(readWrite{}))
+	require.Equal(t, "write /a", serve(t, mux, "titan://localhost/a").body.String())
+}
+
+func TestTimeoutHandlerTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", gemini.TimeoutHandler(readWrite{}, time.Second, "timeout"))
+	require.Equal(t, "write /a", serve(t, mux, "titan://localhost/a").body.String())
+}
@@ -0,0 +1,92 @@
+package gemtext
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Feed is a Gemini page subscribable as described in the "Subscribing to
+// Gemini pages" companion specification.
+type Feed struct {
+	Title    string
+	Subtitle string
+	Entries  []FeedEntry
+}
+
+// FeedEntry is a feed entry: a link line whose label starts with a
+// YYYY-MM-DD date.
+type FeedEntry struct {
+	URL   string
+	Date  time.Time
+	Title string
+}
+
+// ParseFeed returns the feed of doc. The title is the first level 1
+// heading and the subtitle a level 2 heading directly following it.
+// Entries are the link lines whose labels start with a date, in document
+// order; the rest of the label, without a leading dash, is the title.
+func ParseFeed(doc Document) Feed {
+	var f Feed
+	prev := Line(nil)
+	for _, l := range doc {
+		switch l := l.(type) {
+		case Heading:
+			switch {
+			case l.Level == 1 && f.Title == "":
+				f.Title = l.Text
+			case l.Level == 2 && f.Subtitle == "" && isHeading(prev, 1):
+				f.Subtitle = l.Text
+			}
+		case Link:
+			if e, ok := feedEntry(l); ok {
+				f.Entries = append(f.Entries, e)
+			}
+		}
+		// Blank lines may separate the title and subtitle.
+		if t, ok := l.(Text); !ok || strings.TrimSpace(string(t)) != "" {
+			prev = l
+		}
+	}
+	return f
+}
+
+func isHeading(l Line, level int) bool {
+	h, ok := l.(Heading)
+	return ok && h.Level == level
+}
+
+func feedEntry(l Link) (FeedEntry, bool) {
+	if len(l.Label) < len("2006-01-02") {
+		return FeedEntry{}, false
+	}
+	date, err := time.Parse("2006-01-02", l.Label[:10])
+	if err != nil {
+		return FeedEntry{}, false
+	}
+	title := strings.TrimSpace(l.Label[10:])
+	title = strings.TrimSpace(strings.TrimPrefix(title, "-"))
+	return FeedEntry{URL: l.URL, Date: date, Title: title}, true
+}
+
+// WriteFeed writes f to w as a subscribable page with its entries newest
+// first.
+func WriteFeed(w io.Writer, f Feed) error {
+	gw := NewWriter(w)
+	if f.Title != "" {
+		gw.Heading(1, f.Title)
+	}
+	if f.Subtitle != "" {
+		gw.Heading(2, f.Subtitle)
+	}
+	if f.Title != "" || f.Subtitle != "" {
+		gw.Text("")
+	}
+	entries := append([]FeedEntry(nil), f.Entries...)
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Date.After(entries[j].Date) })
+	for _, e := range entries {
+		gw.Link(e.URL, strings.TrimSpace(e.Date.Format("2006-01-02")+" "+oneLine(e.Title)))
+	}
+	return gw.Err()
+}
@@ -0,0 +1,54 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFeed(t *testing.T) {
+	doc := gemtext.ParseString("# My Log\n" +
+		"\n" +
+		"## Notes from the field\n" +
+		"Intro text.\n" +
+		"=> /about.gmi About\n" +
+		"=> /2024-02-01.gmi 2024-02-01 - Second post\n" +
+		"=> /first.gmi 2024-01-15 First post\n" +
+		"=> /bare.gmi 2023-12-31\n" +
+		"=> /bad.gmi 2024-13-01 Bad month\n" +
+		"# Another title\n")
+
+	f := gemtext.ParseFeed(doc)
+	require.Equal(t, "My Log", f.Title)
+	require.Equal(t, "Notes from the field", f.Subtitle)
+	require.Equal(t, []gemtext.FeedEntry{
+		{URL: "/2024-02-01.gmi", Date: time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), Title: "Second post"},
+		{URL: "/first.gmi", Date: time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC), Title: "First post"},
+		{URL: "/bare.gmi", Date: time.Date(2023, 12, 31, 0, 0, 0, 0, time.UTC)},
+	}, f.Entries)
+
+	require.Empty(t, gemtext.ParseFeed(gemtext.ParseString("Text\n## Sub\n")).Subtitle)
+}
+
+func TestWriteFeed(t *testing.T) {
+	var b strings.Builder
+	require.NoError(t, gemtext.WriteFeed(&b, gemtext.Feed{
+		Title:    "My Log",
+		Subtitle: "Notes",
+		Entries: []gemtext.FeedEntry{
+			{URL: "/old.gmi", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), Title: "Old"},
+			{URL: "/new post.gmi", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), Title: "New\npost"},
+		},
+	}))
+	require.Equal(t, "# My Log\n## Notes\n\n"+
+		"=> /new%20post.gmi 2024-01-01 New post\n"+
+		"=> /old.gmi 2023-01-01 Old\n", b.String())
+
+	// Round trip.
+	f := gemtext.ParseFeed(gemtext.ParseString(b.String()))
+	require.Equal(t, "Notes", f.Subtitle)
+	require.Len(t, f.Entries, 2)
+}
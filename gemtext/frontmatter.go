@@ -0,0 +1,197 @@
+package gemtext
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Metadata is the front matter of a content file.
+type Metadata struct {
+	Title string
+	Date  time.Time
+	Tags  []string
+	Draft bool
+
+	// Params holds every front matter key, including the ones above.
+	Params map[string]interface{}
+}
+
+// SplitFrontMatter separates front matter from the body of src.
+//
+// YAML front matter is enclosed in lines holding "---" and TOML front
+// matter in lines holding "+++"; both must start on the first line. The
+// TOML support covers flat key = value pairs with strings, numbers,
+// booleans, dates and arrays of those. Without front matter src is
+// returned unchanged with empty Metadata.
+func SplitFrontMatter(src []byte) (Metadata, []byte, error) {
+	var meta Metadata
+	delim := ""
+	switch {
+	case hasDelimLine(src, "---"):
+		delim = "---"
+	case hasDelimLine(src, "+++"):
+		delim = "+++"
+	default:
+		return meta, src, nil
+	}
+	rest := src[bytes.IndexByte(src, '\n')+1:]
+	var head, body []byte
+	for off := 0; ; {
+		i := bytes.IndexByte(rest[off:], '\n')
+		line := rest[off:]
+		if i >= 0 {
+			line = rest[off : off+i]
+		}
+		if string(bytes.TrimRight(line, "\r ")) == delim {
+			head = rest[:off]
+			if i >= 0 {
+				body = rest[off+i+1:]
+			}
+			break
+		}
+		if i < 0 {
+			return meta, src, fmt.Errorf("gemtext: unterminated front matter")
+		}
+		off += i + 1
+	}
+
+	params := map[string]interface{}{}
+	var err error
+	if delim == "---" {
+		err = yaml.Unmarshal(head, &params)
+	} else {
+		params, err = parseTOML(head)
+	}
+	if err != nil {
+		return meta, src, fmt.Errorf("gemtext: front matter: %v", err)
+	}
+	if params == nil {
+		params = map[string]interface{}{}
+	}
+	meta.Params = params
+	meta.Title, _ = params["title"].(string)
+	meta.Draft, _ = params["draft"].(bool)
+	meta.Date = toTime(params["date"])
+	switch tags := params["tags"].(type) {
+	case string:
+		meta.Tags = []string{tags}
+	case []interface{}:
+		for _, t := range tags {
+			meta.Tags = append(meta.Tags, fmt.Sprint(t))
+		}
+	}
+	return meta, body, nil
+}
+
+func hasDelimLine(src []byte, delim string) bool {
+	i := bytes.IndexByte(src, '\n')
+	return i >= 0 && string(bytes.TrimRight(src[:i], "\r ")) == delim
+}
+
+var dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func toTime(v interface{}) time.Time {
+	switch v := v.(type) {
+	case time.Time:
+		return v
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	}
+	return time.Time{}
+}
+
+// parseTOML parses flat key = value TOML.
+func parseTOML(src []byte) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for n, line := range strings.Split(string(src), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		i := strings.IndexByte(line, '=')
+		if i < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n+1)
+		}
+		key := strings.Trim(strings.TrimSpace(line[:i]), `"`)
+		v, err := tomlValue(strings.TrimSpace(line[i+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+		params[key] = v
+	}
+	return params, nil
+}
+
+func tomlValue(s string) (interface{}, error) {
+	switch {
+	case strings.HasPrefix(s, "["):
+		if !strings.HasSuffix(s, "]") {
+			return nil, fmt.Errorf("unterminated array")
+		}
+		var list []interface{}
+		for _, item := range splitTOMLArray(s[1 : len(s)-1]) {
+			v, err := tomlValue(item)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, v)
+		}
+		return list, nil
+	case strings.HasPrefix(s, `"`):
+		return strconv.Unquote(s)
+	case strings.HasPrefix(s, "'"):
+		if len(s) < 2 || !strings.HasSuffix(s, "'") {
+			return nil, fmt.Errorf("unterminated string")
+		}
+		return s[1 : len(s)-1], nil
+	case s == "true" || s == "false":
+		return s == "true", nil
+	}
+	if i, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return int(i), nil
+	}
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return f, nil
+	}
+	if t := toTime(s); !t.IsZero() {
+		return t, nil
+	}
+	return nil, fmt.Errorf("unsupported value %s", s)
+}
+
+// splitTOMLArray splits array items on commas outside of strings.
+func splitTOMLArray(s string) []string {
+	var items []string
+	var quote rune
+	start := 0
+	for i, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case r == '"' || r == '\'':
+			quote = r
+		case r == ',':
+			items = append(items, s[start:i])
+			start = i + 1
+		}
+	}
+	items = append(items, s[start:])
+	var out []string
+	for _, item := range items {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
@@ -0,0 +1,42 @@
+package gemtext_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitFrontMatter(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	meta, body, err := gemtext.SplitFrontMatter([]byte("---\ntitle: Hello\ndate: 2024-03-01\ntags: [go, gemini]\ndraft: true\nauthor: ann\n---\n# Hello\n"))
+	require.NoError(t, err)
+	require.Equal(t, "Hello", meta.Title)
+	require.True(t, date.Equal(meta.Date))
+	require.Equal(t, []string{"go", "gemini"}, meta.Tags)
+	require.True(t, meta.Draft)
+	require.Equal(t, "ann", meta.Params["author"])
+	require.Equal(t, "# Hello\n", string(body))
+
+	meta, body, err = gemtext.SplitFrontMatter([]byte("+++\r\ntitle = \"Hi, there\"\ndate = 2024-03-01\ntags = [\"a,b\", 'c']\ndraft = false\nweight = 3\n+++\r\nbody"))
+	require.NoError(t, err)
+	require.Equal(t, "Hi, there", meta.Title)
+	require.True(t, date.Equal(meta.Date))
+	require.Equal(t, []string{"a,b", "c"}, meta.Tags)
+	require.False(t, meta.Draft)
+	require.Equal(t, 3, meta.Params["weight"])
+	require.Equal(t, "body", string(body))
+
+	src := []byte("# No front matter\n---\n")
+	meta, body, err = gemtext.SplitFrontMatter(src)
+	require.NoError(t, err)
+	require.Equal(t, src, body)
+	require.Empty(t, meta.Title)
+
+	_, _, err = gemtext.SplitFrontMatter([]byte("---\ntitle: x\n"))
+	require.Error(t, err)
+	_, _, err = gemtext.SplitFrontMatter([]byte("+++\ntitle\n+++\n"))
+	require.Error(t, err)
+}
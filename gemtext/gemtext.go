@@ -0,0 +1,217 @@
+// Package gemtext parses, writes and converts text/gemini documents.
+//
+// Scanner reads a document one line at a time, which suits large or
+// streamed documents. Parse reads a whole document and groups
+// preformatted lines into Preformatted blocks.
+package gemtext
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// Line is a parsed line of a text/gemini document. String returns the
+// line in gemtext syntax, without the line ending.
+type Line interface {
+	String() string
+}
+
+// Text is a plain text line.
+type Text string
+
+func (t Text) String() string { return string(t) }
+
+// Link is a link line.
+type Link struct {
+	URL   string
+	Label string
+}
+
+func (l Link) String() string {
+	if l.Label == "" {
+		return "=> " + l.URL
+	}
+	return "=> " + l.URL + " " + l.Label
+}
+
+// Heading is a heading line of level 1 to 3.
+type Heading struct {
+	Level int
+	Text  string
+}
+
+func (h Heading) String() string {
+	return strings.Repeat("#", h.Level) + " " + h.Text
+}
+
+// ListItem is an unordered list item line.
+type ListItem string
+
+func (li ListItem) String() string { return "* " + string(li) }
+
+// Quote is a quote line.
+type Quote string
+
+func (q Quote) String() string { return "> " + string(q) }
+
+// PreformatToggle is a line starting or ending a preformatted block.
+// Alt is the alt text of an opening toggle. Only Scanner returns it.
+type PreformatToggle struct {
+	Alt string
+}
+
+func (p PreformatToggle) String() string { return "```" + p.Alt }
+
+// PreformattedText is a line inside a preformatted block. Only Scanner
+// returns it.
+type PreformattedText string
+
+func (p PreformattedText) String() string { return string(p) }
+
+// Preformatted is a whole preformatted block. Lines holds its content
+// lines without line endings. Only Parse returns it.
+type Preformatted struct {
+	Alt   string
+	Lines []string
+}
+
+func (p Preformatted) String() string {
+	var b strings.Builder
+	b.WriteString("```" + p.Alt + "\n")
+	for _, l := range p.Lines {
+		b.WriteString(l + "\n")
+	}
+	b.WriteString("```")
+	return b.String()
+}
+
+// Document is a parsed text/gemini document.
+type Document []Line
+
+// String returns the document in gemtext syntax.
+func (d Document) String() string {
+	var b strings.Builder
+	for _, l := range d {
+		b.WriteString(l.String())
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// Scanner reads the lines of a text/gemini document.
+type Scanner struct {
+	s    *bufio.Scanner
+	line Line
+	pre  bool
+}
+
+// NewScanner returns a Scanner reading from r.
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{s: bufio.NewScanner(r)}
+}
+
+// Buffer sets the initial buffer and the maximum line length, see
+// bufio.Scanner.Buffer.
+func (s *Scanner) Buffer(buf []byte, max int) {
+	s.s.Buffer(buf, max)
+}
+
+// Scan advances to the next line, which is then available through Line.
+// It returns false at the end of the input or on an error.
+func (s *Scanner) Scan() bool {
+	if !s.s.Scan() {
+		return false
+	}
+	s.line = s.parse(strings.TrimSuffix(s.s.Text(), "\r"))
+	return true
+}
+
+// Line returns the line read by the last call to Scan.
+func (s *Scanner) Line() Line {
+	return s.line
+}
+
+// Err returns the first error reading the input.
+func (s *Scanner) Err() error {
+	return s.s.Err()
+}
+
+func (s *Scanner) parse(text string) Line {
+	if strings.HasPrefix(text, "```") {
+		s.pre = !s.pre
+		if s.pre {
+			return PreformatToggle{Alt: strings.TrimSpace(text[3:])}
+		}
+		return PreformatToggle{}
+	}
+	if s.pre {
+		return PreformattedText(text)
+	}
+	return ParseLine(text)
+}
+
+// ParseLine parses a single line outside of a preformatted block. Lines
+// starting with "```" are returned as PreformatToggle.
+func ParseLine(text string) Line {
+	switch {
+	case strings.HasPrefix(text, "```"):
+		return PreformatToggle{Alt: strings.TrimSpace(text[3:])}
+	case strings.HasPrefix(text, "=>"):
+		rest := strings.TrimSpace(text[2:])
+		if rest == "" {
+			return Text(text)
+		}
+		i := strings.IndexAny(rest, " \t")
+		if i < 0 {
+			return Link{URL: rest}
+		}
+		return Link{URL: rest[:i], Label: strings.TrimSpace(rest[i:])}
+	case strings.HasPrefix(text, "#"):
+		level := len(text) - len(strings.TrimLeft(text, "#"))
+		if level > 3 {
+			level = 3
+		}
+		return Heading{Level: level, Text: strings.TrimSpace(text[level:])}
+	case strings.HasPrefix(text, "* "):
+		return ListItem(strings.TrimSpace(text[2:]))
+	case strings.HasPrefix(text, ">"):
+		return Quote(strings.TrimSpace(text[1:]))
+	default:
+		return Text(text)
+	}
+}
+
+// Parse reads a whole document. Preformatted blocks, including one left
+// open at the end of the input, are returned as Preformatted.
+func Parse(r io.Reader) (Document, error) {
+	var doc Document
+	var block *Preformatted
+	s := NewScanner(r)
+	for s.Scan() {
+		switch l := s.Line().(type) {
+		case PreformatToggle:
+			if block == nil {
+				block = &Preformatted{Alt: l.Alt}
+				continue
+			}
+			doc = append(doc, *block)
+			block = nil
+		case PreformattedText:
+			block.Lines = append(block.Lines, string(l))
+		default:
+			doc = append(doc, l)
+		}
+	}
+	if block != nil {
+		doc = append(doc, *block)
+	}
+	return doc, s.Err()
+}
+
+// ParseString is like Parse for a document held in a string. A line
+// longer than bufio.MaxScanTokenSize ends the document.
+func ParseString(text string) Document {
+	doc, _ := Parse(strings.NewReader(text))
+	return doc
+}
@@ -0,0 +1,75 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+const doc = "# Title\r\n" +
+	"## Sub\n" +
+	"####Deep\n" +
+	"Some text.\n" +
+	"=> gemini://example.org/ Example  site\n" +
+	"=>/bare\n" +
+	"=>\n" +
+	"* item\n" +
+	">quoted\n" +
+	"```go code\n" +
+	"=> not a link\n" +
+	"\n" +
+	"```\n" +
+	"after\n" +
+	"``` open\n" +
+	"# unterminated\n"
+
+func TestScanner(t *testing.T) {
+	s := gemtext.NewScanner(strings.NewReader(doc))
+	var lines []gemtext.Line
+	for s.Scan() {
+		lines = append(lines, s.Line())
+	}
+	require.NoError(t, s.Err())
+	require.Equal(t, []gemtext.Line{
+		gemtext.Heading{Level: 1, Text: "Title"},
+		gemtext.Heading{Level: 2, Text: "Sub"},
+		gemtext.Heading{Level: 3, Text: "#Deep"},
+		gemtext.Text("Some text."),
+		gemtext.Link{URL: "gemini://example.org/", Label: "Example  site"},
+		gemtext.Link{URL: "/bare"},
+		gemtext.Text("=>"),
+		gemtext.ListItem("item"),
+		gemtext.Quote("quoted"),
+		gemtext.PreformatToggle{Alt: "go code"},
+		gemtext.PreformattedText("=> not a link"),
+		gemtext.PreformattedText(""),
+		gemtext.PreformatToggle{},
+		gemtext.Text("after"),
+		gemtext.PreformatToggle{Alt: "open"},
+		gemtext.PreformattedText("# unterminated"),
+	}, lines)
+}
+
+func TestParse(t *testing.T) {
+	d, err := gemtext.Parse(strings.NewReader(doc))
+	require.NoError(t, err)
+	require.Len(t, d, 12)
+	require.Equal(t, gemtext.Preformatted{Alt: "go code", Lines: []string{"=> not a link", ""}}, d[9])
+	require.Equal(t, gemtext.Preformatted{Alt: "open", Lines: []string{"# unterminated"}}, d[11])
+
+	require.Equal(t, "# Title\n"+
+		"## Sub\n"+
+		"### #Deep\n"+
+		"Some text.\n"+
+		"=> gemini://example.org/ Example  site\n"+
+		"=> /bare\n"+
+		"=>\n"+
+		"* item\n"+
+		"> quoted\n"+
+		"```go code\n=> not a link\n\n```\n"+
+		"after\n"+
+		"```open\n# unterminated\n```\n", d.String())
+	require.Equal(t, d, gemtext.ParseString(d.String()))
+}
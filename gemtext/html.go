@@ -0,0 +1,122 @@
+package gemtext
+
+import (
+	"bufio"
+	"html"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// HTMLRenderer converts documents to HTML fragments. The zero value is
+// ready to use.
+//
+// All text is escaped. Link targets with schemes other than gemini,
+// titan, gopher, finger, http, https and mailto, such as javascript:, are
+// rendered as plain text instead of links.
+type HTMLRenderer struct {
+	// RewriteURL optionally rewrites link targets, for example to point
+	// gemini:// links at an HTTP mirror.
+	RewriteURL func(string) string
+
+	// Classes optionally maps element names ("h1", "h2", "h3", "p", "a",
+	// "ul", "li", "blockquote", "pre") to CSS class attributes.
+	Classes map[string]string
+}
+
+var safeSchemes = map[string]bool{
+	"gemini": true, "titan": true, "gopher": true, "finger": true,
+	"http": true, "https": true, "mailto": true,
+}
+
+// Render writes doc to w as HTML.
+func (hr *HTMLRenderer) Render(w io.Writer, doc Document) error {
+	bw := bufio.NewWriter(w)
+	inList := false
+	for _, l := range doc {
+		if _, ok := l.(ListItem); ok != inList {
+			if ok {
+				bw.WriteString(hr.open("ul") + "\n")
+			} else {
+				bw.WriteString("</ul>\n")
+			}
+			inList = ok
+		}
+		switch l := l.(type) {
+		case Heading:
+			tag := "h" + string(rune('0'+l.Level))
+			bw.WriteString(hr.open(tag) + html.EscapeString(l.Text) + "</" + tag + ">\n")
+		case Link:
+			label := l.Label
+			if label == "" {
+				label = l.URL
+			}
+			bw.WriteString(hr.open("p"))
+			if href, ok := hr.href(l.URL); ok {
+				bw.WriteString(`<a href="` + html.EscapeString(href) + `"` + hr.class("a") + ">" + html.EscapeString(label) + "</a>")
+			} else {
+				bw.WriteString(html.EscapeString(label))
+			}
+			bw.WriteString("</p>\n")
+		case ListItem:
+			bw.WriteString(hr.open("li") + html.EscapeString(string(l)) + "</li>\n")
+		case Quote:
+			bw.WriteString(hr.open("blockquote") + html.EscapeString(string(l)) + "</blockquote>\n")
+		case Preformatted:
+			bw.WriteString("<pre" + hr.class("pre"))
+			if l.Alt != "" {
+				bw.WriteString(` aria-label="` + html.EscapeString(l.Alt) + `"`)
+			}
+			bw.WriteString(">")
+			for i, line := range l.Lines {
+				if i > 0 {
+					bw.WriteString("\n")
+				}
+				bw.WriteString(html.EscapeString(line))
+			}
+			bw.WriteString("</pre>\n")
+		case PreformatToggle, PreformattedText:
+			// Only produced by Scanner; Render takes parsed documents.
+		default:
+			if text := l.String(); strings.TrimSpace(text) != "" {
+				bw.WriteString(hr.open("p") + html.EscapeString(text) + "</p>\n")
+			}
+		}
+	}
+	if inList {
+		bw.WriteString("</ul>\n")
+	}
+	return bw.Flush()
+}
+
+func (hr *HTMLRenderer) open(tag string) string {
+	return "<" + tag + hr.class(tag) + ">"
+}
+
+func (hr *HTMLRenderer) class(tag string) string {
+	if c := hr.Classes[tag]; c != "" {
+		return ` class="` + html.EscapeString(c) + `"`
+	}
+	return ""
+}
+
+func (hr *HTMLRenderer) href(target string) (string, bool) {
+	if hr.RewriteURL != nil {
+		target = hr.RewriteURL(target)
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "", false
+	}
+	if u.Scheme != "" && !safeSchemes[strings.ToLower(u.Scheme)] {
+		return "", false
+	}
+	return target, true
+}
+
+// ToHTML returns doc rendered by a zero HTMLRenderer.
+func ToHTML(doc Document) string {
+	var b strings.Builder
+	(&HTMLRenderer{}).Render(&b, doc)
+	return b.String()
+}
@@ -0,0 +1,43 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToHTML(t *testing.T) {
+	doc := gemtext.ParseString("# A <title>\n" +
+		"\n" +
+		"Text & more\n" +
+		"=> gemini://example.org/ Example\n" +
+		"=> javascript:alert(1) Click\n" +
+		"=> /rel\n" +
+		"* one\n" +
+		"* two\n" +
+		"> quote\n" +
+		"```sh\n" +
+		"echo \"<hi>\"\n" +
+		"```\n")
+	require.Equal(t, "<h1>A &lt;title&gt;</h1>\n"+
+		"<p>Text &amp; more</p>\n"+
+		"<p><a href=\"gemini://example.org/\">Example</a></p>\n"+
+		"<p>Click</p>\n"+
+		"<p><a href=\"/rel\">/rel</a></p>\n"+
+		"<ul>\n<li>one</li>\n<li>two</li>\n</ul>\n"+
+		"<blockquote>quote</blockquote>\n"+
+		"<pre aria-label=\"sh\">echo &#34;&lt;hi&gt;&#34;</pre>\n", gemtext.ToHTML(doc))
+
+	var b strings.Builder
+	hr := &gemtext.HTMLRenderer{
+		RewriteURL: func(u string) string {
+			return strings.Replace(u, "gemini://", "https://proxy.example/", 1)
+		},
+		Classes: map[string]string{"a": "link", "ul": "list"},
+	}
+	require.NoError(t, hr.Render(&b, gemtext.ParseString("=> gemini://x/ X\n* i\n")))
+	require.Equal(t, "<p><a href=\"https://proxy.example/x/\" class=\"link\">X</a></p>\n"+
+		"<ul class=\"list\">\n<li>i</li>\n</ul>\n", b.String())
+}
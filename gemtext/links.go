@@ -0,0 +1,28 @@
+package gemtext
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Links returns the targets of the link lines of doc in document order,
+// resolved against base. Targets that are not valid URLs are skipped. A
+// nil base leaves relative targets unresolved.
+func Links(doc Document, base *url.URL) []*url.URL {
+	var links []*url.URL
+	for _, l := range doc {
+		link, ok := l.(Link)
+		if !ok {
+			continue
+		}
+		u, err := url.Parse(strings.TrimSpace(link.URL))
+		if err != nil {
+			continue
+		}
+		if base != nil {
+			u = base.ResolveReference(u)
+		}
+		links = append(links, u)
+	}
+	return links
+}
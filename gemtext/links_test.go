@@ -0,0 +1,34 @@
+package gemtext_test
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLinks(t *testing.T) {
+	doc := gemtext.ParseString("# Links\n" +
+		"=> gemini://example.org/a Absolute\n" +
+		"=> b.gmi\n" +
+		"=> ../c?q=1 Up\n" +
+		"=> //other.example/d\n" +
+		"=> %zz Bad\n" +
+		"```\n=> not/a/link\n```\n")
+	base, err := url.Parse("gemini://example.org/dir/page.gmi")
+	require.NoError(t, err)
+
+	var got []string
+	for _, u := range gemtext.Links(doc, base) {
+		got = append(got, u.String())
+	}
+	require.Equal(t, []string{
+		"gemini://example.org/a",
+		"gemini://example.org/dir/b.gmi",
+		"gemini://example.org/c?q=1",
+		"gemini://other.example/d",
+	}, got)
+
+	require.Equal(t, "b.gmi", gemtext.Links(doc, nil)[1].String())
+}
@@ -0,0 +1,87 @@
+package gemtext
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+// Issue is a problem found in a document by a Linter.
+type Issue struct {
+	// Line and Column are 1-based. Column counts characters.
+	Line    int
+	Column  int
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%d:%d: %s", i.Line, i.Column, i.Message)
+}
+
+// Linter reports text/gemini documents not following the specification
+// or common practice: link lines without or with an invalid URL, tabs
+// after "=>", preformatted blocks left open and, if MaxLineLength is set,
+// overly long lines. The zero value is ready to use.
+type Linter struct {
+	// MaxLineLength, when positive, reports lines longer than that many
+	// characters.
+	MaxLineLength int
+}
+
+// Lint reads the document from r and returns its issues in document
+// order.
+func (l *Linter) Lint(r io.Reader) ([]Issue, error) {
+	var issues []Issue
+	report := func(line, col int, format string, args ...interface{}) {
+		issues = append(issues, Issue{Line: line, Column: col, Message: fmt.Sprintf(format, args...)})
+	}
+	s := NewScanner(r)
+	s.Buffer(nil, 1<<20)
+	n, open := 0, 0
+	for s.Scan() {
+		n++
+		text := strings.TrimSuffix(s.s.Text(), "\r")
+		if l.MaxLineLength > 0 && utf8.RuneCountInString(text) > l.MaxLineLength {
+			report(n, l.MaxLineLength+1, "line longer than %d characters", l.MaxLineLength)
+		}
+		switch line := s.Line().(type) {
+		case PreformatToggle:
+			if open == 0 {
+				open = n
+			} else {
+				open = 0
+			}
+		case Link:
+			if strings.HasPrefix(text, "=>\t") {
+				report(n, 3, "tab after \"=>\"; use a space")
+			}
+			if _, err := url.Parse(line.URL); err != nil {
+				report(n, utf8.RuneCountInString(text[:strings.Index(text, line.URL)])+1, "invalid link URL %q", line.URL)
+			}
+		case Text:
+			if strings.HasPrefix(text, "=>") {
+				report(n, 1, "link line without URL")
+			}
+		}
+	}
+	if open != 0 {
+		report(open, 1, "unterminated preformatted block")
+	}
+	return issues, s.Err()
+}
+
+// Normalize returns src rewritten in canonical form: line breaks are
+// "\n", link, heading, list item and quote markers are followed by a
+// single space, surrounding white space is removed from those lines and
+// an unterminated preformatted block is closed. Text and preformatted
+// lines are left unchanged.
+func Normalize(src []byte) ([]byte, error) {
+	doc, err := Parse(bytes.NewReader(src))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(doc.String()), nil
+}
@@ -0,0 +1,48 @@
+package gemtext_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	doc := "# Title\r\n" +
+		"=>\tgemini://example.org/ Tabbed\n" +
+		"=> \n" +
+		"=> http://[::1 Bad\n" +
+		"a fairly long line\n" +
+		"```\n" +
+		"=> inside pre\n" +
+		"```\n" +
+		"```alt\n" +
+		"never closed\n"
+
+	issues, err := (&gemtext.Linter{MaxLineLength: 15}).Lint(strings.NewReader(doc))
+	require.NoError(t, err)
+	var got []string
+	for _, i := range issues {
+		got = append(got, i.String())
+	}
+	require.Equal(t, []string{
+		"2:16: line longer than 15 characters",
+		"2:3: tab after \"=>\"; use a space",
+		"3:1: link line without URL",
+		"4:16: line longer than 15 characters",
+		"4:4: invalid link URL \"http://[::1\"",
+		"5:16: line longer than 15 characters",
+		"9:1: unterminated preformatted block",
+	}, got)
+
+	issues, err = (&gemtext.Linter{}).Lint(strings.NewReader("# Fine\n=> /a A\n```\nx\n```\n"))
+	require.NoError(t, err)
+	require.Empty(t, issues)
+}
+
+func TestNormalize(t *testing.T) {
+	out, err := gemtext.Normalize([]byte("#Title\r\n=>\t/a \t A  \n*  item\n>quote\ntext  \n```go\n>  pre\n"))
+	require.NoError(t, err)
+	require.Equal(t, "# Title\n=> /a A\n* item\n> quote\ntext  \n```go\n>  pre\n```\n", string(out))
+}
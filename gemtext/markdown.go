@@ -0,0 +1,235 @@
+package gemtext
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdHeading     = regexp.MustCompile(`^(#{1,6})\s+(.*?)\s*#*\s*$`)
+	mdSetext1     = regexp.MustCompile(`^ {0,3}=+\s*$`)
+	mdSetext2     = regexp.MustCompile(`^ {0,3}-+\s*$`)
+	mdRule        = regexp.MustCompile(`^ {0,3}(?:(?:-\s*){3,}|(?:\*\s*){3,}|(?:_\s*){3,})$`)
+	mdListItem    = regexp.MustCompile(`^\s{0,3}[-+*]\s+(.*)$`)
+	mdOrderedItem = regexp.MustCompile(`^\s{0,3}(\d{1,9})[.)]\s+(.*)$`)
+	mdFence       = regexp.MustCompile("^\\s{0,3}(```|~~~)\\s*(\\S*)")
+	mdLinkDef     = regexp.MustCompile(`^ {0,3}\[([^\]]+)\]:\s*<?([^\s>]+)>?(?:\s+["'(].*)?$`)
+	mdInline      = regexp.MustCompile(`<([a-zA-Z][a-zA-Z0-9+.-]*:[^>\s]+)>|(!?)\[([^\]]*)\](?:\(<?([^)\s>]+)>?(?:\s+"[^"]*")?\)|\[([^\]]*)\])?`)
+	mdCode        = regexp.MustCompile("`+([^`]+)`+")
+	mdEmphasis    = []*regexp.Regexp{
+		regexp.MustCompile(`\*\*(\S(?:.*?\S)?)\*\*`),
+		regexp.MustCompile(`\b__(\S(?:.*?\S)?)__\b`),
+		regexp.MustCompile(`~~(\S(?:.*?\S)?)~~`),
+		regexp.MustCompile(`\*(\S(?:[^*]*?\S)?)\*`),
+		regexp.MustCompile(`\b_(\S(?:[^_]*?\S)?)_\b`),
+	}
+)
+
+// FromMarkdown converts a Markdown document to text/gemini.
+//
+// ATX and setext headings deeper than three levels become level three
+// headings, paragraphs are joined into single text lines and fenced code
+// blocks become preformatted blocks with the info string as alt text.
+// Ordered list items become text lines keeping their number, tables
+// (rows starting with "|") become preformatted blocks and thematic breaks
+// become blank lines. Emphasis and code span markers are removed.
+//
+// Inline, reference, image and autolinks keep their text in place and
+// are repeated as link lines after the paragraph, list item or quote
+// containing them.
+func FromMarkdown(src []byte) []byte {
+	c := &mdConverter{refs: mdLinkDefs(src)}
+	s := bufio.NewScanner(bytes.NewReader(src))
+	s.Buffer(nil, 1<<20)
+	for s.Scan() {
+		c.line(strings.TrimSuffix(s.Text(), "\r"))
+	}
+	c.flush()
+	if c.fence != "" || c.table {
+		c.out.WriteString("```\n")
+	}
+	return c.out.Bytes()
+}
+
+// mdLinkDefs returns the link reference definitions of src outside of
+// code fences, keyed by lower case label.
+func mdLinkDefs(src []byte) map[string]string {
+	refs := map[string]string{}
+	fence := ""
+	for _, text := range strings.Split(string(src), "\n") {
+		text = strings.TrimSuffix(text, "\r")
+		if fence != "" {
+			if strings.HasPrefix(strings.TrimSpace(text), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if m := mdFence.FindStringSubmatch(text); m != nil {
+			fence = m[1]
+			continue
+		}
+		if m := mdLinkDef.FindStringSubmatch(text); m != nil {
+			if key := strings.ToLower(m[1]); refs[key] == "" {
+				refs[key] = m[2]
+			}
+		}
+	}
+	return refs
+}
+
+type mdConverter struct {
+	out   bytes.Buffer
+	para  []string
+	links []Link
+	refs  map[string]string
+	fence string // open code fence marker
+	table bool   // inside a table
+}
+
+func (c *mdConverter) line(text string) {
+	if c.fence != "" {
+		if strings.HasPrefix(strings.TrimSpace(text), c.fence) {
+			c.fence = ""
+			c.out.WriteString("```\n")
+			return
+		}
+		c.out.WriteString(text + "\n")
+		return
+	}
+	trimmed := strings.TrimSpace(text)
+	if strings.HasPrefix(trimmed, "|") {
+		if !c.table {
+			c.flush()
+			c.table = true
+			c.out.WriteString("```table\n")
+		}
+		c.out.WriteString(trimmed + "\n")
+		return
+	}
+	if c.table {
+		c.table = false
+		c.out.WriteString("```\n")
+	}
+	if m := mdFence.FindStringSubmatch(text); m != nil {
+		c.flush()
+		c.fence = m[1]
+		c.out.WriteString("```" + m[2] + "\n")
+		return
+	}
+	switch {
+	case trimmed == "":
+		c.flush()
+		c.blank()
+	case mdLinkDef.MatchString(text):
+		c.flush()
+	case len(c.para) > 0 && mdSetext1.MatchString(text):
+		c.heading(1)
+	case len(c.para) > 0 && mdSetext2.MatchString(text):
+		c.heading(2)
+	case mdRule.MatchString(text):
+		c.flush()
+		c.blank()
+	case mdHeading.MatchString(trimmed):
+		c.flush()
+		m := mdHeading.FindStringSubmatch(trimmed)
+		level := len(m[1])
+		if level > 3 {
+			level = 3
+		}
+		c.emit(Heading{Level: level, Text: c.inline(m[2])})
+	case mdListItem.MatchString(text):
+		c.flush()
+		c.emit(ListItem(c.inline(mdListItem.FindStringSubmatch(text)[1])))
+	case mdOrderedItem.MatchString(text):
+		c.flush()
+		m := mdOrderedItem.FindStringSubmatch(text)
+		c.emit(Text(m[1] + ". " + c.inline(m[2])))
+	case strings.HasPrefix(trimmed, ">"):
+		c.flush()
+		c.emit(Quote(c.inline(strings.TrimSpace(trimmed[1:]))))
+	default:
+		c.para = append(c.para, trimmed)
+	}
+}
+
+// blank writes a blank line unless the output ends in one.
+func (c *mdConverter) blank() {
+	if c.out.Len() > 0 && !bytes.HasSuffix(c.out.Bytes(), []byte("\n\n")) {
+		c.out.WriteString("\n")
+	}
+}
+
+// heading writes the pending paragraph as a heading of level.
+func (c *mdConverter) heading(level int) {
+	text := c.inline(strings.Join(c.para, " "))
+	c.para = nil
+	c.emit(Heading{Level: level, Text: text})
+}
+
+// inline rewrites links to their text, records them and removes
+// emphasis and code span markers.
+func (c *mdConverter) inline(text string) string {
+	text = mdInline.ReplaceAllStringFunc(text, func(s string) string {
+		m := mdInline.FindStringSubmatch(s)
+		if m[1] != "" {
+			c.links = append(c.links, Link{URL: m[1]})
+			return m[1]
+		}
+		target := m[4]
+		if target == "" {
+			key := m[5]
+			if key == "" {
+				key = m[3]
+			}
+			if target = c.refs[strings.ToLower(key)]; target == "" {
+				return s
+			}
+		}
+		label := plainText(m[3])
+		c.links = append(c.links, Link{URL: target, Label: label})
+		return label
+	})
+	return plainText(text)
+}
+
+// plainText removes emphasis markers outside of code spans and the
+// backticks around code spans.
+func plainText(text string) string {
+	var b strings.Builder
+	last := 0
+	for _, loc := range mdCode.FindAllStringSubmatchIndex(text, -1) {
+		b.WriteString(stripEmphasis(text[last:loc[0]]))
+		b.WriteString(text[loc[2]:loc[3]])
+		last = loc[1]
+	}
+	b.WriteString(stripEmphasis(text[last:]))
+	return b.String()
+}
+
+func stripEmphasis(text string) string {
+	for _, re := range mdEmphasis {
+		text = re.ReplaceAllString(text, "$1")
+	}
+	return text
+}
+
+// emit writes l followed by the links collected from it.
+func (c *mdConverter) emit(l Line) {
+	c.out.WriteString(l.String() + "\n")
+	for _, link := range c.links {
+		c.out.WriteString(link.String() + "\n")
+	}
+	c.links = nil
+}
+
+// flush writes the pending paragraph as one text line.
+func (c *mdConverter) flush() {
+	if len(c.para) == 0 {
+		return
+	}
+	text := c.inline(strings.Join(c.para, " "))
+	c.para = nil
+	c.emit(Text(text))
+}
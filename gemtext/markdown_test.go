@@ -0,0 +1,78 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromMarkdown(t *testing.T) {
+	md := "# Title #\n" +
+		"\n" +
+		"A paragraph with a [link](https://example.org \"t\")\n" +
+		"spanning lines.\n" +
+		"\n" +
+		"\n" +
+		"#### Deep\n" +
+		"- one\n" +
+		"+ [two](two.md)\n" +
+		"> quote\n" +
+		"```go\n" +
+		"# not a heading\n" +
+		"```\n" +
+		"~~~\n" +
+		"unterminated"
+
+	require.Equal(t, "# Title\n"+
+		"\n"+
+		"A paragraph with a link spanning lines.\n"+
+		"=> https://example.org link\n"+
+		"\n"+
+		"### Deep\n"+
+		"* one\n"+
+		"* two\n"+
+		"=> two.md two\n"+
+		"> quote\n"+
+		"```go\n# not a heading\n```\n"+
+		"```\nunterminated\n```\n", string(gemtext.FromMarkdown([]byte(md))))
+}
+
+func TestFromMarkdownExtended(t *testing.T) {
+	md := "Title\n" +
+		"=====\n" +
+		"Sub *title*\n" +
+		"---\n" +
+		"Some **bold**, _em_, ~~gone~~ and `a*b*c` in snake_case_name.\n" +
+		"See [the docs][docs], [Docs] and <gemini://example.org/>.\n" +
+		"\n" +
+		"***\n" +
+		"\n" +
+		"1. first ![a cat](cat.png)\n" +
+		"2) second [missing][nope]\n" +
+		"| a | b |\n" +
+		"|---|---|\n" +
+		"| 1 | 2 |\n" +
+		"after\n" +
+		"\n" +
+		"[docs]: https://example.org/docs \"Docs\"\n"
+
+	require.Equal(t, "# Title\n"+
+		"## Sub title\n"+
+		"Some bold, em, gone and a*b*c in snake_case_name. "+
+		"See the docs, Docs and gemini://example.org/.\n"+
+		"=> https://example.org/docs the docs\n"+
+		"=> https://example.org/docs Docs\n"+
+		"=> gemini://example.org/\n"+
+		"\n"+
+		"1. first a cat\n"+
+		"=> cat.png a cat\n"+
+		"2. second [missing][nope]\n"+
+		"```table\n"+
+		"| a | b |\n"+
+		"|---|---|\n"+
+		"| 1 | 2 |\n"+
+		"```\n"+
+		"after\n"+
+		"\n", string(gemtext.FromMarkdown([]byte(md))))
+}
@@ -0,0 +1,138 @@
+package gemtext
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// ToText renders doc as plain text for terminals and e-mail. Text, list
+// items and quotes are wrapped at width columns; a width of zero or less
+// disables wrapping. Links are written as "label <url>" and preformatted
+// blocks are copied unchanged.
+func ToText(doc Document, width int) string {
+	var b strings.Builder
+	for _, l := range doc {
+		switch l := l.(type) {
+		case Heading:
+			b.WriteString(l.Text + "\n")
+			switch l.Level {
+			case 1:
+				b.WriteString(strings.Repeat("=", utf8.RuneCountInString(l.Text)) + "\n")
+			case 2:
+				b.WriteString(strings.Repeat("-", utf8.RuneCountInString(l.Text)) + "\n")
+			}
+		case Link:
+			if l.Label == "" {
+				b.WriteString(wrap("<"+l.URL+">", width, "", "  "))
+			} else {
+				b.WriteString(wrap(l.Label+" <"+l.URL+">", width, "", "  "))
+			}
+		case ListItem:
+			b.WriteString(wrap(string(l), width, "  * ", "    "))
+		case Quote:
+			b.WriteString(wrap(string(l), width, "> ", "> "))
+		case Preformatted:
+			for _, line := range l.Lines {
+				b.WriteString(line + "\n")
+			}
+		default:
+			b.WriteString(wrap(l.String(), width, "", ""))
+		}
+	}
+	return b.String()
+}
+
+// wrap breaks text into lines of at most width runes, where possible, at
+// spaces. The first line is prefixed with first and the others with rest.
+// The result ends in a newline.
+func wrap(text string, width int, first, rest string) string {
+	words := strings.Fields(text)
+	if width <= 0 || len(words) == 0 {
+		return first + strings.TrimSpace(text) + "\n"
+	}
+	var b strings.Builder
+	line, prefix := "", first
+	for _, word := range words {
+		switch {
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(prefix+line+" "+word) <= width:
+			line += " " + word
+		default:
+			b.WriteString(prefix + line + "\n")
+			line, prefix = word, rest
+		}
+	}
+	b.WriteString(prefix + line + "\n")
+	return b.String()
+}
+
+var (
+	mdSpecial     = regexp.MustCompile("([\\\\`*_\\[\\]<>])")
+	mdBlockPrefix = regexp.MustCompile(`^(\s*)([#>+=-])`)
+	mdOrdered     = regexp.MustCompile(`^(\s*\d+)\.`)
+)
+
+// escapeMarkdown escapes text so Markdown renders it literally.
+func escapeMarkdown(s string) string {
+	s = mdSpecial.ReplaceAllString(s, `\$1`)
+	s = mdBlockPrefix.ReplaceAllString(s, `$1\$2`)
+	return mdOrdered.ReplaceAllString(s, `$1\.`)
+}
+
+// ToMarkdown renders doc as Markdown. Each line becomes its own block;
+// consecutive list items form one list. Text is escaped so it is not
+// interpreted as Markdown syntax.
+func ToMarkdown(doc Document) string {
+	var blocks []string
+	var list []string
+	flush := func() {
+		if list != nil {
+			blocks = append(blocks, strings.Join(list, "\n"))
+			list = nil
+		}
+	}
+	for _, l := range doc {
+		if li, ok := l.(ListItem); ok {
+			list = append(list, "- "+escapeMarkdown(string(li)))
+			continue
+		}
+		flush()
+		switch l := l.(type) {
+		case Heading:
+			blocks = append(blocks, strings.Repeat("#", l.Level)+" "+escapeMarkdown(l.Text))
+		case Link:
+			label := l.Label
+			if label == "" {
+				label = l.URL
+			}
+			blocks = append(blocks, "["+escapeMarkdown(label)+"](<"+l.URL+">)")
+		case Quote:
+			blocks = append(blocks, "> "+escapeMarkdown(string(l)))
+		case Preformatted:
+			fence := "```"
+			for _, line := range l.Lines {
+				for strings.HasPrefix(strings.TrimSpace(line), fence) {
+					fence += "`"
+				}
+			}
+			// Markdown info strings are a single word, the language.
+			info := ""
+			if f := strings.Fields(l.Alt); len(f) > 0 {
+				info = f[0]
+			}
+			lines := append([]string{fence + info}, l.Lines...)
+			blocks = append(blocks, strings.Join(append(lines, fence), "\n"))
+		default:
+			if text := l.String(); strings.TrimSpace(text) != "" {
+				blocks = append(blocks, escapeMarkdown(text))
+			}
+		}
+	}
+	flush()
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
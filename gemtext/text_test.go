@@ -0,0 +1,52 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+const sample = "# Title\n" +
+	"## Sub\n" +
+	"The quick brown fox jumps over the lazy dog.\n" +
+	"\n" +
+	"=> gemini://example.org/ Example *site*\n" +
+	"* first item that is rather long\n" +
+	"* 2. second\n" +
+	"> a quoted line to wrap\n" +
+	"```go program\n" +
+	"fmt.Println(\"```\")\n" +
+	"```\n" +
+	"```\n" +
+	"```\n"
+
+func TestToText(t *testing.T) {
+	doc := gemtext.ParseString(sample)
+	require.Equal(t, "Title\n=====\n"+
+		"Sub\n---\n"+
+		"The quick brown\nfox jumps over\nthe lazy dog.\n"+
+		"\n"+
+		"Example *site*\n  <gemini://example.org/>\n"+
+		"  * first item\n    that is\n    rather long\n"+
+		"  * 2. second\n"+
+		"> a quoted line\n> to wrap\n"+
+		"fmt.Println(\"```\")\n", gemtext.ToText(doc, 16))
+
+	require.Equal(t, "The quick  brown fox jumps over the lazy dog.\n",
+		gemtext.ToText(gemtext.ParseString("The quick  brown fox jumps over the lazy dog.\n"), 0))
+}
+
+func TestToMarkdown(t *testing.T) {
+	doc := gemtext.ParseString(sample)
+	require.Equal(t, "# Title\n\n"+
+		"## Sub\n\n"+
+		"The quick brown fox jumps over the lazy dog.\n\n"+
+		"[Example \\*site\\*](<gemini://example.org/>)\n\n"+
+		"- first item that is rather long\n- 2\\. second\n\n"+
+		"> a quoted line to wrap\n\n"+
+		"```go\nfmt.Println(\"```\")\n```\n\n"+
+		"```\n```\n", gemtext.ToMarkdown(doc))
+
+	require.Equal(t, "\\# not a heading \\[x\\]\n", gemtext.ToMarkdown(gemtext.Document{gemtext.Text("# not a heading [x]")}))
+}
@@ -0,0 +1,100 @@
+package gemtext
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// TOCEntry is a heading in a table of contents.
+type TOCEntry struct {
+	Level int
+	Text  string
+
+	// Anchor is a fragment identifier generated from Text, unique within
+	// the document, such as "getting-started" or "notes-2".
+	Anchor string
+
+	// Children are the entries of the headings nested under this one.
+	Children []*TOCEntry
+}
+
+// TOC is a table of contents: the top level entries of a document.
+type TOC []*TOCEntry
+
+// BuildTOC returns the table of contents of the headings of doc. Each
+// heading is nested under the closest preceding heading of a lower level.
+func BuildTOC(doc Document) TOC {
+	var toc TOC
+	var stack []*TOCEntry
+	used := map[string]int{}
+	for _, l := range doc {
+		h, ok := l.(Heading)
+		if !ok {
+			continue
+		}
+		e := &TOCEntry{Level: h.Level, Text: h.Text, Anchor: anchor(h.Text, used)}
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			toc = append(toc, e)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, e)
+		}
+		stack = append(stack, e)
+	}
+	return toc
+}
+
+// anchor returns a unique slug of text.
+func anchor(text string, used map[string]int) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(text) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	slug := b.String()
+	if slug == "" {
+		slug = "section"
+	}
+	used[slug]++
+	if n := used[slug]; n > 1 {
+		slug += "-" + strconv.Itoa(n)
+	}
+	return slug
+}
+
+// Len returns the number of entries in t, including nested ones.
+func (t TOC) Len() int {
+	n := 0
+	for _, e := range t {
+		n += 1 + TOC(e.Children).Len()
+	}
+	return n
+}
+
+// Document returns t as link lines to the entries' anchors, labeled with
+// section numbers such as "2.1 Installation".
+func (t TOC) Document() Document {
+	var doc Document
+	var walk func(entries []*TOCEntry, prefix string)
+	walk = func(entries []*TOCEntry, prefix string) {
+		for i, e := range entries {
+			num := prefix + strconv.Itoa(i+1)
+			doc = append(doc, Link{URL: "#" + e.Anchor, Label: num + " " + oneLine(e.Text)})
+			walk(e.Children, num+".")
+		}
+	}
+	walk(t, "")
+	return doc
+}
@@ -0,0 +1,36 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildTOC(t *testing.T) {
+	doc := gemtext.ParseString("## Preface\n" +
+		"# Getting Started!\n" +
+		"text\n" +
+		"## Install\n" +
+		"### On Linux\n" +
+		"```\n# not a heading\n```\n" +
+		"## Notes\n" +
+		"# Notes\n" +
+		"## ¿Qué tal?\n" +
+		"# !!!\n")
+
+	toc := gemtext.BuildTOC(doc)
+	require.Equal(t, 8, toc.Len())
+	require.Len(t, toc, 4)
+	require.Equal(t, "getting-started", toc[1].Anchor)
+	require.Equal(t, "on-linux", toc[1].Children[0].Children[0].Anchor)
+
+	require.Equal(t, "=> #preface 1 Preface\n"+
+		"=> #getting-started 2 Getting Started!\n"+
+		"=> #install 2.1 Install\n"+
+		"=> #on-linux 2.1.1 On Linux\n"+
+		"=> #notes 2.2 Notes\n"+
+		"=> #notes-2 3 Notes\n"+
+		"=> #qué-tal 3.1 ¿Qué tal?\n"+
+		"=> #section 4 !!!\n", toc.Document().String())
+}
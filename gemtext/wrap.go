@@ -0,0 +1,90 @@
+package gemtext
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// Wrap returns doc with text and quote lines longer than width runes
+// broken at spaces into several lines, for clients that do not wrap
+// long lines themselves. Links, headings, list items and preformatted
+// text are left untouched, as are words longer than width.
+func Wrap(doc Document, width int) Document {
+	if width <= 0 {
+		return doc
+	}
+	var out Document
+	for _, l := range doc {
+		switch l := l.(type) {
+		case Text:
+			if utf8.RuneCountInString(string(l)) <= width {
+				out = append(out, l)
+				continue
+			}
+			for _, line := range wrapLines(string(l), width, "") {
+				out = append(out, Text(line))
+			}
+		case Quote:
+			if utf8.RuneCountInString(l.String()) <= width {
+				out = append(out, l)
+				continue
+			}
+			for _, line := range wrapLines(string(l), width, "> ") {
+				out = append(out, Quote(line))
+			}
+		default:
+			out = append(out, l)
+		}
+	}
+	return out
+}
+
+// wrapLines wraps text at width, counting prefix, and returns the lines
+// without it. A line that would be parsed as another line type, such as
+// one starting with "=>", is joined to the one before it.
+func wrapLines(text string, width int, prefix string) []string {
+	var lines []string
+	for _, line := range strings.Split(strings.TrimSuffix(wrap(text, width, prefix, prefix), "\n"), "\n") {
+		line = strings.TrimPrefix(line, prefix)
+		if _, ok := ParseLine(line).(Text); !ok && len(lines) > 0 && prefix == "" {
+			lines[len(lines)-1] += " " + line
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Unwrap returns doc with runs of consecutive non-blank text lines, and
+// of consecutive quote lines, joined with spaces into single lines,
+// undoing Wrap and the hard wrapping of text written for fixed width
+// terminals. Blank lines separate paragraphs.
+func Unwrap(doc Document) Document {
+	var out Document
+	for _, l := range doc {
+		if len(out) > 0 {
+			switch l := l.(type) {
+			case Text:
+				if prev, ok := out[len(out)-1].(Text); ok && strings.TrimSpace(string(prev)) != "" && strings.TrimSpace(string(l)) != "" {
+					out[len(out)-1] = Text(joinWrapped(string(prev), string(l)))
+					continue
+				}
+			case Quote:
+				if prev, ok := out[len(out)-1].(Quote); ok {
+					out[len(out)-1] = Quote(joinWrapped(string(prev), string(l)))
+					continue
+				}
+			}
+		}
+		out = append(out, l)
+	}
+	return out
+}
+
+func joinWrapped(a, b string) string {
+	a, b = strings.TrimRight(a, " \t"), strings.TrimLeft(b, " \t")
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + " " + b
+}
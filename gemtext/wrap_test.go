@@ -0,0 +1,72 @@
+package gemtext_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWrap(t *testing.T) {
+	doc := gemtext.ParseString("# A heading longer than the width\n" +
+		"The quick brown fox jumps over the lazy dog.\n" +
+		"short line\n" +
+		"\n" +
+		"see the list of => links\n" +
+		"> a quoted line to wrap\n" +
+		"* a list item that stays long\n" +
+		"=> gemini://example.org/ A link label that stays long\n" +
+		"```\n" +
+		"preformatted text stays long\n" +
+		"```\n" +
+		"unbreakable-very-long-word\n")
+
+	wrapped := gemtext.Wrap(doc, 16).String()
+	require.Equal(t, "# A heading longer than the width\n"+
+		"The quick brown\n"+
+		"fox jumps over\n"+
+		"the lazy dog.\n"+
+		"short line\n"+
+		"\n"+
+		"see the list of => links\n"+
+		"> a quoted line\n"+
+		"> to wrap\n"+
+		"* a list item that stays long\n"+
+		"=> gemini://example.org/ A link label that stays long\n"+
+		"```\n"+
+		"preformatted text stays long\n"+
+		"```\n"+
+		"unbreakable-very-long-word\n", wrapped)
+
+	require.Equal(t, doc, gemtext.Wrap(doc, 0))
+}
+
+func TestUnwrap(t *testing.T) {
+	doc := gemtext.ParseString("The quick brown\n" +
+		"fox jumps over\n" +
+		"the lazy dog.\n" +
+		"\n" +
+		"Second paragraph\n" +
+		"> a quoted line\n" +
+		"> to wrap\n" +
+		"* item\n" +
+		"* item\n" +
+		"```\n" +
+		"pre\n" +
+		"text\n" +
+		"```\n")
+
+	require.Equal(t, "The quick brown fox jumps over the lazy dog.\n"+
+		"\n"+
+		"Second paragraph\n"+
+		"> a quoted line to wrap\n"+
+		"* item\n"+
+		"* item\n"+
+		"```\n"+
+		"pre\n"+
+		"text\n"+
+		"```\n", gemtext.Unwrap(doc).String())
+
+	long := "The quick brown fox jumps over the lazy dog.\n"
+	require.Equal(t, long, gemtext.Unwrap(gemtext.Wrap(gemtext.ParseString(long), 10)).String())
+}
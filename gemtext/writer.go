@@ -0,0 +1,108 @@
+package gemtext
+
+import (
+	"io"
+	"strings"
+)
+
+// Writer writes well formed text/gemini.
+//
+// Text passed to it is normalized so it can not change the meaning of a
+// line: text that would otherwise read as a link, heading, list item,
+// quote or preformat toggle is prefixed with a space, and line breaks in
+// headings, labels, list items and quotes are replaced with spaces.
+//
+// Writes stop at the first error, which Err reports.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+// NewWriter returns a Writer writing to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Err returns the first error writing to the underlying writer.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Heading writes a heading line. level is clamped to 1 to 3.
+func (w *Writer) Heading(level int, text string) {
+	if level < 1 {
+		level = 1
+	}
+	if level > 3 {
+		level = 3
+	}
+	w.line(Heading{Level: level, Text: oneLine(text)}.String())
+}
+
+var urlSpace = strings.NewReplacer(" ", "%20", "\t", "%09", "\n", "%0A", "\r", "%0D")
+
+// Link writes a link line, percent encoding white space in url. An empty
+// label omits it.
+func (w *Writer) Link(url, label string) {
+	w.line(Link{URL: urlSpace.Replace(url), Label: oneLine(label)}.String())
+}
+
+// Text writes text as one text line per line break. An empty text writes
+// a blank line.
+func (w *Writer) Text(text string) {
+	for _, l := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		w.line(escapeText(l))
+	}
+}
+
+// ListItem writes an unordered list item.
+func (w *Writer) ListItem(text string) {
+	w.line(ListItem(oneLine(text)).String())
+}
+
+// Quote writes a quote line.
+func (w *Writer) Quote(text string) {
+	w.line(Quote(oneLine(text)).String())
+}
+
+// Pre writes body as a preformatted block with alt text. Lines of body
+// starting with "```" are prefixed with a space so they do not end the
+// block.
+func (w *Writer) Pre(alt, body string) {
+	w.line("```" + oneLine(alt))
+	body = strings.TrimSuffix(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	if body != "" {
+		for _, l := range strings.Split(body, "\n") {
+			if strings.HasPrefix(l, "```") {
+				l = " " + l
+			}
+			w.line(l)
+		}
+	}
+	w.line("```")
+}
+
+// Line writes a parsed line as it is.
+func (w *Writer) Line(l Line) {
+	w.line(l.String())
+}
+
+func (w *Writer) line(s string) {
+	if w.err != nil {
+		return
+	}
+	_, w.err = io.WriteString(w.w, s+"\n")
+}
+
+func oneLine(s string) string {
+	return strings.TrimSpace(strings.Join(strings.FieldsFunc(s, func(r rune) bool { return r == '\n' || r == '\r' }), " "))
+}
+
+func escapeText(s string) string {
+	for _, p := range []string{"=>", "#", "* ", ">", "```"} {
+		if strings.HasPrefix(s, p) {
+			return " " + s
+		}
+	}
+	return s
+}
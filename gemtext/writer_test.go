@@ -0,0 +1,48 @@
+package gemtext_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+type errWriter struct{}
+
+func (errWriter) Write([]byte) (int, error) { return 0, errors.New("closed") }
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := gemtext.NewWriter(&b)
+	w.Heading(5, "Deep\nheading")
+	w.Link("/a b", "Label\r\nsplit")
+	w.Link("/bare", "")
+	w.Text("=> not a link\n# not a heading\r\nplain")
+	w.Text("")
+	w.ListItem("item")
+	w.Quote("quoted")
+	w.Pre("go", "```\ncode\n")
+	w.Line(gemtext.Text("raw"))
+	require.NoError(t, w.Err())
+	require.Equal(t, "### Deep heading\n"+
+		"=> /a%20b Label split\n"+
+		"=> /bare\n"+
+		" => not a link\n"+
+		" # not a heading\n"+
+		"plain\n"+
+		"\n"+
+		"* item\n"+
+		"> quoted\n"+
+		"```go\n ```\ncode\n```\n"+
+		"raw\n", b.String())
+
+	doc := gemtext.ParseString(b.String())
+	require.Equal(t, gemtext.Text(" => not a link"), doc[3])
+
+	w = gemtext.NewWriter(errWriter{})
+	w.Text("a")
+	w.Text("b")
+	require.EqualError(t, w.Err(), "closed")
+}
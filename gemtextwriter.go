@@ -0,0 +1,142 @@
+package gemini
+
+import (
+	"strings"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// GemtextWriter writes a text/gemini response line by line, so handlers
+// can send large or slowly produced pages without building them in
+// memory first. The 20 text/gemini header is written before the first
+// line, or by Close if there is none.
+//
+// Text is escaped as by gemtext.Writer. Writing anything other than text
+// while a preformatted block is open ends the block first.
+//
+// Methods return the first error writing the response; later calls do
+// nothing and return it again.
+type GemtextWriter struct {
+	w      ResponseWriter
+	gw     *gemtext.Writer
+	header bool
+	pre    bool
+}
+
+// NewGemtextWriter returns a GemtextWriter writing to w.
+func NewGemtextWriter(w ResponseWriter) *GemtextWriter {
+	g := &GemtextWriter{w: w}
+	g.gw = gemtext.NewWriter(gemtextBody{g})
+	return g
+}
+
+type gemtextBody struct{ g *GemtextWriter }
+
+func (b gemtextBody) Write(p []byte) (int, error) {
+	if err := b.g.writeHeader(); err != nil {
+		return 0, err
+	}
+	return b.g.w.WriteBody(p)
+}
+
+func (g *GemtextWriter) writeHeader() error {
+	if g.header {
+		return nil
+	}
+	g.header = true
+	return g.w.WriteStatusMsg(StatusSuccess, "text/gemini")
+}
+
+// WriteHeading writes a heading line. level is clamped to 1 to 3.
+func (g *GemtextWriter) WriteHeading(level int, text string) error {
+	g.endPre()
+	g.gw.Heading(level, text)
+	return g.gw.Err()
+}
+
+// WriteLink writes a link line. An empty label omits it.
+func (g *GemtextWriter) WriteLink(url, label string) error {
+	g.endPre()
+	g.gw.Link(url, label)
+	return g.gw.Err()
+}
+
+// WriteListItem writes a list item line.
+func (g *GemtextWriter) WriteListItem(text string) error {
+	g.endPre()
+	g.gw.ListItem(text)
+	return g.gw.Err()
+}
+
+// WriteQuote writes a quote line.
+func (g *GemtextWriter) WriteQuote(text string) error {
+	g.endPre()
+	g.gw.Quote(text)
+	return g.gw.Err()
+}
+
+// WriteText writes text as one line per line break. Inside a
+// preformatted block the lines are written as they are, except that
+// lines starting with "```" are prefixed with a space so they do not end
+// the block.
+func (g *GemtextWriter) WriteText(text string) error {
+	if !g.pre {
+		g.gw.Text(text)
+		return g.gw.Err()
+	}
+	for _, l := range strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n") {
+		if strings.HasPrefix(l, "```") {
+			l = " " + l
+		}
+		g.gw.Line(gemtext.PreformattedText(l))
+	}
+	return g.gw.Err()
+}
+
+// BeginPre starts a preformatted block with alt text, ending an open one
+// first.
+func (g *GemtextWriter) BeginPre(alt string) error {
+	g.endPre()
+	g.gw.Line(gemtext.PreformatToggle{Alt: strings.Join(strings.Fields(alt), " ")})
+	g.pre = true
+	return g.gw.Err()
+}
+
+// EndPre ends the open preformatted block, if any.
+func (g *GemtextWriter) EndPre() error {
+	g.endPre()
+	return g.gw.Err()
+}
+
+func (g *GemtextWriter) endPre() {
+	if g.pre {
+		g.pre = false
+		g.gw.Line(gemtext.PreformatToggle{})
+	}
+}
+
+// Flush writes the header if needed and sends buffered data to the
+// client if the underlying ResponseWriter is a Flusher. An open
+// preformatted block stays open.
+func (g *GemtextWriter) Flush() error {
+	if err := g.gw.Err(); err != nil {
+		return err
+	}
+	if err := g.writeHeader(); err != nil {
+		return err
+	}
+	if f, ok := g.w.(Flusher); ok {
+		return f.Flush()
+	}
+	return nil
+}
+
+// Close ends an open preformatted block and writes the header if no line
+// has been written. The GemtextWriter can still be used afterwards.
+func (g *GemtextWriter) Close() error {
+	g.endPre()
+	if err := g.gw.Err(); err != nil {
+		return err
+	}
+	return g.writeHeader()
+}
@@ -0,0 +1,40 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGemtextWriter(t *testing.T) {
+	w := &recorder{}
+	g := gemini.NewGemtextWriter(w)
+	require.Zero(t, w.status)
+
+	require.NoError(t, g.WriteHeading(1, "Title"))
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini", w.meta)
+	require.NoError(t, g.WriteText("=> not a link\nplain"))
+	require.NoError(t, g.BeginPre("go  code"))
+	require.NoError(t, g.WriteText("```\nfunc main() {}"))
+	require.NoError(t, g.WriteLink("/next page", "Next"))
+	require.NoError(t, g.WriteListItem("item"))
+	require.NoError(t, g.BeginPre(""))
+	require.NoError(t, g.WriteText("x"))
+	require.NoError(t, g.Close())
+
+	require.Equal(t, "# Title\n"+
+		" => not a link\nplain\n"+
+		"```go code\n ```\nfunc main() {}\n```\n"+
+		"=> /next%20page Next\n"+
+		"* item\n"+
+		"```\nx\n```\n", w.body.String())
+}
+
+func TestGemtextWriterEmpty(t *testing.T) {
+	w := &recorder{}
+	require.NoError(t, gemini.NewGemtextWriter(w).Close())
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Empty(t, w.body.String())
+}
@@ -0,0 +1,70 @@
+package gemini
+
+import (
+	"net"
+	"strings"
+)
+
+// GeoLocator resolves the country of a client address, typically by
+// wrapping a user supplied GeoIP (mmdb) database reader.
+type GeoLocator interface {
+	// Country returns ISO 3166-1 alpha-2 country code for ip, or an
+	// empty string when it is unknown.
+	Country(ip net.IP) (string, error)
+}
+
+// RemoteIP returns the IP address part of r.RemoteAddr, or nil.
+func RemoteIP(r *Request) net.IP {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// CountryPolicy returns middleware that admits requests by client country.
+// When allow is not empty only the listed countries are admitted;
+// otherwise countries in deny are rejected. Requests from addresses that
+// can not be located are admitted only when allow is empty.
+// Rejected requests are answered with 50.
+func CountryPolicy(loc GeoLocator, allow, deny []string) Middleware {
+	return func(next Handler) Handler {
+		return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+			return func(w ResponseWriter, r *Request) {
+				country := lookupCountry(loc, RemoteIP(r))
+				if !countryAllowed(country, allow, deny) {
+					w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Not available in your region"))
+					return
+				}
+				next.ServeGemini(w, r)
+			}
+		})
+	}
+}
+
+func countryAllowed(country string, allow, deny []string) bool {
+	if len(allow) > 0 {
+		return country != "" && containsFold(allow, country)
+	}
+	return !containsFold(deny, country)
+}
+
+func lookupCountry(loc GeoLocator, ip net.IP) string {
+	if loc == nil || ip == nil {
+		return ""
+	}
+	country, err := loc.Country(ip)
+	if err != nil {
+		return ""
+	}
+	return country
+}
+
+func containsFold(list []string, s string) bool {
+	for _, v := range list {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,250 @@
+package gemini
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"os/exec"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// GitHandler serves a read-only view of a local git repository by running
+// the git command. Create it with GitServer.
+//
+// It serves these paths relative to where it is mounted:
+//
+//	/                  summary with links to the tree and the log
+//	/tree/{path...}    directory listing
+//	/blob/{path...}    raw file contents
+//	/log?{page}        paginated commit log
+//	/commit/{hash}     commit message and diff in a preformatted block
+type GitHandler struct {
+	// Ref is the revision that is browsed. Defaults to HEAD.
+	Ref string
+
+	// PageSize is the number of commits per log page. Defaults to 50.
+	PageSize int
+
+	dir string
+}
+
+// GitServer returns a GitHandler for the repository in dir.
+func GitServer(dir string) *GitHandler {
+	return &GitHandler{dir: dir}
+}
+
+var commitHash = regexp.MustCompile(`^[0-9a-f]{4,64}$`)
+
+// ServeGemini serves the repository view named by the request path.
+func (h *GitHandler) ServeGemini(w ResponseWriter, r *Request) {
+	if hasDotDot(r.URL.Path) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	p := path.Clean("/" + r.URL.Path)
+	switch {
+	case p == "/":
+		h.serveSummary(w, r)
+	case p == "/tree" || strings.HasPrefix(p, "/tree/"):
+		h.serveTree(w, r, strings.TrimPrefix(strings.TrimPrefix(p, "/tree"), "/"))
+	case strings.HasPrefix(p, "/blob/"):
+		h.serveBlob(w, r, strings.TrimPrefix(p, "/blob/"))
+	case p == "/log":
+		h.serveLog(w, r)
+	case strings.HasPrefix(p, "/commit/") && commitHash.MatchString(strings.TrimPrefix(p, "/commit/")):
+		h.serveCommit(w, r, strings.TrimPrefix(p, "/commit/"))
+	default:
+		NotFound(w, r)
+	}
+}
+
+func (h *GitHandler) ref() string {
+	if h.Ref != "" {
+		return h.Ref
+	}
+	return "HEAD"
+}
+
+func (h *GitHandler) git(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, "git", append([]string{"-C", h.dir}, args...)...)
+}
+
+func (h *GitHandler) output(ctx context.Context, args ...string) ([]byte, error) {
+	return h.git(ctx, args...).Output()
+}
+
+func (h *GitHandler) serveSummary(w ResponseWriter, r *Request) {
+	out, err := h.output(r.Context(), "log", "-1", "--format=%h %s", h.ref())
+	if err != nil {
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", h.ref())
+	b.WriteString(messagef(r, "Latest commit: %s", strings.TrimSpace(string(out))) + "\n\n")
+	b.WriteString("=> tree/ " + Message(r, "Files") + "\n")
+	b.WriteString("=> log " + Message(r, "Commit log") + "\n")
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+func (h *GitHandler) serveTree(w ResponseWriter, r *Request, dir string) {
+	if !strings.HasSuffix(r.URL.Path, "/") {
+		redirectToDir(w, r)
+		return
+	}
+	spec := h.ref() + ":" + dir
+	out, err := h.output(r.Context(), "ls-tree", "-z", spec)
+	if err != nil {
+		NotFound(w, r)
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# /%s\n\n", dir)
+	if dir != "" {
+		b.WriteString("=> ../ " + Message(r, "Parent directory") + "\n")
+	}
+	for _, entry := range bytes.Split(out, []byte{0}) {
+		// <mode> SP <type> SP <object> TAB <file>
+		tab := bytes.IndexByte(entry, '\t')
+		if tab < 0 {
+			continue
+		}
+		fields := strings.Fields(string(entry[:tab]))
+		name := string(entry[tab+1:])
+		link := (&url.URL{Path: name}).EscapedPath()
+		switch {
+		case len(fields) > 1 && fields[1] == "tree":
+			fmt.Fprintf(&b, "=> %s/ %s/\n", link, name)
+		default:
+			// Climb out of "tree/" and dir to reach the handler root.
+			up := 1
+			if dir != "" {
+				up += strings.Count(dir, "/") + 1
+			}
+			target := (&url.URL{Path: path.Join("blob", dir, name)}).EscapedPath()
+			fmt.Fprintf(&b, "=> %s%s %s\n", strings.Repeat("../", up), target, name)
+		}
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+func (h *GitHandler) serveBlob(w ResponseWriter, r *Request, name string) {
+	spec := h.ref() + ":" + name
+	typ, err := h.output(r.Context(), "cat-file", "-t", spec)
+	if err != nil || strings.TrimSpace(string(typ)) != "blob" {
+		NotFound(w, r)
+		return
+	}
+	h.stream(w, r, TypeByExtension(path.Ext(name)), "", "cat-file", "blob", spec)
+}
+
+func (h *GitHandler) serveLog(w ResponseWriter, r *Request) {
+	page, err := PageNumber(r)
+	if err != nil {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad page number"))
+		return
+	}
+	size := h.PageSize
+	if size <= 0 {
+		size = 50
+	}
+	// One extra commit tells whether there is a next page.
+	out, err := h.output(r.Context(), "log", "--date=short", "--format=%H%x00%ad%x00%an%x00%s",
+		"--skip="+strconv.Itoa((page-1)*size), "--max-count="+strconv.Itoa(size+1), h.ref())
+	if err != nil {
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	lines := strings.Split(strings.TrimSuffix(string(out), "\n"), "\n")
+	if len(lines) == 1 && lines[0] == "" {
+		lines = nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n", messagef(r, "Log, page %d", page))
+	for i, line := range lines {
+		if i == size {
+			break
+		}
+		f := strings.SplitN(line, "\x00", 4)
+		if len(f) != 4 {
+			continue
+		}
+		fmt.Fprintf(&b, "=> commit/%s %s %s: %s\n", f[0], f[1], f[2], f[3])
+	}
+	b.WriteString("\n")
+	if page > 1 {
+		fmt.Fprintf(&b, "=> log?%d %s\n", page-1, Message(r, "Newer commits"))
+	}
+	if len(lines) > size {
+		fmt.Fprintf(&b, "=> log?%d %s\n", page+1, Message(r, "Older commits"))
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+func (h *GitHandler) serveCommit(w ResponseWriter, r *Request, hash string) {
+	if _, err := h.output(r.Context(), "cat-file", "-e", hash+"^{commit}"); err != nil {
+		NotFound(w, r)
+		return
+	}
+	header := "# " + messagef(r, "Commit %s", hash) + "\n\n=> ../log " + Message(r, "Commit log") + "\n\n```diff\n"
+	h.stream(w, r, "text/gemini", header, "show", "--stat", "--patch", "--format=fuller", hash)
+}
+
+// stream writes the output of git to the response. When pre is not empty
+// it is written first and the output follows line by line inside the
+// preformatted block pre opens; lines that would close the block early
+// are indented.
+func (h *GitHandler) stream(w ResponseWriter, r *Request, mimeType string, pre string, args ...string) {
+	ctx, cancel := context.WithCancel(r.Context())
+	cmd := h.git(ctx, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err == nil {
+		err = cmd.Start()
+	}
+	if err != nil {
+		cancel()
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	// Kill git before waiting for it when the client went away mid-stream.
+	defer cmd.Wait()
+	defer cancel()
+	w.WriteStatusMsg(StatusSuccess, mimeType)
+	if pre == "" {
+		if _, err := CopyBody(ctx, w, stdout); err != nil {
+			RecordError(w, err)
+		}
+		return
+	}
+	if _, err := w.WriteBody([]byte(pre)); err != nil {
+		return
+	}
+	br := bufio.NewReader(stdout)
+	for {
+		line, err := br.ReadString('\n')
+		if strings.HasPrefix(line, "```") {
+			line = " " + line
+		}
+		if line != "" {
+			if _, werr := w.WriteBody([]byte(line)); werr != nil {
+				return
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return
+		}
+	}
+	w.WriteBody([]byte("```\n"))
+}
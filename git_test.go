@@ -0,0 +1,83 @@
+package gemini_test
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func gitRepo(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	dir := writeTree(t, map[string]string{
+		"README.gmi":  "# Project",
+		"src/main.go": "package main\n```\n",
+	})
+	run := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Ann", "GIT_AUTHOR_EMAIL=ann@example.com",
+			"GIT_COMMITTER_NAME=Ann", "GIT_COMMITTER_EMAIL=ann@example.com")
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+	}
+	run("init", "-q")
+	run("add", "README.gmi")
+	run("commit", "-q", "-m", "First")
+	run("add", ".")
+	run("commit", "-q", "-m", "Add main")
+	return dir
+}
+
+func TestGitServer(t *testing.T) {
+	h := gemini.GitServer(gitRepo(t))
+	h.PageSize = 1
+
+	w := serve(t, h, "gemini://localhost/")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Contains(t, w.body.String(), "Add main")
+
+	w = serve(t, h, "gemini://localhost/tree")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "./tree/", w.meta)
+
+	w = serve(t, h, "gemini://localhost/tree/")
+	require.Contains(t, w.body.String(), "=> ../blob/README.gmi README.gmi\n")
+	require.Contains(t, w.body.String(), "=> src/ src/\n")
+
+	w = serve(t, h, "gemini://localhost/tree/src/")
+	require.Contains(t, w.body.String(), "=> ../../blob/src/main.go main.go\n")
+
+	w = serve(t, h, "gemini://localhost/blob/README.gmi")
+	require.Equal(t, "text/gemini; charset=utf-8", w.meta)
+	require.Equal(t, "# Project", w.body.String())
+
+	w = serve(t, h, "gemini://localhost/log")
+	body := w.body.String()
+	require.Contains(t, body, "Ann: Add main")
+	require.NotContains(t, body, "First")
+	require.Contains(t, body, "=> log?2 Older commits")
+
+	w = serve(t, h, "gemini://localhost/log?2")
+	require.Contains(t, w.body.String(), "Ann: First")
+	require.NotContains(t, w.body.String(), "Older commits")
+
+	i := strings.Index(body, "=> commit/")
+	hash := body[i+len("=> commit/") : i+len("=> commit/")+40]
+	w = serve(t, h, "gemini://localhost/commit/"+hash)
+	require.Equal(t, "text/gemini", w.meta)
+	require.Contains(t, w.body.String(), "+package main\n")
+	require.Contains(t, w.body.String(), "\n+```\n")
+	require.True(t, strings.HasSuffix(w.body.String(), "\n```\n"))
+
+	for _, p := range []string{"/blob/missing", "/blob/src", "/commit/zzzz", "/commit/0000000", "/tree/missing/"} {
+		w = serve(t, h, "gemini://localhost"+p)
+		require.Equal(t, gemini.StatusNotFound, w.status, p)
+	}
+}
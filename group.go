@@ -0,0 +1,78 @@
+package gemini
+
+import "strings"
+
+// RouteGroup registers routes on a ServeMux under a common path prefix,
+// wrapping each of them in the group's middleware.
+type RouteGroup struct {
+	mux        *ServeMux
+	parent     *RouteGroup
+	prefix     string
+	middleware []Middleware
+}
+
+// Group returns a RouteGroup for patterns under prefix whose handlers are
+// wrapped in mws, e.g. mux.Group("/admin", RequireCertificate).
+func (mux *ServeMux) Group(prefix string, mws ...Middleware) *RouteGroup {
+	return &RouteGroup{mux: mux, prefix: strings.TrimSuffix(prefix, "/"), middleware: mws}
+}
+
+// Group returns a nested group. Its prefix is appended to g's prefix and
+// its middleware runs inside g's middleware.
+func (g *RouteGroup) Group(prefix string, mws ...Middleware) *RouteGroup {
+	return &RouteGroup{
+		mux:        g.mux,
+		parent:     g,
+		prefix:     g.prefix + strings.TrimSuffix(prefix, "/"),
+		middleware: mws,
+	}
+}
+
+// Use appends middleware to the group. It applies to routes registered
+// before and after the call.
+func (g *RouteGroup) Use(mws ...Middleware) {
+	g.mux.mu.Lock()
+	defer g.mux.mu.Unlock()
+	g.middleware = append(g.middleware, mws...)
+}
+
+// Handle registers the handler for the pattern relative to the group
+// prefix.
+func (g *RouteGroup) Handle(pattern string, handler Handler) {
+	g.mux.Handle(g.prefix+pattern, g.wrap(handler))
+}
+
+// HandleFunc registers the handler function for the pattern relative to
+// the group prefix.
+func (g *RouteGroup) HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	g.Handle(pattern, HandlerFunc(handler))
+}
+
+// HandleTitan registers the titan handler for the pattern relative to the
+// group prefix.
+func (g *RouteGroup) HandleTitan(pattern string, handler Handler) {
+	g.mux.HandleTitan(g.prefix+pattern, g.wrap(handler))
+}
+
+// HandleTitanFunc registers the titan handler function for the pattern
+// relative to the group prefix.
+func (g *RouteGroup) HandleTitanFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	g.HandleTitan(pattern, HandlerFunc(handler))
+}
+
+func (g *RouteGroup) wrap(h Handler) Handler {
+	if h == nil {
+		panic("gemini: nil handler")
+	}
+	return wrapTitan(h, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			g.mux.mu.RLock()
+			var mws []Middleware
+			for p := g; p != nil; p = p.parent {
+				mws = append(append([]Middleware(nil), p.middleware...), mws...)
+			}
+			g.mux.mu.RUnlock()
+			Chain(mws...)(next).ServeGemini(w, r)
+		}
+	})
+}
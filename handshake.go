@@ -0,0 +1,79 @@
+package gemini
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strings"
+)
+
+// HandshakeFailure categorizes why a TLS handshake with a client failed.
+type HandshakeFailure int
+
+// Lists TLS handshake failure categories.
+const (
+	HandshakeOther HandshakeFailure = iota
+	HandshakeTimeout
+	HandshakeAborted
+	HandshakeNotTLS
+	HandshakeProtocolVersion
+	HandshakeNoCipherOverlap
+	HandshakeBadCertificate
+)
+
+var handshakeFailureName = map[HandshakeFailure]string{
+	HandshakeOther:           "other",
+	HandshakeTimeout:         "timeout",
+	HandshakeAborted:         "aborted",
+	HandshakeNotTLS:          "not tls",
+	HandshakeProtocolVersion: "protocol version",
+	HandshakeNoCipherOverlap: "no cipher overlap",
+	HandshakeBadCertificate:  "bad client certificate",
+}
+
+func (f HandshakeFailure) String() string {
+	return handshakeFailureName[f]
+}
+
+// HandshakeError is reported by Server.HandshakeError when a TLS handshake
+// with a client fails.
+type HandshakeError struct {
+	Reason HandshakeFailure
+	Err    error
+}
+
+func (e *HandshakeError) Error() string {
+	return "tls handshake failed (" + e.Reason.String() + "): " + e.Err.Error()
+}
+
+func (e *HandshakeError) Unwrap() error {
+	return e.Err
+}
+
+func newHandshakeError(err error) *HandshakeError {
+	return &HandshakeError{Reason: classifyHandshake(err), Err: err}
+}
+
+func classifyHandshake(err error) HandshakeFailure {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return HandshakeTimeout
+	}
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return HandshakeAborted
+	}
+	msg := err.Error()
+	switch {
+	case strings.Contains(msg, "first record does not look like a TLS handshake"):
+		return HandshakeNotTLS
+	case strings.Contains(msg, "unsupported versions"), strings.Contains(msg, "protocol version"):
+		return HandshakeProtocolVersion
+	case strings.Contains(msg, "no cipher suite"), strings.Contains(msg, "no mutually supported"):
+		return HandshakeNoCipherOverlap
+	case strings.Contains(msg, "certificate"):
+		return HandshakeBadCertificate
+	case strings.Contains(msg, "connection reset"):
+		return HandshakeAborted
+	}
+	return HandshakeOther
+}
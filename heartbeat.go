@@ -0,0 +1,137 @@
+package gemini
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// HeartbeatWriter wraps the ResponseWriter of a long-lived streaming
+// response and writes a heartbeat whenever the handler has been silent for
+// an interval, so a dead client is noticed by the failing write instead of
+// holding the handler forever.
+//
+// Heartbeats start once the status line has been written. Stop must be
+// called when the handler is done.
+type HeartbeatWriter struct {
+	w        ResponseWriter
+	interval time.Duration
+	beat     []byte
+	cancel   context.CancelFunc
+
+	mu          sync.Mutex
+	wroteHeader bool
+	last        time.Time
+	err         error
+	stop        chan struct{}
+	stopOnce    sync.Once
+}
+
+// NewHeartbeatWriter wraps w to write beat after interval without writes.
+// The returned context is derived from ctx and canceled when a write fails
+// or Stop is called; streaming handlers should select on it.
+// For text/gemini an empty line ("\n") is a harmless beat. An interval of
+// zero or less disables heartbeats.
+func NewHeartbeatWriter(ctx context.Context, w ResponseWriter, interval time.Duration, beat []byte) (*HeartbeatWriter, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	hw := &HeartbeatWriter{
+		w:        w,
+		interval: interval,
+		beat:     beat,
+		cancel:   cancel,
+		last:     time.Now(),
+		stop:     make(chan struct{}),
+	}
+	if interval > 0 {
+		go hw.run(ctx)
+	}
+	return hw, ctx
+}
+
+// WriteStatusMsg writes the status line.
+func (hw *HeartbeatWriter) WriteStatusMsg(status StatusCode, msg string) error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	err := hw.w.WriteStatusMsg(status, msg)
+	if err != nil {
+		return hw.fail(err)
+	}
+	hw.wroteHeader = true
+	hw.last = time.Now()
+	return nil
+}
+
+// WriteBody writes body bytes and postpones the next heartbeat.
+func (hw *HeartbeatWriter) WriteBody(b []byte) (int, error) {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	if hw.err != nil {
+		return 0, hw.err
+	}
+	n, err := hw.w.WriteBody(b)
+	if err != nil {
+		return n, hw.fail(err)
+	}
+	hw.last = time.Now()
+	return n, nil
+}
+
+// Write implements io.Writer by writing body bytes.
+func (hw *HeartbeatWriter) Write(b []byte) (int, error) {
+	return hw.WriteBody(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter.
+func (hw *HeartbeatWriter) Unwrap() ResponseWriter {
+	return hw.w
+}
+
+// Err returns the error that ended the stream, if any.
+func (hw *HeartbeatWriter) Err() error {
+	hw.mu.Lock()
+	defer hw.mu.Unlock()
+	return hw.err
+}
+
+// Stop ends heartbeats and cancels the context.
+func (hw *HeartbeatWriter) Stop() {
+	hw.stopOnce.Do(func() {
+		close(hw.stop)
+		hw.cancel()
+	})
+}
+
+// fail records err and cancels the context. hw.mu must be held.
+func (hw *HeartbeatWriter) fail(err error) error {
+	if hw.err == nil {
+		hw.err = err
+	}
+	hw.cancel()
+	return hw.err
+}
+
+func (hw *HeartbeatWriter) run(ctx context.Context) {
+	d := hw.interval / 2
+	if d <= 0 {
+		d = hw.interval
+	}
+	tick := time.NewTicker(d)
+	defer tick.Stop()
+	for {
+		select {
+		case <-hw.stop:
+			return
+		case <-ctx.Done():
+			return
+		case now := <-tick.C:
+			hw.mu.Lock()
+			if hw.wroteHeader && hw.err == nil && now.Sub(hw.last) >= hw.interval {
+				if _, err := hw.w.WriteBody(hw.beat); err != nil {
+					hw.fail(err)
+				}
+				hw.last = now
+			}
+			hw.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package gemini
+
+import (
+	"strings"
+	"sync"
+)
+
+// HostMux dispatches requests by the host name in the request URL, so one
+// server can host several capsules behind a single (wildcard) certificate.
+//
+// Hosts are matched case-insensitively and without the port. A pattern of
+// the form "*.example.com" matches any subdomain of example.com; exact
+// names take precedence over wildcards, and longer wildcards over shorter
+// ones.
+type HostMux struct {
+	// Default serves requests for unknown hosts. If nil, such requests
+	// are answered with 53 PROXY REQUEST REFUSED.
+	Default Handler
+
+	mu    sync.RWMutex
+	hosts map[string]Handler
+}
+
+// NewHostMux allocates and returns a new HostMux.
+func NewHostMux() *HostMux {
+	return &HostMux{}
+}
+
+// Handle registers the handler for the given host.
+// If a handler already exists for host, Handle panics.
+func (mux *HostMux) Handle(host string, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+
+	if host == "" {
+		panic("gemini: empty host")
+	}
+	if handler == nil {
+		panic("gemini: nil handler")
+	}
+	host = strings.ToLower(host)
+	if _, exist := mux.hosts[host]; exist {
+		panic("gemini: multiple registrations for host " + host)
+	}
+	if mux.hosts == nil {
+		mux.hosts = make(map[string]Handler)
+	}
+	mux.hosts[host] = handler
+}
+
+// HandleFunc registers the handler function for the given host.
+func (mux *HostMux) HandleFunc(host string, handler func(ResponseWriter, *Request)) {
+	mux.Handle(host, HandlerFunc(handler))
+}
+
+// Handler returns the handler registered for host, or nil.
+func (mux *HostMux) Handler(host string) Handler {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	host = strings.ToLower(host)
+	if h, ok := mux.hosts[host]; ok {
+		return h
+	}
+	for i := strings.IndexByte(host, '.'); i >= 0; {
+		if h, ok := mux.hosts["*"+host[i:]]; ok {
+			return h
+		}
+		next := strings.IndexByte(host[i+1:], '.')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return nil
+}
+
+// ServeGemini dispatches the request to the handler registered for the
+// request URL's host.
+func (mux *HostMux) ServeGemini(w ResponseWriter, r *Request) {
+	if h := mux.handler(r); h != nil {
+		h.ServeGemini(w, r)
+		return
+	}
+	w.WriteStatusMsg(StatusProxyRefused, Message(r, "Proxy Request Refused"))
+}
+
+// ServeTitan dispatches the titan request like ServeGemini.
+func (mux *HostMux) ServeTitan(w ResponseWriter, r *Request) {
+	if h := mux.handler(r); h != nil {
+		serveTitan(h, w, r)
+		return
+	}
+	w.WriteStatusMsg(StatusProxyRefused, Message(r, "Proxy Request Refused"))
+}
+
+// handler returns the handler for the request URL's host, falling back
+// to Default.
+func (mux *HostMux) handler(r *Request) Handler {
+	if h := mux.Handler(r.URL.Hostname()); h != nil {
+		return h
+	}
+	return mux.Default
+}
@@ -0,0 +1,54 @@
+package gemini_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHostMux(t *testing.T) {
+	mux := gemini.NewHostMux()
+	mux.Handle("example.com", text("example"))
+	mux.Handle("*.example.com", text("wildcard"))
+	mux.Handle("*.blog.example.com", text("blog"))
+	mux.Handle("docs.example.com", text("docs"))
+
+	for host, want := range map[string]string{
+		"example.com":              "example",
+		"EXAMPLE.com:1966":         "example",
+		"docs.example.com":         "docs",
+		"www.example.com":          "wildcard",
+		"a.b.example.com":          "wildcard",
+		"me.blog.example.com":      "blog",
+		"deep.me.blog.example.com": "blog",
+	} {
+		w := serve(t, mux, "gemini://"+host+"/")
+		require.Equal(t, want, w.body.String(), host)
+	}
+
+	w := serve(t, mux, "gemini://other.org/")
+	require.Equal(t, gemini.StatusProxyRefused, w.status)
+
+	mux.Default = text("default")
+	w = serve(t, mux, "gemini://other.org/")
+	require.Equal(t, "default", w.body.String())
+}
+
+func TestHostMuxTitan(t *testing.T) {
+	dir := t.TempDir()
+	mux := gemini.NewHostMux()
+	mux.Handle("localhost", gemini.NewFileStore(dir))
+	addr := startServer(t, &gemini.Server{Handler: mux})
+
+	header, _ := roundTrip(t, addr, "titan://localhost/a.gmi;size=4\r\n# A\n")
+	require.Equal(t, "30 gemini://localhost/a.gmi\r\n", header)
+	b, err := os.ReadFile(filepath.Join(dir, "a.gmi"))
+	require.NoError(t, err)
+	require.Equal(t, "# A\n", string(b))
+
+	header, _ = roundTrip(t, addr, "titan://other.org/a.gmi;size=1\r\nx")
+	require.Equal(t, "53 Proxy Request Refused\r\n", header)
+}
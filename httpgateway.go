@@ -0,0 +1,168 @@
+package gemini
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// HTTPGateway is a handler forwarding requests to an HTTP backend, so
+// existing HTTP services can be served inside a capsule.
+//
+// The request path and query are appended to Backend. Gemini requests
+// become GET requests and titan uploads PUT requests carrying the
+// payload. The client address is sent in X-Forwarded-For and the client
+// certificate fingerprint, if any, in X-Client-Cert-Fingerprint.
+//
+// Successful responses are sent with their Content-Type as the meta.
+// Redirects become 30 or 31, and errors map to the closest Gemini status:
+// 400 to 59, 401 to 60, 403 to 61, 404 to 51, 410 to 52, 429 to 44,
+// 503 to 41, other 4xx to 50 and other 5xx to 40. A backend that can not
+// be reached is answered with 43 PROXY ERROR.
+type HTTPGateway struct {
+	// Backend is the base URL requests are forwarded to.
+	Backend *url.URL
+
+	// Client sends the backend requests. If nil, a client with
+	// http.DefaultTransport is used. Redirects are never followed.
+	Client *http.Client
+}
+
+// ServeTitan forwards the upload to the backend.
+func (g *HTTPGateway) ServeTitan(w ResponseWriter, r *Request) {
+	g.ServeGemini(w, r)
+}
+
+// ServeGemini forwards the request to the backend. Paths with ".."
+// segments are answered with 59, so requests cannot reach backend paths
+// outside Backend.
+func (g *HTTPGateway) ServeGemini(w ResponseWriter, r *Request) {
+	if hasDotDot(r.URL.Path) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	target := *g.Backend
+	target.Path = strings.TrimSuffix(target.Path, "/") + r.URL.Path
+	target.RawPath = ""
+	target.RawQuery = r.URL.RawQuery
+
+	method, body := http.MethodGet, io.Reader(nil)
+	if r.URL.Scheme == SchemaTitan {
+		method, body = http.MethodPut, r.Titan.Reader()
+	}
+	req, err := http.NewRequestWithContext(r.Context(), method, target.String(), body)
+	if err != nil {
+		w.WriteStatusMsg(StatusProxyError, Message(r, "Proxy Error"))
+		return
+	}
+	if r.URL.Scheme == SchemaTitan {
+		req.ContentLength = r.Titan.Size
+		if r.Titan.Mime != "" {
+			req.Header.Set("Content-Type", r.Titan.Mime)
+		}
+	}
+	if ip := RemoteIP(r); ip != nil {
+		req.Header.Set("X-Forwarded-For", ip.String())
+	}
+	if cert := r.Certificate(); cert != nil {
+		req.Header.Set("X-Client-Cert-Fingerprint", Fingerprint(cert))
+	}
+
+	resp, err := g.client().Do(req)
+	if err != nil {
+		w.WriteStatusMsg(StatusProxyError, Message(r, "Proxy Error"))
+		return
+	}
+	defer resp.Body.Close()
+
+	switch code := resp.StatusCode; {
+	case code >= 200 && code < 300:
+		mimeType := resp.Header.Get("Content-Type")
+		if mimeType == "" {
+			mimeType = "application/octet-stream"
+		}
+		w.WriteStatusMsg(StatusSuccess, mimeType)
+		if _, err := CopyBody(r.Context(), w, resp.Body); err != nil {
+			RecordError(w, err)
+		}
+	case code >= 300 && code < 400 && resp.Header.Get("Location") != "":
+		status := StatusTemporaryRedirect
+		if code == http.StatusMovedPermanently || code == http.StatusPermanentRedirect {
+			status = StatusPermanentRedirect
+		}
+		w.WriteStatusMsg(status, g.location(r, resp))
+	default:
+		status, meta := httpErrorStatus(resp)
+		w.WriteStatusMsg(status, meta)
+	}
+}
+
+func (g *HTTPGateway) client() *http.Client {
+	c := g.Client
+	if c == nil {
+		c = &http.Client{}
+	}
+	if c.CheckRedirect == nil {
+		c2 := *c
+		c2.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+		c = &c2
+	}
+	return c
+}
+
+// location maps a redirect target on the backend back into the capsule.
+// Targets on other hosts are passed through unchanged.
+func (g *HTTPGateway) location(r *Request, resp *http.Response) string {
+	loc, err := resp.Location()
+	if err != nil {
+		return resp.Header.Get("Location")
+	}
+	if loc.Host != g.Backend.Host {
+		return loc.String()
+	}
+	base := strings.TrimSuffix(g.Backend.Path, "/")
+	if loc.Path != base && !strings.HasPrefix(loc.Path, base+"/") {
+		return loc.String()
+	}
+	u := *r.URL
+	u.Path = path.Join("/", strings.TrimPrefix(loc.Path, base))
+	if strings.HasSuffix(loc.Path, "/") && u.Path != "/" {
+		u.Path += "/"
+	}
+	u.RawPath = ""
+	u.RawQuery = loc.RawQuery
+	return u.String()
+}
+
+func httpErrorStatus(resp *http.Response) (StatusCode, string) {
+	text := http.StatusText(resp.StatusCode)
+	switch code := resp.StatusCode; {
+	case code == http.StatusBadRequest:
+		return StatusBadRequest, text
+	case code == http.StatusUnauthorized:
+		return StatusCertRequired, text
+	case code == http.StatusForbidden:
+		return StatusCertNotAuthorized, text
+	case code == http.StatusNotFound:
+		return StatusNotFound, text
+	case code == http.StatusGone:
+		return StatusGone, text
+	case code == http.StatusTooManyRequests:
+		retry := resp.Header.Get("Retry-After")
+		if _, err := strconv.Atoi(retry); err != nil {
+			retry = "1"
+		}
+		return StatusSlowDown, retry
+	case code == http.StatusServiceUnavailable:
+		return StatusServerUnavalable, text
+	case code >= 400 && code < 500:
+		return StatusGeneralPermFail, text
+	default:
+		return StatusUnspecified, text
+	}
+}
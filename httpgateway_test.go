@@ -0,0 +1,76 @@
+package gemini_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPGateway(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/hello":
+			w.Header().Set("Content-Type", "text/gemini")
+			fmt.Fprintf(w, "# Hello %s from %s", r.URL.RawQuery, r.Header.Get("X-Forwarded-For"))
+		case "/api/upload":
+			b, _ := io.ReadAll(r.Body)
+			w.Header().Set("Content-Type", "text/plain")
+			fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Content-Type"), b)
+		case "/api/old":
+			http.Redirect(w, r, "/api/new?x=1", http.StatusMovedPermanently)
+		case "/api/busy":
+			w.Header().Set("Retry-After", "30")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/api/boom":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer backend.Close()
+	u, err := url.Parse(backend.URL + "/api")
+	require.NoError(t, err)
+	g := &gemini.HTTPGateway{Backend: u}
+
+	r := &gemini.Request{RemoteAddr: "192.0.2.1:5000"}
+	require.NoError(t, r.Reset(nil, "gemini://localhost/hello?world"))
+	w := &recorder{}
+	g.ServeGemini(w, r)
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini", w.meta)
+	require.Equal(t, "# Hello world from 192.0.2.1", w.body.String())
+
+	r = &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/upload;mime=text/plain;size=2"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("hi there"))
+	w = &recorder{}
+	g.ServeGemini(w, r)
+	require.Equal(t, "PUT text/plain hi", w.body.String())
+
+	w = serve(t, g, "gemini://localhost/../admin")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	w = serve(t, g, "gemini://localhost/x/%2E%2E/%2E%2E/admin")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+
+	w = serve(t, g, "gemini://localhost/old")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "gemini://localhost/new?x=1", w.meta)
+
+	w = serve(t, g, "gemini://localhost/busy")
+	require.Equal(t, gemini.StatusSlowDown, w.status)
+	require.Equal(t, "30", w.meta)
+
+	require.Equal(t, gemini.StatusUnspecified, serve(t, g, "gemini://localhost/boom").status)
+	require.Equal(t, gemini.StatusNotFound, serve(t, g, "gemini://localhost/missing").status)
+
+	down, _ := url.Parse("http://127.0.0.1:1/")
+	w = serve(t, &gemini.HTTPGateway{Backend: down}, "gemini://localhost/")
+	require.Equal(t, gemini.StatusProxyError, w.status)
+}
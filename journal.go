@@ -0,0 +1,126 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultJournalSocket is the socket of the systemd journal.
+const DefaultJournalSocket = "/run/systemd/journal/socket"
+
+// Journal sends log messages to the systemd journal using its native
+// protocol, so it only works on Linux systems running systemd. The zero
+// value is ready to use and connects on the first write.
+//
+// Write logs text, so a Journal can be the output of Server.ErrorLog.
+// LogAccess implements AccessLogger and logs requests with the structured
+// fields GEMINI_URL, GEMINI_STATUS, GEMINI_META, GEMINI_BYTES,
+// GEMINI_DURATION_MS, REMOTE_ADDR and, when set, GEMINI_COUNTRY and
+// GEMINI_ERROR:
+//
+//	j := &gemini.Journal{Identifier: "capsule"}
+//	srv.ErrorLog = log.New(j, "", 0)
+//	srv.AccessLog = j
+//
+// Messages larger than the socket's datagram size are not supported.
+type Journal struct {
+	// Identifier is sent as SYSLOG_IDENTIFIER, if set.
+	Identifier string
+
+	// Socket is the journal socket. Defaults to DefaultJournalSocket.
+	Socket string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// Write sends p, without a trailing line break, as an informational
+// message.
+func (j *Journal) Write(p []byte) (int, error) {
+	err := j.send([][2]string{
+		{"MESSAGE", strings.TrimRight(string(p), "\n")},
+		{"PRIORITY", "6"},
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// LogAccess sends e as a message with structured fields. Responses with
+// status 40 and above are logged as warnings.
+func (j *Journal) LogAccess(e AccessLogEntry) {
+	priority := "6"
+	if e.Status >= 40 {
+		priority = "4"
+	}
+	fields := [][2]string{
+		{"MESSAGE", strconv.Itoa(int(e.Status)) + " " + e.URL},
+		{"PRIORITY", priority},
+		{"GEMINI_URL", e.URL},
+		{"GEMINI_STATUS", strconv.Itoa(int(e.Status))},
+		{"GEMINI_META", e.Meta},
+		{"GEMINI_BYTES", strconv.FormatInt(e.Bytes, 10)},
+		{"GEMINI_DURATION_MS", strconv.FormatInt(e.Duration.Milliseconds(), 10)},
+		{"REMOTE_ADDR", e.RemoteAddr},
+	}
+	if e.Country != "" {
+		fields = append(fields, [2]string{"GEMINI_COUNTRY", e.Country})
+	}
+	if e.Err != nil {
+		fields = append(fields, [2]string{"GEMINI_ERROR", e.Err.Error()})
+	}
+	_ = j.send(fields)
+}
+
+// Close closes the connection to the journal.
+func (j *Journal) Close() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+func (j *Journal) send(fields [][2]string) error {
+	var b bytes.Buffer
+	if j.Identifier != "" {
+		fields = append(fields, [2]string{"SYSLOG_IDENTIFIER", j.Identifier})
+	}
+	for _, f := range fields {
+		b.WriteString(f[0])
+		if strings.ContainsRune(f[1], '\n') {
+			// Values with line breaks are sent length prefixed.
+			b.WriteByte('\n')
+			binary.Write(&b, binary.LittleEndian, uint64(len(f[1])))
+			b.WriteString(f[1])
+		} else {
+			b.WriteByte('=')
+			b.WriteString(f[1])
+		}
+		b.WriteByte('\n')
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.conn == nil {
+		socket := j.Socket
+		if socket == "" {
+			socket = DefaultJournalSocket
+		}
+		conn, err := net.Dial("unixgram", socket)
+		if err != nil {
+			return err
+		}
+		j.conn = conn
+	}
+	_, err := j.conn.Write(b.Bytes())
+	return err
+}
@@ -0,0 +1,114 @@
+package gemini
+
+import (
+	"crypto/tls"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// LoadShedder rejects new requests with 44 SLOW DOWN while the process is
+// under pressure. Zero thresholds are disabled.
+//
+// The check runs as soon as a connection is accepted, so a shed request
+// costs the TLS handshake needed to deliver the status, but the request is
+// never read and no handler runs.
+type LoadShedder struct {
+	// MaxGoroutines sheds load while runtime.NumGoroutine exceeds it.
+	MaxGoroutines int
+
+	// MaxHeapBytes sheds load while allocated heap exceeds it.
+	// Memory statistics are sampled at most once per second.
+	MaxHeapBytes uint64
+
+	// MaxLatency sheds load while the moving average of handler
+	// durations exceeds it.
+	MaxLatency time.Duration
+
+	// RetryAfter is reported to clients in the 44 meta.
+	// Defaults to 10 seconds.
+	RetryAfter time.Duration
+
+	// Priority optionally lists SHA-256 fingerprints (see Fingerprint) of
+	// client certificates whose requests are never shed, so operators can
+	// reach their capsule while it is overloaded.
+	Priority []string
+
+	shed uint64
+
+	mu       sync.Mutex
+	latency  time.Duration
+	heap     uint64
+	heapRead time.Time
+}
+
+// ShedCount returns the number of requests rejected so far.
+func (l *LoadShedder) ShedCount() uint64 {
+	return atomic.LoadUint64(&l.shed)
+}
+
+// Overloaded reports whether any threshold is currently exceeded.
+func (l *LoadShedder) Overloaded() bool {
+	if l.MaxGoroutines > 0 && runtime.NumGoroutine() > l.MaxGoroutines {
+		return true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.MaxLatency > 0 && l.latency > l.MaxLatency {
+		return true
+	}
+	if l.MaxHeapBytes > 0 {
+		if time.Since(l.heapRead) > time.Second {
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			l.heap = ms.HeapAlloc
+			l.heapRead = time.Now()
+		}
+		if l.heap > l.MaxHeapBytes {
+			return true
+		}
+	}
+	return false
+}
+
+// prioritized reports whether conn, after its handshake, presents a
+// Priority certificate.
+func (l *LoadShedder) prioritized(conn *tls.Conn) bool {
+	if len(l.Priority) == 0 {
+		return false
+	}
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return false
+	}
+	fp := Fingerprint(certs[0])
+	for _, p := range l.Priority {
+		if strings.EqualFold(p, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// observe folds a handler duration into the moving average.
+func (l *LoadShedder) observe(d time.Duration) {
+	if l.MaxLatency <= 0 {
+		return
+	}
+	l.mu.Lock()
+	l.latency += (d - l.latency) / 8
+	l.mu.Unlock()
+}
+
+// reject writes the 44 response and counts it.
+func (l *LoadShedder) reject(w ResponseWriter) {
+	atomic.AddUint64(&l.shed, 1)
+	retry := l.RetryAfter
+	if retry <= 0 {
+		retry = 10 * time.Second
+	}
+	_ = w.WriteStatusMsg(StatusSlowDown, strconv.Itoa(int(retry.Seconds())))
+}
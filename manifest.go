@@ -0,0 +1,69 @@
+package gemini
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ManifestPath is the well-known path a capsule's Manifest is served at.
+const ManifestPath = "/.well-known/capsule.json"
+
+// Manifest is a machine-readable description of a capsule, so clients
+// and crawlers can discover its feeds and endpoints without scraping its
+// pages. It is served as JSON at ManifestPath:
+//
+//	mux.Handle(gemini.ManifestPath, gemini.ManifestHandler(&gemini.Manifest{
+//		Name:    "Example capsule",
+//		Contact: "mailto:admin@example.org",
+//		Feeds:   []string{"/gemlog/"},
+//		Search:  "/search",
+//	}, mux))
+//
+// URLs may be relative to the manifest's.
+type Manifest struct {
+	Name        string `json:"name,omitempty"`
+	Description string `json:"description,omitempty"`
+
+	// Contact is a URL to reach the capsule's author, such as a mailto
+	// URL.
+	Contact string `json:"contact,omitempty"`
+
+	// Feeds are the capsule's gemfeeds and Atom feeds.
+	Feeds []string `json:"feeds,omitempty"`
+
+	// Uploads are the Titan upload endpoints. Patterns ending in a slash
+	// accept uploads below them.
+	Uploads []string `json:"uploads,omitempty"`
+
+	// Search is an endpoint prompting for a search query.
+	Search string `json:"search,omitempty"`
+}
+
+// ManifestHandler returns a handler serving m. If mux is not nil and m
+// lists no uploads, the patterns registered with mux.HandleTitan are
+// listed, read when the manifest is requested.
+func ManifestHandler(m *Manifest, mux *ServeMux) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		out := *m
+		if mux != nil && len(out.Uploads) == 0 {
+			out.Uploads = mux.TitanPatterns()
+		}
+		body, err := json.MarshalIndent(&out, "", "  ")
+		if err != nil {
+			w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+			return
+		}
+		w.WriteStatusMsg(StatusSuccess, "application/json")
+		w.WriteBody(append(body, '\n'))
+	})
+}
+
+// ParseManifest reads a manifest fetched from a capsule's ManifestPath.
+func ParseManifest(r io.Reader) (*Manifest, error) {
+	var m Manifest
+	if err := json.NewDecoder(r).Decode(&m); err != nil {
+		return nil, fmt.Errorf("gemini: invalid manifest: %w", err)
+	}
+	return &m, nil
+}
@@ -0,0 +1,42 @@
+package gemini_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.HandleTitan("/wiki/", text("ok"))
+	mux.HandleTitan("/upload", text("ok"))
+	m := &gemini.Manifest{
+		Name:    "Example",
+		Contact: "mailto:admin@example.org",
+		Feeds:   []string{"/gemlog/"},
+		Search:  "/search",
+	}
+	mux.Handle(gemini.ManifestPath, gemini.ManifestHandler(m, mux))
+
+	w := serve(t, mux, "gemini://localhost/.well-known/capsule.json")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "application/json", w.meta)
+	require.JSONEq(t, `{
+		"name": "Example",
+		"contact": "mailto:admin@example.org",
+		"feeds": ["/gemlog/"],
+		"uploads": ["/upload", "/wiki/"],
+		"search": "/search"
+	}`, w.body.String())
+
+	got, err := gemini.ParseManifest(strings.NewReader(w.body.String()))
+	require.NoError(t, err)
+	require.Equal(t, []string{"/upload", "/wiki/"}, got.Uploads)
+	require.Equal(t, m.Search, got.Search)
+	require.Nil(t, m.Uploads)
+
+	_, err = gemini.ParseManifest(strings.NewReader("# not json"))
+	require.Error(t, err)
+}
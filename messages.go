@@ -0,0 +1,104 @@
+package gemini
+
+import (
+	"context"
+	"fmt"
+)
+
+// Messages translates the English status messages and page texts
+// generated by the package, such as "404 Resource Not Found" or
+// "Index of %s", keyed by the English text. Texts with formatting verbs
+// must keep them in their translation. Texts without a translation are
+// used as they are; BuiltinMessages lists all of them.
+//
+// Install a catalogue for all requests with Server.Messages or for a
+// part of a capsule, such as one virtual host, with Localize:
+//
+//	hosts.Handle("example.de", gemini.Localize(german)(site))
+type Messages map[string]string
+
+// BuiltinMessages lists the texts Messages may translate.
+var BuiltinMessages = []string{
+	"404 Resource Not Found",
+	"Authors",
+	"Available: %s",
+	"Bad Request",
+	"Bad page number",
+	"CGI Error",
+	"CGI Timeout",
+	"Certificate Not Authorized",
+	"Certificate Not Valid",
+	"Certificate Required",
+	"Challenge expired",
+	"Challenge failed",
+	"Commit %s",
+	"Commit log",
+	"Files",
+	"Form expired",
+	"Index of %s",
+	"Internal Server Error",
+	"Invalid gemtext: %s",
+	"Invalid Token",
+	"Latest commit: %s",
+	"Log, page %d",
+	"More space from: %s",
+	"Newer commits",
+	"Next page",
+	"Not available in your region",
+	"Older commits",
+	"Page %d of %d",
+	"Parent directory",
+	"Permission Denied",
+	"Previous page",
+	"Proxy Error",
+	"Proxy Request Refused",
+	"Submission rejected",
+	"Tag: %s",
+	"Tags",
+	"Temporarily unavailable",
+	"Titan requests not supported",
+	"Unavailable until %s",
+	"Unsupported format %s",
+	"Upload exceeds %d bytes",
+	"Upload exceeds quota of %d bytes",
+	"Upload quota",
+	"Upload type %s not allowed",
+	"Used: %s of %s",
+	"What is %d plus %d?",
+	"Width must be between 1 and %d",
+	"Window: %s",
+	"Wrong answer. %s",
+}
+
+type messagesContextKey struct{}
+
+// Localize returns a Middleware making the handlers below it use msgs,
+// overriding Server.Messages.
+func Localize(msgs Messages) Middleware {
+	return func(next Handler) Handler {
+		return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+			return func(w ResponseWriter, r *Request) {
+				next.ServeGemini(w, r.WithContext(context.WithValue(r.Context(), messagesContextKey{}, msgs)))
+			}
+		})
+	}
+}
+
+// Message returns the translation of msg in the Messages installed for
+// r, or msg if there is none.
+func Message(r *Request, msg string) string {
+	if r == nil {
+		return msg
+	}
+	if msgs, _ := r.Context().Value(messagesContextKey{}).(Messages); msgs != nil {
+		if t, ok := msgs[msg]; ok {
+			return t
+		}
+	}
+	return msg
+}
+
+// messagef formats the translation of format for r.
+func messagef(r *Request, format string, args ...interface{}) string {
+	return fmt.Sprintf(Message(r, format), args...)
+}
@@ -0,0 +1,46 @@
+package gemini_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+var german = gemini.Messages{
+	"404 Resource Not Found": "404 Nicht gefunden",
+	"Index of %s":            "Inhalt von %s",
+	"Parent directory":       "Übergeordnetes Verzeichnis",
+	"Proxy Request Refused":  "Proxy-Anfrage abgelehnt",
+}
+
+func TestLocalize(t *testing.T) {
+	fsrv := gemini.FileServerFS(fstest.MapFS{"sub/a.gmi": {Data: []byte("a")}})
+	fsrv.Listing = true
+	hosts := gemini.NewHostMux()
+	hosts.Handle("example.de", gemini.Localize(german)(fsrv))
+	hosts.Handle("example.com", fsrv)
+
+	w := serve(t, hosts, "gemini://example.de/missing")
+	require.Equal(t, "404 Nicht gefunden", w.meta)
+	w = serve(t, hosts, "gemini://example.de/sub/")
+	require.Equal(t, "# Inhalt von /sub/\n\n=> ../ Übergeordnetes Verzeichnis\n=> a.gmi a.gmi (1 B)\n", w.body.String())
+
+	w = serve(t, hosts, "gemini://example.com/missing")
+	require.Equal(t, "404 Resource Not Found", w.meta)
+	require.Equal(t, "Proxy Request Refused", serve(t, hosts, "gemini://example.org/").meta)
+}
+
+func TestServerMessages(t *testing.T) {
+	srv := &gemini.Server{
+		Handler:  gemini.HandlerFunc(gemini.NotFound),
+		Hosts:    []string{"localhost"},
+		Messages: german,
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "51 404 Nicht gefunden\r\n", header)
+	header, _ = roundTrip(t, addr, "gemini://example.org/")
+	require.Equal(t, "53 Proxy-Anfrage abgelehnt\r\n", header)
+}
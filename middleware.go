@@ -0,0 +1,64 @@
+package gemini
+
+import (
+	"log"
+	"runtime/debug"
+)
+
+// Middleware wraps a Handler to add behaviour before or after it runs.
+type Middleware func(Handler) Handler
+
+// Chain composes middlewares into one. The first middleware is the
+// outermost, so Chain(a, b)(h) is equivalent to a(b(h)). If h implements
+// TitanHandler, so does the result, with titan requests passing through
+// the middlewares to h's ServeTitan.
+func Chain(mws ...Middleware) Middleware {
+	return func(h Handler) Handler {
+		return wrapTitan(h, func(next HandlerFunc) HandlerFunc {
+			var chained Handler = next
+			for i := len(mws) - 1; i >= 0; i-- {
+				chained = mws[i](chained)
+			}
+			return chained.ServeGemini
+		})
+	}
+}
+
+// wrapTitan returns the handler wrap builds around next. If next
+// implements TitanHandler, so does the returned handler: its ServeTitan
+// runs the same wrapping around next's ServeTitan. Middleware of the
+// package uses it so uploads still reach handlers serving them apart.
+func wrapTitan(next Handler, wrap func(next HandlerFunc) HandlerFunc) Handler {
+	h := wrap(next.ServeGemini)
+	if th, ok := next.(TitanHandler); ok {
+		return titanWrapper{h, wrap(th.ServeTitan)}
+	}
+	return h
+}
+
+// titanWrapper is a handler returned by wrapTitan for a TitanHandler.
+type titanWrapper struct {
+	HandlerFunc
+	titan HandlerFunc
+}
+
+func (h titanWrapper) ServeTitan(w ResponseWriter, r *Request) {
+	h.titan(w, r)
+}
+
+// TrapPanic is a Middleware recovering panics in next, logging them and
+// responding with 40 if possible.
+func TrapPanic(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, req *Request) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("Trapped: %v", r)
+					debug.PrintStack()
+					w.WriteStatusMsg(StatusUnspecified, Message(req, "Internal Server Error"))
+				}
+			}()
+			next.ServeGemini(w, req)
+		}
+	})
+}
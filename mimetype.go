@@ -0,0 +1,108 @@
+package gemini
+
+import (
+	"mime"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MIMETypes maps file name extensions to MIME types, including any lang
+// or charset parameters, for example "text/gemini; lang=en".
+// Extensions it does not know are looked up with mime.TypeByExtension
+// and default to application/octet-stream.
+//
+// The zero value has no entries of its own; use NewMIMETypes for one
+// with the Gemini defaults.
+type MIMETypes struct {
+	mu    sync.RWMutex
+	types map[string]string
+}
+
+// DefaultMIMETypes is used by FileServer and ServeContent unless
+// configured otherwise.
+var DefaultMIMETypes = NewMIMETypes()
+
+// NewMIMETypes returns a registry mapping .gmi and .gemini to
+// text/gemini; charset=utf-8, and the archive types ArchiveServer
+// serves.
+func NewMIMETypes() *MIMETypes {
+	m := &MIMETypes{}
+	m.Add(".gmi", "text/gemini; charset=utf-8")
+	m.Add(".gemini", "text/gemini; charset=utf-8")
+	m.Add(".zip", "application/zip")
+	m.Add(".epub", "application/epub+zip")
+	return m
+}
+
+// Add sets the MIME type for ext, which must begin with a dot.
+// Extensions are matched case-insensitively. It panics if typ does not
+// parse as a media type.
+func (m *MIMETypes) Add(ext, typ string) {
+	if _, _, err := mime.ParseMediaType(typ); err != nil {
+		panic("gemini: invalid MIME type " + typ)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.types == nil {
+		m.types = make(map[string]string)
+	}
+	m.types[strings.ToLower(ext)] = typ
+}
+
+// TypeByExtension returns the MIME type for ext.
+func (m *MIMETypes) TypeByExtension(ext string) string {
+	m.mu.RLock()
+	t, ok := m.types[strings.ToLower(ext)]
+	m.mu.RUnlock()
+	if ok {
+		return t
+	}
+	if t := mime.TypeByExtension(ext); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+// TypeByExtension returns the MIME type for ext from DefaultMIMETypes.
+func TypeByExtension(ext string) string {
+	return DefaultMIMETypes.TypeByExtension(ext)
+}
+
+// ExtensionByType returns an extension, with a leading dot, for files of
+// the MIME type typ, or an empty string if there is none. Extensions
+// added to m are preferred over the system's, shorter ones over longer.
+func (m *MIMETypes) ExtensionByType(typ string) string {
+	var exts []string
+	m.mu.RLock()
+	for ext, t := range m.types {
+		if sameMediaType(t, typ) {
+			exts = append(exts, ext)
+		}
+	}
+	m.mu.RUnlock()
+	if len(exts) == 0 {
+		exts, _ = mime.ExtensionsByType(typ)
+	}
+	if len(exts) == 0 {
+		return ""
+	}
+	sort.Slice(exts, func(i, j int) bool {
+		if len(exts[i]) != len(exts[j]) {
+			return len(exts[i]) < len(exts[j])
+		}
+		return exts[i] < exts[j]
+	})
+	return exts[0]
+}
+
+// sameMediaType reports whether the MIME types a and b have the same media
+// type, ignoring parameters.
+func sameMediaType(a, b string) bool {
+	ma, _, err := mime.ParseMediaType(a)
+	if err != nil {
+		return false
+	}
+	mb, _, err := mime.ParseMediaType(b)
+	return err == nil && ma == mb
+}
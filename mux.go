@@ -0,0 +1,378 @@
+package gemini
+
+import (
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ServeMux is a Gemini request multiplexer.
+// It matches the URL path of each incoming request against a list of
+// registered patterns and calls the handler for the pattern that most
+// closely matches the path.
+//
+// Patterns name fixed, rooted paths, like "/favicon.txt", or rooted
+// subtrees, like "/images/" (note the trailing slash). Longer patterns take
+// precedence over shorter ones, so "/images/thumbnails/" is preferred over
+// "/images/" for paths under it.
+//
+// Patterns may contain named segments, like "/user/{name}/posts/{id}",
+// matching exactly one path segment each. A final segment of the form
+// "{name...}" matches the remainder of the path. Matched values are
+// available to handlers through Request.Param. Exact patterns are
+// preferred over patterns with named segments, which in turn are
+// preferred over subtrees; among patterns with named segments the one
+// with more literal segments wins.
+//
+// A request for a subtree root without the trailing slash, such as
+// "/images", is redirected to "/images/" unless "/images" is registered
+// on its own.
+//
+// HandleRegexp registers regular expression patterns, matched against the
+// whole path after exact and named segment patterns and before subtrees.
+// Capture groups are available through Request.Param by group name, or by
+// index ("1", "2", ...) for unnamed groups.
+//
+// Handlers registered with HandleTitan serve only titan:// requests and
+// take precedence over Handle registrations for them, so a path can have
+// separate read and upload handlers. Titan requests not matching any
+// HandleTitan pattern are passed to the ServeTitan method of the handler
+// matching their path, and answered with 59 if it only serves gemini
+// requests, that is, it does not implement TitanHandler. Handlers
+// registered with HandleTitan are passed titan requests through ServeTitan
+// if they implement it and ServeGemini otherwise.
+type ServeMux struct {
+	// NotFound is called when no pattern matches. If nil, the package
+	// level NotFound is used.
+	NotFound Handler
+
+	mu         sync.RWMutex
+	middleware []Middleware
+	gemini     routes
+	titan      routes
+}
+
+// routes is a set of patterns registered for one scheme.
+type routes struct {
+	m  map[string]Handler
+	es []muxEntry    // subtree patterns sorted from longest to shortest
+	ps []paramEntry  // patterns with named segments, most specific first
+	rs []regexpEntry // regular expression patterns in registration order
+}
+
+type muxEntry struct {
+	pattern string
+	h       Handler
+}
+
+type regexpEntry struct {
+	re *regexp.Regexp
+	h  Handler
+}
+
+type paramEntry struct {
+	muxEntry
+	segments []string
+	literals int
+	rest     bool // last segment is "{name...}"
+}
+
+// NewServeMux allocates and returns a new ServeMux.
+func NewServeMux() *ServeMux {
+	return &ServeMux{}
+}
+
+// Handle registers the handler for the given pattern.
+// If a handler already exists for pattern, Handle panics.
+func (mux *ServeMux) Handle(pattern string, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.gemini.add(pattern, handler)
+}
+
+// HandleRegexp registers the handler for paths matching the regular
+// expression expr. It panics if expr does not compile.
+func (mux *ServeMux) HandleRegexp(expr string, handler Handler) {
+	re := regexp.MustCompile(expr)
+	if handler == nil {
+		panic("gemini: nil handler")
+	}
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.gemini.rs = append(mux.gemini.rs, regexpEntry{re: re, h: handler})
+}
+
+// HandleTitan registers the handler for titan:// requests matching the
+// given pattern. If a titan handler already exists for pattern,
+// HandleTitan panics.
+func (mux *ServeMux) HandleTitan(pattern string, handler Handler) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.titan.add(pattern, handler)
+}
+
+func (rt *routes) add(pattern string, handler Handler) {
+	if pattern == "" || pattern[0] != '/' {
+		panic("gemini: invalid pattern " + pattern)
+	}
+	if handler == nil {
+		panic("gemini: nil handler")
+	}
+	if _, exist := rt.m[pattern]; exist {
+		panic("gemini: multiple registrations for " + pattern)
+	}
+	if rt.m == nil {
+		rt.m = make(map[string]Handler)
+	}
+	rt.m[pattern] = handler
+	switch {
+	case strings.Contains(pattern, "{"):
+		rt.ps = append(rt.ps, newParamEntry(pattern, handler))
+		sort.SliceStable(rt.ps, func(i, j int) bool {
+			return rt.ps[i].literals > rt.ps[j].literals
+		})
+	case strings.HasSuffix(pattern, "/"):
+		rt.es = append(rt.es, muxEntry{pattern: pattern, h: handler})
+		sort.SliceStable(rt.es, func(i, j int) bool {
+			return len(rt.es[i].pattern) > len(rt.es[j].pattern)
+		})
+	}
+}
+
+// Mount attaches handler under the prefix subtree. The handler sees request
+// paths with the prefix removed, so "/static/" mounted handler receives
+// "/css/site.css" for "/static/css/site.css".
+func (mux *ServeMux) Mount(prefix string, handler Handler) {
+	prefix = strings.TrimSuffix(prefix, "/")
+	mux.Handle(prefix+"/", StripPrefix(prefix, handler))
+}
+
+// Use appends middleware applied to every handler the mux dispatches to,
+// including redirects and NotFound. The first middleware is the outermost.
+func (mux *ServeMux) Use(mws ...Middleware) {
+	mux.mu.Lock()
+	defer mux.mu.Unlock()
+	mux.middleware = append(mux.middleware, mws...)
+}
+
+// HandleFunc registers the handler function for the given pattern.
+func (mux *ServeMux) HandleFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	mux.Handle(pattern, HandlerFunc(handler))
+}
+
+// HandleTitanFunc registers the titan handler function for the given
+// pattern.
+func (mux *ServeMux) HandleTitanFunc(pattern string, handler func(ResponseWriter, *Request)) {
+	mux.HandleTitan(pattern, HandlerFunc(handler))
+}
+
+// TitanPatterns returns the patterns registered with HandleTitan, sorted.
+func (mux *ServeMux) TitanPatterns() []string {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+	patterns := make([]string, 0, len(mux.titan.m))
+	for pattern := range mux.titan.m {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	return patterns
+}
+
+// Handler returns the handler to use for the given request and the
+// registered pattern that matches it. If there is no registered handler,
+// Handler returns the NotFound handler and an empty pattern.
+func (mux *ServeMux) Handler(r *Request) (h Handler, pattern string) {
+	h, pattern, _ = mux.match(r.URL.Scheme, r.URL.Path)
+	return h, pattern
+}
+
+func (mux *ServeMux) match(scheme, path string) (Handler, string, map[string]string) {
+	mux.mu.RLock()
+	defer mux.mu.RUnlock()
+
+	if scheme == SchemaTitan {
+		if h, pattern, params := mux.titan.match(path); h != nil {
+			if th, ok := h.(TitanHandler); ok {
+				return HandlerFunc(th.ServeTitan), pattern, params
+			}
+			return h, pattern, params
+		}
+		if h, pattern, params := mux.gemini.match(path); h != nil {
+			return HandlerFunc(func(w ResponseWriter, r *Request) {
+				serveTitan(h, w, r)
+			}), pattern, params
+		}
+	} else if h, pattern, params := mux.gemini.match(path); h != nil {
+		return h, pattern, params
+	}
+	if mux.NotFound != nil {
+		return mux.NotFound, "", nil
+	}
+	return HandlerFunc(NotFound), "", nil
+}
+
+// match returns nil handler when no pattern matches path.
+func (rt *routes) match(path string) (Handler, string, map[string]string) {
+	if h, ok := rt.m[path]; ok && !strings.Contains(path, "{") {
+		return h, path, nil
+	}
+	for _, e := range rt.ps {
+		if params, ok := e.match(path); ok {
+			return e.h, e.pattern, params
+		}
+	}
+	for _, e := range rt.rs {
+		if m := e.re.FindStringSubmatch(path); m != nil {
+			return e.h, e.re.String(), regexpParams(e.re, m)
+		}
+	}
+	if _, ok := rt.m[path+"/"]; ok {
+		return redirectHandler(path + "/"), path, nil
+	}
+	for _, e := range rt.es {
+		if strings.HasPrefix(path, e.pattern) {
+			return e.h, e.pattern, nil
+		}
+	}
+	return nil, "", nil
+}
+
+// ServeGemini dispatches the request to the handler whose pattern most
+// closely matches the request URL path.
+func (mux *ServeMux) ServeGemini(w ResponseWriter, r *Request) {
+	h, _, params := mux.match(r.URL.Scheme, r.URL.Path)
+	for k, v := range params {
+		r.SetParam(k, v)
+	}
+	mux.mu.RLock()
+	mws := mux.middleware
+	mux.mu.RUnlock()
+	if len(mws) > 0 {
+		h = Chain(mws...)(h)
+	}
+	h.ServeGemini(w, r)
+}
+
+// ServeTitan dispatches the titan request like ServeGemini.
+func (mux *ServeMux) ServeTitan(w ResponseWriter, r *Request) {
+	mux.ServeGemini(w, r)
+}
+
+func newParamEntry(pattern string, h Handler) paramEntry {
+	e := paramEntry{muxEntry: muxEntry{pattern: pattern, h: h}}
+	e.segments = strings.Split(pattern[1:], "/")
+	for i, seg := range e.segments {
+		name, isParam := paramName(seg)
+		switch {
+		case !isParam:
+			e.literals++
+		case strings.HasSuffix(name, "..."):
+			if i != len(e.segments)-1 {
+				panic("gemini: " + seg + " must be the last segment in " + pattern)
+			}
+			e.rest = true
+		case name == "":
+			panic("gemini: empty segment name in " + pattern)
+		}
+	}
+	return e
+}
+
+func (e paramEntry) match(path string) (map[string]string, bool) {
+	if path == "" || path[0] != '/' {
+		return nil, false
+	}
+	parts := strings.Split(path[1:], "/")
+	if len(parts) < len(e.segments) || (!e.rest && len(parts) != len(e.segments)) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, seg := range e.segments {
+		name, isParam := paramName(seg)
+		switch {
+		case !isParam:
+			if parts[i] != seg {
+				return nil, false
+			}
+		case strings.HasSuffix(name, "..."):
+			params[strings.TrimSuffix(name, "...")] = strings.Join(parts[i:], "/")
+		default:
+			if parts[i] == "" {
+				return nil, false
+			}
+			params[name] = parts[i]
+		}
+	}
+	return params, true
+}
+
+func regexpParams(re *regexp.Regexp, m []string) map[string]string {
+	params := make(map[string]string, len(m)-1)
+	for i, name := range re.SubexpNames()[1:] {
+		if name == "" {
+			name = strconv.Itoa(i + 1)
+		}
+		params[name] = m[i+1]
+	}
+	return params
+}
+
+func paramName(segment string) (string, bool) {
+	if len(segment) >= 2 && segment[0] == '{' && segment[len(segment)-1] == '}' {
+		return segment[1 : len(segment)-1], true
+	}
+	return "", false
+}
+
+// redirectHandler permanently redirects to path on the same host.
+func redirectHandler(path string) Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		u := *r.URL
+		u.Path = path
+		u.RawPath = ""
+		Redirect(w, u.String(), true)
+	})
+}
+
+// redirectToDir permanently redirects a request for a directory to the
+// same path with a trailing slash. The target is relative to the request
+// URL, so it stays inside the mount point when a prefix has been stripped
+// from r.URL.Path.
+func redirectToDir(w ResponseWriter, r *Request) {
+	u := url.URL{Path: "./" + path.Base(r.URL.Path) + "/"}
+	Redirect(w, u.String(), true)
+}
+
+// StripPrefix returns a handler that serves requests by removing the given
+// prefix from the request URL's Path and invoking the handler h.
+// StripPrefix handles a request for a path that doesn't begin with prefix
+// by replying with 51 NOT FOUND. If h implements TitanHandler, so does
+// the returned handler.
+func StripPrefix(prefix string, h Handler) Handler {
+	if prefix == "" {
+		return h
+	}
+	return wrapTitan(h, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			p := strings.TrimPrefix(r.URL.Path, prefix)
+			if len(p) == len(r.URL.Path) {
+				NotFound(w, r)
+				return
+			}
+			if p == "" || p[0] != '/' {
+				p = "/" + p
+			}
+			r2 := new(Request)
+			*r2 = *r
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = p
+			r2.URL.RawPath = ""
+			next.ServeGemini(w, r2)
+		}
+	})
+}
@@ -0,0 +1,219 @@
+package gemini_test
+
+import (
+	"bytes"
+	"net/url"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+// recorder is a ResponseWriter capturing what a handler writes.
+type recorder struct {
+	status gemini.StatusCode
+	meta   string
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteStatusMsg(status gemini.StatusCode, msg string) error {
+	r.status, r.meta = status, msg
+	return nil
+}
+
+func (r *recorder) WriteBody(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func serve(t *testing.T, h gemini.Handler, rawurl string) *recorder {
+	t.Helper()
+	u, err := url.Parse(rawurl)
+	require.NoError(t, err)
+	w := &recorder{}
+	h.ServeGemini(w, &gemini.Request{URL: u})
+	return w
+}
+
+func text(body string) gemini.HandlerFunc {
+	return func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		w.WriteBody([]byte(body))
+	}
+}
+
+func TestServeMux(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", text("root"))
+	mux.Handle("/about", text("about"))
+	mux.Handle("/docs/", text("docs"))
+	mux.Handle("/docs/api/", text("api"))
+
+	for path, want := range map[string]string{
+		"/":              "root",
+		"/about":         "about",
+		"/unknown":       "root",
+		"/docs/":         "docs",
+		"/docs/guide":    "docs",
+		"/docs/api/":     "api",
+		"/docs/api/ref":  "api",
+		"/docs/apiary":   "docs",
+		"/about/nothing": "root",
+	} {
+		w := serve(t, mux, "gemini://localhost"+path)
+		require.Equal(t, want, w.body.String(), path)
+	}
+
+	w := serve(t, mux, "gemini://localhost/docs?q=1")
+	require.Equal(t, gemini.StatusPermanentRedirect, w.status)
+	require.Equal(t, "gemini://localhost/docs/?q=1", w.meta)
+}
+
+func TestServeMuxNotFound(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/about", text("about"))
+	w := serve(t, mux, "gemini://localhost/missing")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+
+	mux.NotFound = text("custom")
+	w = serve(t, mux, "gemini://localhost/missing")
+	require.Equal(t, "custom", w.body.String())
+
+	require.Panics(t, func() { mux.Handle("/about", text("again")) })
+	require.Panics(t, func() { mux.Handle("about", text("relative")) })
+}
+
+func params(names ...string) gemini.HandlerFunc {
+	return func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+		for _, name := range names {
+			w.WriteBody([]byte(name + "=" + r.Param(name) + ";"))
+		}
+	}
+}
+
+func TestServeMuxParams(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/user/{name}/posts/{id}", params("name", "id"))
+	mux.Handle("/user/admin/posts/{id}", params("id"))
+	mux.Handle("/files/{path...}", params("path"))
+	mux.Handle("/user/", text("subtree"))
+
+	for path, want := range map[string]string{
+		"/user/bob/posts/42":   "name=bob;id=42;",
+		"/user/admin/posts/7":  "id=7;",
+		"/user/bob/posts/":     "subtree",
+		"/user/bob/posts/1/x":  "subtree",
+		"/files/a/b/c.gmi":     "path=a/b/c.gmi;",
+		"/files/":              "path=;",
+		"/user/{name}/posts/1": "name={name};id=1;",
+	} {
+		w := serve(t, mux, "gemini://localhost"+path)
+		require.Equal(t, want, w.body.String(), path)
+	}
+
+	require.Panics(t, func() { mux.Handle("/bad/{rest...}/tail", text("")) })
+}
+
+func tag(name string) gemini.Middleware {
+	return func(next gemini.Handler) gemini.Handler {
+		return gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			next.ServeGemini(w, r)
+			w.WriteBody([]byte("<" + name))
+		})
+	}
+}
+
+func TestServeMuxUse(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", text("root"))
+	mux.Use(tag("outer"), tag("inner"))
+	w := serve(t, mux, "gemini://localhost/")
+	require.Equal(t, "root<inner<outer", w.body.String())
+
+	h := gemini.Chain(tag("a"), tag("b"))(text("x"))
+	require.Equal(t, "x<b<a", serve(t, h, "gemini://localhost/").body.String())
+}
+
+func path(w gemini.ResponseWriter, r *gemini.Request) {
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+	w.WriteBody([]byte(r.URL.Path))
+}
+
+func TestStripPrefixAndMount(t *testing.T) {
+	h := gemini.StripPrefix("/static", gemini.HandlerFunc(path))
+	require.Equal(t, "/css/a.css", serve(t, h, "gemini://localhost/static/css/a.css").body.String())
+	require.Equal(t, "/", serve(t, h, "gemini://localhost/static").body.String())
+	require.Equal(t, gemini.StatusNotFound, serve(t, h, "gemini://localhost/other").status)
+
+	mux := gemini.NewServeMux()
+	mux.Mount("/app/", gemini.HandlerFunc(path))
+	require.Equal(t, "/", serve(t, mux, "gemini://localhost/app/").body.String())
+	require.Equal(t, "/x/y", serve(t, mux, "gemini://localhost/app/x/y").body.String())
+	require.Equal(t, gemini.StatusPermanentRedirect, serve(t, mux, "gemini://localhost/app").status)
+}
+
+func TestServeMuxTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/wiki/", text("read"))
+	mux.HandleTitan("/wiki/", text("edit"))
+	mux.Handle("/about", text("about"))
+	mux.Mount("/files/", readWrite{})
+	mux.Handle("/inbox", gemini.TitanHandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+		w.WriteBody([]byte("inbox"))
+	}))
+
+	require.Equal(t, "read", serve(t, mux, "gemini://localhost/wiki/page").body.String())
+	require.Equal(t, "edit", serve(t, mux, "titan://localhost/wiki/page").body.String())
+	w := serve(t, mux, "titan://localhost/about")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Titan requests not supported", w.meta)
+	require.Equal(t, "read /a", serve(t, mux, "gemini://localhost/files/a").body.String())
+	require.Equal(t, "write /a", serve(t, mux, "titan://localhost/files/a").body.String())
+	require.Equal(t, "inbox", serve(t, mux, "titan://localhost/inbox").body.String())
+	require.Equal(t, gemini.StatusBadRequest, serve(t, mux, "gemini://localhost/inbox").status)
+}
+
+// readWrite serves reads and uploads with separate methods.
+type readWrite struct{}
+
+func (readWrite) ServeGemini(w gemini.ResponseWriter, r *gemini.Request) {
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+	w.WriteBody([]byte("read " + r.URL.Path))
+}
+
+func (readWrite) ServeTitan(w gemini.ResponseWriter, r *gemini.Request) {
+	w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+	w.WriteBody([]byte("write " + r.URL.Path))
+}
+
+func TestServeMuxRegexp(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.HandleRegexp(`^/post/(\d{4})/(\d{2})/(.+)$`, params("1", "2", "3"))
+	mux.HandleRegexp(`^/tag/(?P<tag>[a-z]+)$`, params("tag"))
+	mux.Handle("/post/", text("subtree"))
+
+	require.Equal(t, "1=2021;2=07;3=hello-world;", serve(t, mux, "gemini://localhost/post/2021/07/hello-world").body.String())
+	require.Equal(t, "subtree", serve(t, mux, "gemini://localhost/post/21/07/x").body.String())
+	require.Equal(t, "tag=go;", serve(t, mux, "gemini://localhost/tag/go").body.String())
+	require.Equal(t, gemini.StatusNotFound, serve(t, mux, "gemini://localhost/tag/Go").status)
+	require.Panics(t, func() { mux.HandleRegexp("(", text("")) })
+}
+
+func TestServeMuxGroup(t *testing.T) {
+	mux := gemini.NewServeMux()
+	admin := mux.Group("/admin/", tag("admin"))
+	admin.Handle("/", text("dashboard"))
+	users := admin.Group("/users", tag("users"))
+	users.Handle("/{name}", params("name"))
+	admin.Use(tag("late"))
+
+	require.Equal(t, "dashboard<late<admin", serve(t, mux, "gemini://localhost/admin/").body.String())
+	require.Equal(t, "name=bob;<users<late<admin", serve(t, mux, "gemini://localhost/admin/users/bob").body.String())
+}
+
+func TestServeMuxGroupTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Group("/files", tag("files")).Handle("/", readWrite{})
+	require.Equal(t, "write /files/a<files", serve(t, mux, "titan://localhost/files/a").body.String())
+}
@@ -0,0 +1,99 @@
+package gemini
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// ErrBadPageNumber is returned by PageNumber for queries that are not a
+// positive page number.
+var ErrBadPageNumber = errors.New("gemini: bad page number")
+
+// PageNumber returns the page requested with the query of r, as in
+// "log?2". Requests without a query ask for page 1.
+func PageNumber(r *Request) (int, error) {
+	if r.URL.RawQuery == "" {
+		return 1, nil
+	}
+	n, err := r.QueryInt()
+	if err != nil || n < 1 {
+		return 0, ErrBadPageNumber
+	}
+	return n, nil
+}
+
+// Paginator splits long generated documents into pages of at most Lines
+// lines and Bytes bytes, served with links to the previous and next
+// page:
+//
+//	p := &gemini.Paginator{Lines: 100}
+//	p.Serve(w, r, doc)
+//
+// Preformatted blocks are kept on one page even when they exceed the
+// limits on their own.
+type Paginator struct {
+	// Lines and Bytes limit the size of a page; zero means no limit.
+	Lines int
+	Bytes int
+}
+
+// Pages returns doc split into pages. A document without lines has one
+// empty page.
+func (p *Paginator) Pages(doc gemtext.Document) []gemtext.Document {
+	var pages []gemtext.Document
+	var page gemtext.Document
+	lines, size, inPre := 0, 0, false
+	for _, l := range doc {
+		n, s := 1, len(l.String())+1
+		if pre, ok := l.(gemtext.Preformatted); ok {
+			n = len(pre.Lines) + 2
+		}
+		// Pages do not break within a block of toggled preformatted lines.
+		full := (p.Lines > 0 && lines+n > p.Lines) || (p.Bytes > 0 && size+s > p.Bytes)
+		if full && len(page) > 0 && !inPre {
+			pages = append(pages, page)
+			page, lines, size = nil, 0, 0
+		}
+		if _, ok := l.(gemtext.PreformatToggle); ok {
+			inPre = !inPre
+		}
+		page = append(page, l)
+		lines += n
+		size += s
+	}
+	return append(pages, page)
+}
+
+// Serve answers r with the page of doc requested by its query, followed
+// by links to the previous and next page. Bad page numbers are answered
+// with 59 and pages past the end with 51.
+func (p *Paginator) Serve(w ResponseWriter, r *Request, doc gemtext.Document) {
+	n, err := PageNumber(r)
+	if err != nil {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad page number"))
+		return
+	}
+	pages := p.Pages(doc)
+	if n > len(pages) {
+		NotFound(w, r)
+		return
+	}
+	var b strings.Builder
+	b.WriteString(pages[n-1].String())
+	if len(pages) > 1 {
+		gw := gemtext.NewWriter(&b)
+		gw.Text("")
+		if n > 1 {
+			gw.Link("?"+strconv.Itoa(n-1), Message(r, "Previous page"))
+		}
+		if n < len(pages) {
+			gw.Link("?"+strconv.Itoa(n+1), Message(r, "Next page"))
+		}
+		gw.Text(messagef(r, "Page %d of %d", n, len(pages)))
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
@@ -0,0 +1,54 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/gemtext"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPaginatorPages(t *testing.T) {
+	doc := gemtext.ParseString("# Title\none\ntwo\n```\na\nb\n```\nthree\n")
+	pages := (&gemini.Paginator{Lines: 3}).Pages(doc)
+	require.Len(t, pages, 3)
+	require.Equal(t, "# Title\none\ntwo\n", pages[0].String())
+	require.Equal(t, "```\na\nb\n```\n", pages[1].String())
+	require.Equal(t, "three\n", pages[2].String())
+
+	pages = (&gemini.Paginator{Bytes: 12}).Pages(doc[:3])
+	require.Len(t, pages, 2)
+	require.Equal(t, "# Title\none\n", pages[0].String())
+
+	toggled := gemtext.Document{gemtext.Text("x"), gemtext.PreformatToggle{}, gemtext.PreformattedText("a"),
+		gemtext.PreformattedText("b"), gemtext.PreformatToggle{}, gemtext.Text("y")}
+	pages = (&gemini.Paginator{Lines: 2}).Pages(toggled)
+	require.Len(t, pages, 2)
+	require.Equal(t, "x\n```\na\nb\n```\n", pages[0].String())
+
+	require.Len(t, (&gemini.Paginator{Lines: 3}).Pages(nil), 1)
+}
+
+func TestPaginatorServe(t *testing.T) {
+	doc := gemtext.ParseString("one\ntwo\nthree\nfour\nfive\n")
+	p := &gemini.Paginator{Lines: 2}
+	h := gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		p.Serve(w, r, doc)
+	})
+
+	w := serve(t, h, "gemini://localhost/list")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "one\ntwo\n\n=> ?2 Next page\nPage 1 of 3\n", w.body.String())
+	w = serve(t, h, "gemini://localhost/list?2")
+	require.Equal(t, "three\nfour\n\n=> ?1 Previous page\n=> ?3 Next page\nPage 2 of 3\n", w.body.String())
+	w = serve(t, h, "gemini://localhost/list?3")
+	require.Equal(t, "five\n\n=> ?2 Previous page\nPage 3 of 3\n", w.body.String())
+
+	require.Equal(t, gemini.StatusNotFound, serve(t, h, "gemini://localhost/list?4").status)
+	w = serve(t, h, "gemini://localhost/list?0")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Bad page number", w.meta)
+
+	p.Lines = 0
+	require.Equal(t, doc.String(), serve(t, h, "gemini://localhost/list").body.String())
+}
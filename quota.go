@@ -0,0 +1,204 @@
+package gemini
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// UploadQuota is middleware limiting the Titan upload bytes each client
+// certificate may send within a rolling time window, for capsules shared
+// by several users:
+//
+//	quota := &gemini.UploadQuota{Limit: 50 << 20, Window: 24 * time.Hour}
+//	mux.Handle("/usage", quota.UsageHandler())
+//	srv.Handler = quota.Middleware(mux)
+//
+// Titan requests without a client certificate are answered with 60.
+// Uploads larger than Limit are answered with 50, and uploads that would
+// exceed the quota with 44 and the seconds until enough of it is free
+// again. Uploads count against the quota from the moment the handler is
+// called, and keep counting only if it answers them with a success or
+// redirect status, so concurrent uploads can not together exceed it.
+type UploadQuota struct {
+	// Limit is the number of bytes a certificate may upload per Window.
+	Limit int64
+
+	// Window is the length of the rolling window. Defaults to 24 hours.
+	Window time.Duration
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	uploads map[string][]*quotaUpload
+}
+
+type quotaUpload struct {
+	at   time.Time
+	size int64
+}
+
+// Usage returns the bytes uploaded with the certificate fingerprint
+// within the current window and when the oldest of them leave it, which
+// is zero if there are none.
+func (q *UploadQuota) Usage(fingerprint string) (used int64, next time.Time) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	return q.usage(fingerprint, q.now())
+}
+
+func (q *UploadQuota) usage(fingerprint string, now time.Time) (int64, time.Time) {
+	uploads := q.uploads[fingerprint]
+	i := 0
+	for i < len(uploads) && !uploads[i].at.After(now.Add(-q.window())) {
+		i++
+	}
+	uploads = uploads[i:]
+	if len(uploads) == 0 {
+		delete(q.uploads, fingerprint)
+		return 0, time.Time{}
+	}
+	q.uploads[fingerprint] = uploads
+	var used int64
+	for _, u := range uploads {
+		used += u.size
+	}
+	return used, uploads[0].at.Add(q.window())
+}
+
+// available returns when size more bytes fit into the exhausted quota of
+// fingerprint.
+func (q *UploadQuota) available(fingerprint string, size int64, now time.Time) time.Time {
+	used, _ := q.usage(fingerprint, now)
+	for _, u := range q.uploads[fingerprint] {
+		used -= u.size
+		if used+size <= q.Limit {
+			return u.at.Add(q.window())
+		}
+	}
+	return time.Time{}
+}
+
+func (q *UploadQuota) window() time.Duration {
+	if q.Window <= 0 {
+		return 24 * time.Hour
+	}
+	return q.Window
+}
+
+func (q *UploadQuota) now() time.Time {
+	if q.Now != nil {
+		return q.Now()
+	}
+	return time.Now()
+}
+
+// Middleware returns next wrapped in quota enforcement.
+func (q *UploadQuota) Middleware(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			if r.URL.Scheme != SchemaTitan {
+				next.ServeGemini(w, r)
+				return
+			}
+			cert := r.Certificate()
+			if cert == nil {
+				w.WriteStatusMsg(StatusCertRequired, Message(r, ErrCertificateRequired.Error()))
+				return
+			}
+			size := r.Titan.Size
+			if size < 0 {
+				w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+				return
+			}
+			if size > q.Limit {
+				w.WriteStatusMsg(StatusGeneralPermFail, messagef(r, "Upload exceeds quota of %d bytes", q.Limit))
+				return
+			}
+			fp := Fingerprint(cert)
+			q.mu.Lock()
+			now := q.now()
+			if q.uploads == nil {
+				q.uploads = make(map[string][]*quotaUpload)
+			}
+			used, _ := q.usage(fp, now)
+			var retry time.Time
+			var reserved *quotaUpload
+			if used+size > q.Limit {
+				retry = q.available(fp, size, now)
+			} else {
+				reserved = &quotaUpload{at: now, size: size}
+				q.uploads[fp] = append(q.uploads[fp], reserved)
+			}
+			q.mu.Unlock()
+			if !retry.IsZero() {
+				secs := int(retry.Sub(now).Round(time.Second) / time.Second)
+				if secs < 1 {
+					secs = 1
+				}
+				w.WriteStatusMsg(StatusSlowDown, strconv.Itoa(secs))
+				return
+			}
+
+			rec := NewStatusRecorder(w)
+			defer func() {
+				if st := rec.Status(); st < 20 || st >= 40 {
+					q.release(fp, reserved)
+				}
+			}()
+			next.ServeGemini(rec, r)
+		}
+	})
+}
+
+// release removes the reserved upload u of fingerprint.
+func (q *UploadQuota) release(fingerprint string, u *quotaUpload) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	uploads := q.uploads[fingerprint]
+	for i, v := range uploads {
+		if v == u {
+			uploads = append(uploads[:i:i], uploads[i+1:]...)
+			break
+		}
+	}
+	if len(uploads) == 0 {
+		delete(q.uploads, fingerprint)
+		return
+	}
+	q.uploads[fingerprint] = uploads
+}
+
+// UsageHandler returns a handler showing the client the usage of its
+// certificate's quota. Requests without a certificate are answered
+// with 60.
+func (q *UploadQuota) UsageHandler() Handler {
+	return HandlerFunc(func(w ResponseWriter, r *Request) {
+		cert := r.Certificate()
+		if cert == nil {
+			w.WriteStatusMsg(StatusCertRequired, Message(r, ErrCertificateRequired.Error()))
+			return
+		}
+		used, next := q.Usage(Fingerprint(cert))
+		free := q.Limit - used
+		if free < 0 {
+			free = 0
+		}
+		var b strings.Builder
+		gw := gemtext.NewWriter(&b)
+		gw.Heading(1, Message(r, "Upload quota"))
+		gw.Text("")
+		gw.ListItem(messagef(r, "Used: %s of %s", formatSize(used), formatSize(q.Limit)))
+		gw.ListItem(messagef(r, "Available: %s", formatSize(free)))
+		gw.ListItem(messagef(r, "Window: %s", q.window()))
+		if !next.IsZero() {
+			gw.ListItem(messagef(r, "More space from: %s", next.UTC().Format("2006-01-02 15:04 MST")))
+		}
+		w.WriteStatusMsg(StatusSuccess, "text/gemini")
+		w.WriteBody([]byte(b.String()))
+	})
+}
@@ -0,0 +1,100 @@
+package gemini_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadQuota(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	quota := &gemini.UploadQuota{Limit: 10, Window: time.Hour, Now: func() time.Time { return now }}
+	mux := gemini.NewServeMux()
+	mux.Handle("/usage", quota.UsageHandler())
+	mux.HandleTitanFunc("/", func(w gemini.ResponseWriter, r *gemini.Request) {
+		body, err := r.ReadTitanPayload()
+		if err != nil || strings.Contains(string(body), "x") {
+			w.WriteStatusMsg(gemini.StatusBadRequest, "Rejected")
+			return
+		}
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	})
+	addr := startServer(t, &gemini.Server{Handler: quota.Middleware(mux)})
+	client := testcert.Client().TLSCertificate()
+	upload := func(body string, certs ...tls.Certificate) string {
+		t.Helper()
+		header, _ := roundTrip(t, addr, "titan://localhost/f;size="+strconv.Itoa(len(body))+"\r\n"+body, certs...)
+		return header
+	}
+
+	require.Equal(t, "60 Certificate Required\r\n", upload("hi"))
+	require.Equal(t, "50 Upload exceeds quota of 10 bytes\r\n", upload("eleven byte", client))
+	require.Equal(t, "20 text/gemini\r\n", upload("hello", client))
+	now = now.Add(10 * time.Minute)
+	require.Equal(t, "59 Rejected\r\n", upload("xxxxx", client)) // not counted
+	require.Equal(t, "20 text/gemini\r\n", upload("world", client))
+	require.Equal(t, "44 3000\r\n", upload("more", client))
+	require.Equal(t, "20 text/gemini\r\n", upload("a", testcert.ExpiredClient().TLSCertificate()))
+
+	header, body := roundTrip(t, addr, "gemini://localhost/usage", client)
+	require.Equal(t, "20 text/gemini\r\n", header)
+	require.Equal(t, "# Upload quota\n\n"+
+		"* Used: 10 B of 10 B\n"+
+		"* Available: 0 B\n"+
+		"* Window: 1h0m0s\n"+
+		"* More space from: 2024-01-01 13:00 UTC\n", body)
+
+	now = now.Add(50 * time.Minute)
+	require.Equal(t, "20 text/gemini\r\n", upload("more", client))
+	used, _ := quota.Usage(testcert.Client().Fingerprint())
+	require.Equal(t, int64(9), used)
+}
+
+func TestUploadQuotaConcurrent(t *testing.T) {
+	quota := &gemini.UploadQuota{Limit: 8}
+	started, release := make(chan struct{}), make(chan struct{})
+	addr := startServer(t, &gemini.Server{Handler: quota.Middleware(gemini.TitanHandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		if _, err := r.ReadTitanPayload(); err != nil {
+			w.WriteStatusMsg(gemini.StatusBadRequest, "Rejected")
+			return
+		}
+		if r.URL.Path == "/bad" {
+			started <- struct{}{}
+			<-release
+			w.WriteStatusMsg(gemini.StatusBadRequest, "Rejected")
+			return
+		}
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+	}))})
+	client := testcert.Client().TLSCertificate()
+	upload := func(path, body string) string {
+		t.Helper()
+		header, _ := roundTrip(t, addr, "titan://localhost"+path+";size="+strconv.Itoa(len(body))+"\r\n"+body, client)
+		return header
+	}
+
+	// Hold the first upload in the handler while the second arrives.
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true, Certificates: []tls.Certificate{client}})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "titan://localhost/bad;size=5\r\nhello")
+	require.NoError(t, err)
+	<-started
+	require.Equal(t, "44 86400\r\n", upload("/f", "world"))
+	close(release)
+	header, err := bufio.NewReader(conn).ReadString('\n')
+	require.NoError(t, err)
+	require.Equal(t, "59 Rejected\r\n", header)
+
+	require.Equal(t, "20 text/gemini\r\n", upload("/f", "world"))
+	used, _ := quota.Usage(testcert.Client().Fingerprint())
+	require.Equal(t, int64(5), used)
+}
@@ -0,0 +1,167 @@
+package gemini
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimitStore keeps token buckets per client key.
+type RateLimitStore interface {
+	// Take removes a token from the bucket for key holding at most burst
+	// tokens and refilled at rate tokens per second. When the bucket is
+	// empty it returns false and the time until a token is available.
+	Take(key string, rate float64, burst int) (ok bool, retryAfter time.Duration)
+}
+
+// RateLimit is token bucket rate limiting middleware answering clients
+// that exceed their rate with 44 SLOW DOWN.
+type RateLimit struct {
+	// Rate is the sustained number of requests per second per client.
+	Rate float64
+
+	// Burst is the number of requests a client may make at once.
+	// Defaults to 1.
+	Burst int
+
+	// Key identifies the client of a request. Defaults to KeyByIP.
+	Key func(*Request) string
+
+	// Store keeps the buckets. Defaults to a MemoryRateLimitStore shared
+	// by all requests passing through this RateLimit.
+	Store RateLimitStore
+
+	// Exempt optionally lists client certificate fingerprints that are
+	// never limited.
+	Exempt []string
+
+	once sync.Once
+}
+
+// KeyByIP identifies clients by their IP address.
+func KeyByIP(r *Request) string {
+	if ip := RemoteIP(r); ip != nil {
+		return ip.String()
+	}
+	return r.RemoteAddr
+}
+
+// KeyByCertificate identifies clients by certificate fingerprint, falling
+// back to the IP address for requests without one.
+func KeyByCertificate(r *Request) string {
+	if cert := r.Certificate(); cert != nil {
+		return "cert:" + Fingerprint(cert)
+	}
+	return KeyByIP(r)
+}
+
+// Middleware returns next wrapped in rate limiting.
+func (rl *RateLimit) Middleware(next Handler) Handler {
+	rl.once.Do(func() {
+		if rl.Store == nil {
+			rl.Store = NewMemoryRateLimitStore()
+		}
+		if rl.Key == nil {
+			rl.Key = KeyByIP
+		}
+		if rl.Burst <= 0 {
+			rl.Burst = 1
+		}
+	})
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			if !rl.exempt(r) {
+				if ok, retry := rl.Store.Take(rl.Key(r), rl.Rate, rl.Burst); !ok {
+					secs := int(math.Ceil(retry.Seconds()))
+					if secs < 1 {
+						secs = 1
+					}
+					w.WriteStatusMsg(StatusSlowDown, strconv.Itoa(secs))
+					return
+				}
+			}
+			next.ServeGemini(w, r)
+		}
+	})
+}
+
+func (rl *RateLimit) exempt(r *Request) bool {
+	if len(rl.Exempt) == 0 {
+		return false
+	}
+	cert := r.Certificate()
+	if cert == nil {
+		return false
+	}
+	fp := Fingerprint(cert)
+	for _, e := range rl.Exempt {
+		if strings.EqualFold(e, fp) {
+			return true
+		}
+	}
+	return false
+}
+
+// MemoryRateLimitStore is an in-process RateLimitStore.
+type MemoryRateLimitStore struct {
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	takes   int
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewMemoryRateLimitStore returns an empty in-process store.
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: make(map[string]*bucket)}
+}
+
+// Take implements RateLimitStore.
+func (s *MemoryRateLimitStore) Take(key string, rate float64, burst int) (bool, time.Duration) {
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.buckets == nil {
+		s.buckets = make(map[string]*bucket)
+	}
+	s.takes++
+	if s.takes%1024 == 0 {
+		s.prune(now, rate, burst)
+	}
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(burst), last: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(float64(burst), b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if rate <= 0 {
+		return false, time.Hour
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
+
+// prune drops buckets that have refilled completely.
+func (s *MemoryRateLimitStore) prune(now time.Time, rate float64, burst int) {
+	for k, b := range s.buckets {
+		if b.tokens+now.Sub(b.last).Seconds()*rate >= float64(burst) {
+			delete(s.buckets, k)
+		}
+	}
+}
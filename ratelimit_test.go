@@ -0,0 +1,44 @@
+package gemini_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimit(t *testing.T) {
+	now := time.Unix(0, 0)
+	store := gemini.NewMemoryRateLimitStore()
+	store.Now = func() time.Time { return now }
+	rl := &gemini.RateLimit{Rate: 0.5, Burst: 2, Store: store}
+	h := rl.Middleware(text("ok"))
+
+	get := func(remote string) *recorder {
+		u, _ := url.Parse("gemini://localhost/")
+		w := &recorder{}
+		h.ServeGemini(w, &gemini.Request{URL: u, RemoteAddr: remote})
+		return w
+	}
+
+	require.Equal(t, gemini.StatusSuccess, get("192.0.2.1:1000").status)
+	require.Equal(t, gemini.StatusSuccess, get("192.0.2.1:1001").status)
+	w := get("192.0.2.1:1002")
+	require.Equal(t, gemini.StatusSlowDown, w.status)
+	require.Equal(t, "2", w.meta)
+
+	require.Equal(t, gemini.StatusSuccess, get("192.0.2.2:1000").status)
+
+	now = now.Add(2 * time.Second)
+	require.Equal(t, gemini.StatusSuccess, get("192.0.2.1:1003").status)
+	require.Equal(t, gemini.StatusSlowDown, get("192.0.2.1:1004").status)
+}
+
+func TestRateLimitTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", (&gemini.RateLimit{Rate: 1, Burst: 1}).Middleware(readWrite{}))
+	require.Equal(t, "write /a", serve(t, mux, "titan://localhost/a").body.String())
+	require.Equal(t, gemini.StatusSlowDown, serve(t, mux, "titan://localhost/a").status)
+}
@@ -3,12 +3,16 @@ package gemini
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -35,9 +39,14 @@ var (
 type Request struct {
 	URL *url.URL
 
-	ctx   context.Context
-	conn  *tls.Conn
-	Titan TitanRequest
+	// RemoteAddr is the network address of the client that sent the request.
+	// It is set by the server and is empty for client requests.
+	RemoteAddr string
+
+	ctx    context.Context
+	conn   *tls.Conn
+	params map[string]string
+	Titan  TitanRequest
 }
 
 type TitanRequest struct {
@@ -54,6 +63,15 @@ type TitanRequest struct {
 	// also treated as unknown.
 	Size int64
 
+	// SHA256 is the hex encoded checksum declared with the sha256
+	// parameter, or empty. When set, reading Body to Size bytes fails
+	// with ErrChecksumMismatch if the payload does not match.
+	SHA256 string
+
+	// Params holds the parameters other than token, mime, size and
+	// sha256, decoded.
+	Params map[string]string
+
 	// Body is the request's body.
 	//
 	// For client requests, a nil body means the request has no
@@ -71,15 +89,66 @@ type TitanRequest struct {
 	Body io.ReadCloser
 
 	getBody func() (io.ReadCloser, error)
+	sized   bool          // the size parameter was given
+	counter *countingBody // counts the bytes read of server requests
+}
+
+// Remaining returns the number of bytes of the payload not yet read from
+// the connection. Middleware can use it, together with Discard, to drain
+// an upload the handler did not read.
+func (t *TitanRequest) Remaining() int64 {
+	if t.counter == nil {
+		return t.Size
+	}
+	if n := t.Size - t.counter.n; n > 0 {
+		return n
+	}
+	return 0
+}
+
+// Discard reads and drops the rest of the payload, returning the number
+// of bytes discarded. Reading the payload to its end lets the connection
+// be closed cleanly, without the client seeing a reset before it reads
+// the response.
+func (t *TitanRequest) Discard() (int64, error) {
+	n := t.Remaining()
+	if n <= 0 || t.Body == nil {
+		return 0, nil
+	}
+	return io.CopyN(io.Discard, t.Body, n)
+}
+
+// countingBody counts the bytes read from a server request's connection.
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (c *countingBody) Read(p []byte) (int, error) {
+	n, err := c.ReadCloser.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// IsDelete reports whether the request asks to delete the resource, by
+// the Titan convention of an upload declared with size=0. Client
+// requests created with a nil or empty body are deletes.
+func (t *TitanRequest) IsDelete() bool {
+	return t.sized && t.Size == 0 && !t.Edit
 }
 
 func (r *Request) Reset(conn *tls.Conn, rawurl string) error {
 	r.conn = conn
+	r.params = nil
 	r.Titan.Edit = false
 	r.Titan.Mime = ""
 	r.Titan.Size = 0
 	r.Titan.Token = ""
+	r.Titan.SHA256 = ""
+	r.Titan.Params = nil
 	r.Titan.Body = conn
+	r.Titan.sized = false
+	r.Titan.counter = nil
 	var err error
 	r.URL, err = url.ParseRequestURI(rawurl)
 	if err != nil {
@@ -93,18 +162,34 @@ func (r *Request) Reset(conn *tls.Conn, rawurl string) error {
 		if err != nil {
 			return err
 		}
+		r.Titan.counter = &countingBody{ReadCloser: conn}
+		r.Titan.Body = r.Titan.counter
+		if r.Titan.SHA256 != "" {
+			r.Titan.Body = newChecksumReader(r.Titan.Body, r.Titan.Size, r.Titan.SHA256)
+		}
 	} else {
 		r.resetGeminiURL()
 	}
 	return err
 }
 
+// resetTitanURL splits the parameters off the path. Parameters are split
+// at ";" and "=" in the escaped path, so escaped ";" and "=" characters
+// may appear in names and values.
 func (r *Request) resetTitanURL() error {
-	parts := strings.Split(r.URL.Path, ";")
+	parts := strings.Split(r.URL.EscapedPath(), ";")
 	if len(parts) < 2 {
 		return errors.New("titan parameters expected")
 	}
-	r.URL.Path, parts = parts[0], parts[1:]
+	path, err := url.PathUnescape(parts[0])
+	if err != nil {
+		return fmt.Errorf("failed to parse titan path: %v", err)
+	}
+	r.URL.Path, r.URL.RawPath = path, ""
+	if (&url.URL{Path: path}).EscapedPath() != parts[0] {
+		r.URL.RawPath = parts[0]
+	}
+	parts = parts[1:]
 	if parts[0] == "edit" {
 		r.Titan.Edit = true
 		if len(parts[1:]) > 0 {
@@ -114,12 +199,18 @@ func (r *Request) resetTitanURL() error {
 	}
 
 	for _, part := range parts {
-		kv := strings.Split(part, "=")
-		if len(kv) != 2 {
+		i := strings.IndexByte(part, '=')
+		if i < 0 {
 			continue
 		}
-		key := kv[0]
-		val := kv[1]
+		key, err := url.PathUnescape(part[:i])
+		if err != nil {
+			return fmt.Errorf("failed to parse titan parameter: %s", part)
+		}
+		val, err := url.PathUnescape(part[i+1:])
+		if err != nil {
+			return fmt.Errorf("failed to parse titan parameter: %s", part)
+		}
 		switch key {
 		case "token":
 			r.Titan.Token = val
@@ -131,11 +222,95 @@ func (r *Request) resetTitanURL() error {
 			if err != nil {
 				return fmt.Errorf("failed to parse titan size parameter: %s", val)
 			}
+			r.Titan.sized = true
+		case "sha256":
+			if b, err := hex.DecodeString(val); err != nil || len(b) != sha256.Size {
+				return fmt.Errorf("failed to parse titan sha256 parameter: %s", val)
+			}
+			r.Titan.SHA256 = strings.ToLower(val)
+		default:
+			if r.Titan.Params == nil {
+				r.Titan.Params = make(map[string]string)
+			}
+			r.Titan.Params[key] = val
 		}
 	}
 	return nil
 }
 
+// GeminiURL returns the gemini:// URL of the resource a titan request
+// uploads to: the request URL with the scheme swapped and the titan
+// parameters, query and fragment removed.
+func (r *Request) GeminiURL() *url.URL {
+	u := *r.URL
+	u.Scheme = SchemaGemini
+	u.RawQuery, u.ForceQuery, u.Fragment, u.RawFragment = "", false, "", ""
+	if p := u.EscapedPath(); strings.Contains(p, ";") {
+		p = p[:strings.IndexByte(p, ';')]
+		u.Path, _ = url.PathUnescape(p)
+		u.RawPath = p
+	}
+	return &u
+}
+
+// TitanURL returns the URL of a titan client request with its parameters
+// appended to the path, escaped so names and values may contain any
+// character. Gemini requests return the URL unchanged.
+func (r *Request) TitanURL() string {
+	if r.URL.Scheme != SchemaTitan {
+		return r.URL.String()
+	}
+	var b strings.Builder
+	if r.Titan.Edit {
+		b.WriteString(";edit")
+	} else {
+		param := func(key, val string) {
+			b.WriteString(";" + escapeTitanParam(key, true) + "=" + escapeTitanParam(val, false))
+		}
+		if r.Titan.Token != "" {
+			param("token", r.Titan.Token)
+		}
+		if r.Titan.Mime != "" {
+			param("mime", r.Titan.Mime)
+		}
+		param("size", strconv.FormatInt(r.Titan.Size, 10))
+		if r.Titan.SHA256 != "" {
+			param("sha256", r.Titan.SHA256)
+		}
+		keys := make([]string, 0, len(r.Titan.Params))
+		for key := range r.Titan.Params {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			param(key, r.Titan.Params[key])
+		}
+	}
+	s := r.URL.Scheme + "://" + r.URL.Host + r.URL.EscapedPath() + b.String()
+	if r.URL.RawQuery != "" {
+		s += "?" + r.URL.RawQuery
+	}
+	return s
+}
+
+// escapeTitanParam percent-encodes the characters of s that would end or
+// split a titan parameter, as well as "=" in names.
+func escapeTitanParam(s string, name bool) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || c == '%' || c == ';' || c == '?' || c == '#' || (name && c == '=') {
+			b.WriteByte('%')
+			b.WriteByte(hex[c>>4])
+			b.WriteByte(hex[c&15])
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
+
 func (r *Request) resetGeminiURL() {
 	// Gemini specific handling
 	if r.URL.Path == "" {
@@ -144,19 +319,187 @@ func (r *Request) resetGeminiURL() {
 }
 
 // ReadTitanPayload reads titan payload from the stream into byte slice.
+// The buffer grows as the payload arrives rather than being allocated
+// for the declared size up front; uploads ending early fail with
+// io.ErrUnexpectedEOF. Limit the size accepted with
+// Server.MaxUploadSize, or use Titan.Reader to stream large uploads.
 func (r *Request) ReadTitanPayload() ([]byte, error) {
-	buf := make([]byte, r.Titan.Size)
-	_, err := io.ReadFull(r.Titan.Body, buf)
+	buf, err := io.ReadAll(r.Titan.Reader())
+	if err == nil && int64(len(buf)) < r.Titan.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if c, ok := r.Titan.Body.(*checksumReader); ok && err == nil {
+		err = c.err
+	}
 	return buf, err
 }
 
+// Reader returns a reader of the payload limited to Size bytes, so it
+// does not read past the upload on the connection.
+func (t *TitanRequest) Reader() *io.LimitedReader {
+	return &io.LimitedReader{R: t.Body, N: t.Size}
+}
+
+// ProgressFunc receives the progress of a titan upload: the bytes
+// transferred so far and the declared size. A non-nil error aborts the
+// upload, failing reads of the body with it.
+type ProgressFunc func(n, total int64) error
+
+// OnProgress calls fn as Body is read. Servers can use it to report large
+// uploads and to abort uploads arriving too slowly; clients set it on a
+// request from NewRequest to report the upload as it is sent.
+func (t *TitanRequest) OnProgress(fn ProgressFunc) {
+	if c, ok := t.Body.(*checksumReader); ok {
+		c.ReadCloser = &progressReader{ReadCloser: c.ReadCloser, total: t.Size, fn: fn}
+	} else if t.Body != nil {
+		t.Body = &progressReader{ReadCloser: t.Body, total: t.Size, fn: fn}
+	}
+	if getBody := t.getBody; getBody != nil {
+		t.getBody = func() (io.ReadCloser, error) {
+			rc, err := getBody()
+			if err != nil {
+				return nil, err
+			}
+			return &progressReader{ReadCloser: rc, total: t.Size, fn: fn}, nil
+		}
+	}
+}
+
+type progressReader struct {
+	io.ReadCloser
+	n, total int64
+	fn       ProgressFunc
+	err      error
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	if p.err != nil {
+		return 0, p.err
+	}
+	n, err := p.ReadCloser.Read(b)
+	if n > 0 {
+		p.n += int64(n)
+		if perr := p.fn(p.n, p.total); perr != nil {
+			p.err = perr
+			return n, perr
+		}
+	}
+	return n, err
+}
+
+// ErrChecksumMismatch is returned reading a titan payload that does not
+// match its declared sha256 parameter. Handlers should answer it with
+// 59 BAD REQUEST and discard the upload.
+var ErrChecksumMismatch = errors.New("gemini: titan payload checksum mismatch")
+
+// checksumReader hashes the first size bytes read and verifies them once
+// they have all been read. An empty payload is verified right away.
+type checksumReader struct {
+	io.ReadCloser
+	h         hash.Hash
+	remaining int64
+	want      string
+	err       error
+}
+
+func newChecksumReader(rc io.ReadCloser, size int64, want string) *checksumReader {
+	c := &checksumReader{ReadCloser: rc, h: sha256.New(), remaining: size, want: want}
+	if size <= 0 {
+		c.verify()
+	}
+	return c
+}
+
+// verify sets c.err if the digest does not match.
+func (c *checksumReader) verify() {
+	if hex.EncodeToString(c.h.Sum(nil)) != c.want {
+		c.err = ErrChecksumMismatch
+	}
+}
+
+func (c *checksumReader) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if c.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > c.remaining {
+		p = p[:c.remaining]
+	}
+	n, err := c.ReadCloser.Read(p)
+	c.h.Write(p[:n])
+	c.remaining -= int64(n)
+	if c.remaining == 0 {
+		if c.verify(); c.err != nil {
+			return n, c.err
+		}
+		if err == nil {
+			err = io.EOF
+		}
+	}
+	return n, err
+}
+
+// Certificate returns the client certificate sent with the request, or nil.
 func (r *Request) Certificate() *x509.Certificate {
+	if r.conn == nil {
+		return nil
+	}
 	if len(r.conn.ConnectionState().PeerCertificates) > 0 {
 		return r.conn.ConnectionState().PeerCertificates[0]
 	}
 	return nil
 }
 
+// Param returns the value of the named path segment matched by ServeMux,
+// or an empty string if there is no such segment.
+func (r *Request) Param(name string) string {
+	return r.params[name]
+}
+
+// SetParam sets the named path segment value returned by Param.
+func (r *Request) SetParam(name, value string) {
+	if r.params == nil {
+		r.params = make(map[string]string)
+	}
+	r.params[name] = value
+}
+
+// Query returns the decoded query of the request URL, the user input of
+// a request answering 10 INPUT. Unlike in HTML forms, "+" is not a space
+// in Gemini queries and is returned as is.
+func (r *Request) Query() (string, error) {
+	return url.PathUnescape(r.URL.RawQuery)
+}
+
+// QueryInt returns the query parsed as a decimal integer.
+func (r *Request) QueryInt() (int, error) {
+	q, err := r.Query()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(q))
+}
+
+// QueryBool returns the query parsed as a yes or no answer. It accepts
+// the values strconv.ParseBool does, as well as "yes", "no", "y" and
+// "n" in any case.
+func (r *Request) QueryBool() (bool, error) {
+	q, err := r.Query()
+	if err != nil {
+		return false, err
+	}
+	q = strings.TrimSpace(q)
+	switch strings.ToLower(q) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(q)
+}
+
 // Context returns the request's context. To change the context, use
 // WithContext.
 //
@@ -242,6 +585,7 @@ func (r *TitanRequest) clientRequest(body io.Reader) error {
 		rc = io.NopCloser(body)
 	}
 	r.Body = rc
+	r.sized = true
 	if body != nil {
 		switch v := body.(type) {
 		case *bytes.Buffer:
@@ -1,7 +1,12 @@
 package gemini_test
 
 import (
+	"context"
+	"errors"
+	"io"
+	"strings"
 	"testing"
+	"testing/iotest"
 
 	"github.com/kulak/gemini"
 	"github.com/stretchr/testify/require"
@@ -16,3 +21,195 @@ func TestReset(t *testing.T) {
 	require.Equal(t, int64(23), r.Titan.Size)
 	require.Equal(t, "", r.Titan.Token)
 }
+
+func TestResetTitanChecksum(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" // sha256("hello")
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/da;size=5;sha256="+sum))
+	require.Equal(t, sum, r.Titan.SHA256)
+
+	require.Error(t, r.Reset(nil, "titan://localhost/da;size=5;sha256=abc"))
+}
+
+func TestQuery(t *testing.T) {
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "gemini://localhost/?hello%20world"))
+	q, err := r.Query()
+	require.NoError(t, err)
+	require.Equal(t, "hello world", q)
+
+	require.NoError(t, r.Reset(nil, "gemini://localhost/?%2042"))
+	n, err := r.QueryInt()
+	require.NoError(t, err)
+	require.Equal(t, 42, n)
+	_, err = r.QueryBool()
+	require.Error(t, err)
+
+	for query, want := range map[string]bool{"yes": true, "Y": true, "true": true, "1": true, "No": false, "f": false} {
+		require.NoError(t, r.Reset(nil, "gemini://localhost/?"+query))
+		b, err := r.QueryBool()
+		require.NoError(t, err, query)
+		require.Equal(t, want, b, query)
+	}
+
+	require.NoError(t, r.Reset(nil, "gemini://localhost/?abc"))
+	_, err = r.QueryInt()
+	require.Error(t, err)
+}
+
+func TestTitanReader(t *testing.T) {
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/da;size=5"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("hellonext request"))
+	body, err := io.ReadAll(r.Titan.Reader())
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+
+	require.NoError(t, r.Reset(nil, "titan://localhost/da;size=1000000000"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("short"))
+	body, err = r.ReadTitanPayload()
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+	require.Equal(t, "short", string(body))
+}
+
+func TestGeminiURL(t *testing.T) {
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost:1966/notes/a%3Bb.gmi;token=x;size=2?q#f"))
+	require.Equal(t, "gemini://localhost:1966/notes/a%3Bb.gmi", r.GeminiURL().String())
+
+	req, err := gemini.NewRequestWithContext(context.Background(), "titan://localhost/notes/a.gmi;mime=text/plain", strings.NewReader("hi"))
+	require.NoError(t, err)
+	require.Equal(t, "gemini://localhost/notes/a.gmi", req.GeminiURL().String())
+
+	for ref, want := range map[string]string{
+		"":       "gemini://localhost:1966/notes/a%3Bb.gmi",
+		"./":     "gemini://localhost:1966/notes/",
+		"b.gmi":  "gemini://localhost:1966/notes/b.gmi",
+		"/index": "gemini://localhost:1966/index",
+	} {
+		w := serve(t, gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			gemini.RedirectAfterUpload(w, r, ref)
+		}), "titan://localhost:1966/notes/a%3Bb.gmi;size=0")
+		require.Equal(t, gemini.StatusTemporaryRedirect, w.status)
+		require.Equal(t, want, w.meta, ref)
+	}
+}
+
+func TestSpoolTitanPayload(t *testing.T) {
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/a;size=5"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("hello"))
+	p, err := r.SpoolTitanPayload(5)
+	require.NoError(t, err)
+	require.False(t, p.OnDisk())
+	require.NoError(t, p.Close())
+
+	require.NoError(t, r.Reset(nil, "titan://localhost/a;size=11"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("hello worldnext"))
+	p, err = r.SpoolTitanPayload(5)
+	require.NoError(t, err)
+	require.True(t, p.OnDisk())
+	require.Equal(t, int64(11), p.Size())
+	_, err = p.Seek(6, io.SeekStart)
+	require.NoError(t, err)
+	body, err := io.ReadAll(p)
+	require.NoError(t, err)
+	require.Equal(t, "world", string(body))
+	require.NoError(t, p.Close())
+
+	require.NoError(t, r.Reset(nil, "titan://localhost/a;size=11"))
+	r.Titan.Body = io.NopCloser(strings.NewReader("short"))
+	_, err = r.SpoolTitanPayload(0)
+	require.Equal(t, io.ErrUnexpectedEOF, err)
+}
+
+func TestTitanProgress(t *testing.T) {
+	abort := errors.New("too slow")
+	var calls []int64
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/a;size=5"))
+	r.Titan.Body = io.NopCloser(iotest.OneByteReader(strings.NewReader("hello")))
+	r.Titan.OnProgress(func(n, total int64) error {
+		require.Equal(t, int64(5), total)
+		calls = append(calls, n)
+		if n == 3 {
+			return abort
+		}
+		return nil
+	})
+	body, err := r.ReadTitanPayload()
+	require.Equal(t, abort, err)
+	require.Equal(t, "hel", string(body))
+	require.Equal(t, []int64{1, 2, 3}, calls)
+
+	calls = nil
+	req, err := gemini.NewRequestWithContext(context.Background(), "titan://localhost/a", strings.NewReader("hello"))
+	require.NoError(t, err)
+	req.Titan.OnProgress(func(n, total int64) error {
+		calls = append(calls, n)
+		return nil
+	})
+	body, err = io.ReadAll(req.Titan.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+	require.Equal(t, []int64{5}, calls)
+}
+
+func TestTitanIsDelete(t *testing.T) {
+	r := &gemini.Request{}
+	for rawurl, want := range map[string]bool{
+		"titan://localhost/a;size=0":                 true,
+		"titan://localhost/a;mime=text/plain;size=0": true,
+		"titan://localhost/a;size=5":                 false,
+		"titan://localhost/a;mime=text/plain":        false,
+		"titan://localhost/a;edit":                   false,
+	} {
+		require.NoError(t, r.Reset(nil, rawurl))
+		require.Equal(t, want, r.Titan.IsDelete(), rawurl)
+	}
+
+	req, err := gemini.NewRequestWithContext(context.Background(), "titan://localhost/a", nil)
+	require.NoError(t, err)
+	require.True(t, req.Titan.IsDelete())
+	req, err = gemini.NewRequestWithContext(context.Background(), "titan://localhost/a", strings.NewReader(""))
+	require.NoError(t, err)
+	require.True(t, req.Titan.IsDelete())
+	req, err = gemini.NewRequestWithContext(context.Background(), "titan://localhost/a", strings.NewReader("x"))
+	require.NoError(t, err)
+	require.False(t, req.Titan.IsDelete())
+}
+
+func TestResetTitanEscapedParams(t *testing.T) {
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, "titan://localhost/my%20page;token=a=b%3Bc;mime=text/plain%3Bcharset=utf-8;size=5;x%3Dy=1;lang=en"))
+	require.Equal(t, "/my page", r.URL.Path)
+	require.Equal(t, "a=b;c", r.Titan.Token)
+	require.Equal(t, "text/plain;charset=utf-8", r.Titan.Mime)
+	require.Equal(t, int64(5), r.Titan.Size)
+	require.Equal(t, map[string]string{"x=y": "1", "lang": "en"}, r.Titan.Params)
+
+	require.Error(t, r.Reset(nil, "titan://localhost/a;token=%zz;size=1"))
+}
+
+func TestTitanURL(t *testing.T) {
+	req, err := gemini.NewRequestWithContext(context.Background(), "titan://localhost/my%20page", strings.NewReader("hello"))
+	require.NoError(t, err)
+	req.Titan.Token = "a=b;c%"
+	req.Titan.Mime = "text/plain; charset=utf-8"
+	req.Titan.Params = map[string]string{"x=y": "1", "lang": "en"}
+	rawurl := req.TitanURL()
+	require.Equal(t, "titan://localhost/my%20page;token=a=b%3Bc%25;mime=text/plain%3B%20charset=utf-8;size=5;lang=en;x%3Dy=1", rawurl)
+
+	r := &gemini.Request{}
+	require.NoError(t, r.Reset(nil, rawurl))
+	require.Equal(t, "/my page", r.URL.Path)
+	require.Equal(t, req.Titan.Token, r.Titan.Token)
+	require.Equal(t, req.Titan.Mime, r.Titan.Mime)
+	require.Equal(t, int64(5), r.Titan.Size)
+	require.Equal(t, req.Titan.Params, r.Titan.Params)
+
+	edit, err := gemini.NewRequestWithContext(context.Background(), "titan://localhost/page", nil)
+	require.NoError(t, err)
+	edit.Titan.Edit = true
+	require.Equal(t, "titan://localhost/page;edit", edit.TitanURL())
+}
@@ -0,0 +1,231 @@
+package gemini
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RotatingFile is an io.WriteCloser appending to a log file that is
+// rotated by size or time, for use with NewAccessLog, NewCombinedLog or
+// NewJSONLog:
+//
+//	logs := &gemini.RotatingFile{
+//		Filename:   "/var/log/gemini/access.log",
+//		MaxSize:    100 << 20,
+//		Interval:   24 * time.Hour,
+//		MaxBackups: 7,
+//		Compress:   true,
+//	}
+//	defer logs.Close()
+//	srv.AccessLog = gemini.NewJSONLog(logs)
+//
+// Rotated files are renamed to Filename with the rotation time appended,
+// such as access.log.20060102T150405, and optionally compressed with gzip.
+// It is safe for concurrent use.
+type RotatingFile struct {
+	// Filename is the file to write to. Its directory must exist.
+	Filename string
+
+	// MaxSize rotates the file before a write would make it larger than
+	// MaxSize bytes. Zero disables size based rotation.
+	MaxSize int64
+
+	// Interval rotates the file when the current time enters a new
+	// interval since the zero time, such as a new UTC day for 24 hours.
+	// Zero disables time based rotation.
+	Interval time.Duration
+
+	// MaxBackups is the number of rotated files to keep. Zero keeps all.
+	MaxBackups int
+
+	// MaxAge removes rotated files older than MaxAge. Zero keeps them.
+	MaxAge time.Duration
+
+	// Compress compresses rotated files with gzip during rotation.
+	Compress bool
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	file   *os.File
+	size   int64
+	opened time.Time
+}
+
+// Write appends p to the file, rotating it first if needed.
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return 0, err
+		}
+	}
+	if rf.due(int64(len(p))) {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Rotate rotates the file now.
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		if err := rf.open(); err != nil {
+			return err
+		}
+	}
+	return rf.rotate()
+}
+
+// Close closes the file. A later Write opens it again.
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *RotatingFile) now() time.Time {
+	if rf.Now != nil {
+		return rf.Now()
+	}
+	return time.Now()
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.Filename, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	rf.file, rf.size = f, info.Size()
+	rf.opened = rf.now()
+	if rf.size > 0 {
+		rf.opened = info.ModTime()
+	}
+	return nil
+}
+
+// due reports whether the file must be rotated before writing n bytes.
+func (rf *RotatingFile) due(n int64) bool {
+	if rf.size == 0 {
+		return false
+	}
+	if rf.MaxSize > 0 && rf.size+n > rf.MaxSize {
+		return true
+	}
+	return rf.Interval > 0 && !rf.now().Truncate(rf.Interval).Equal(rf.opened.Truncate(rf.Interval))
+}
+
+func (rf *RotatingFile) rotate() error {
+	if err := rf.file.Close(); err != nil {
+		return err
+	}
+	rf.file = nil
+	now := rf.now()
+	name := rf.Filename + "." + now.UTC().Format("20060102T150405")
+	for i := 1; exists(name) || exists(name+".gz"); i++ {
+		name = fmt.Sprintf("%s.%s.%d", rf.Filename, now.UTC().Format("20060102T150405"), i)
+	}
+	if err := os.Rename(rf.Filename, name); err != nil {
+		return err
+	}
+	if err := rf.open(); err != nil {
+		return err
+	}
+	rf.opened = now
+	if rf.Compress {
+		if err := gzipFile(name); err != nil {
+			return err
+		}
+	}
+	return rf.prune(now)
+}
+
+func exists(name string) bool {
+	_, err := os.Lstat(name)
+	return err == nil
+}
+
+// gzipFile replaces name with name.gz.
+func gzipFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(name+".gz", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(name + ".gz")
+		return err
+	}
+	return os.Remove(name)
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge.
+func (rf *RotatingFile) prune(now time.Time) error {
+	if rf.MaxBackups <= 0 && rf.MaxAge <= 0 {
+		return nil
+	}
+	matches, err := filepath.Glob(rf.Filename + ".*")
+	if err != nil {
+		return err
+	}
+	type backup struct {
+		name    string
+		modTime time.Time
+	}
+	var backups []backup
+	prefix := filepath.Base(rf.Filename) + "."
+	for _, m := range matches {
+		if !strings.HasPrefix(filepath.Base(m), prefix) {
+			continue
+		}
+		if info, err := os.Stat(m); err == nil && info.Mode().IsRegular() {
+			backups = append(backups, backup{m, info.ModTime()})
+		}
+	}
+	// Names embed the rotation time; sort them newest first.
+	sort.Slice(backups, func(i, j int) bool { return backups[i].name > backups[j].name })
+	var firstErr error
+	for i, b := range backups {
+		if (rf.MaxBackups > 0 && i >= rf.MaxBackups) || (rf.MaxAge > 0 && now.Sub(b.modTime) > rf.MaxAge) {
+			if err := os.Remove(b.name); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,93 @@
+package gemini_test
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotatingFile(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	rf := &gemini.RotatingFile{
+		Filename:   filepath.Join(dir, "access.log"),
+		MaxSize:    16,
+		Interval:   24 * time.Hour,
+		MaxBackups: 2,
+		Compress:   true,
+		Now:        func() time.Time { return now },
+	}
+	defer rf.Close()
+
+	write := func(s string) {
+		t.Helper()
+		_, err := io.WriteString(rf, s)
+		require.NoError(t, err)
+	}
+	backups := func() []string {
+		t.Helper()
+		m, err := filepath.Glob(rf.Filename + ".*")
+		require.NoError(t, err)
+		sort.Strings(m)
+		for i := range m {
+			m[i] = filepath.Base(m[i])
+		}
+		return m
+	}
+
+	write("123456789\n")
+	write("abcde\n") // fits in MaxSize
+	require.Empty(t, backups())
+	now = now.Add(time.Second)
+	write("next\n") // exceeds MaxSize
+	require.Equal(t, []string{"access.log.20240101T100001.gz"}, backups())
+
+	now = now.Add(time.Hour)
+	write("same day\n")
+	require.Len(t, backups(), 1)
+	now = now.Add(24 * time.Hour)
+	write("x\n") // fits, but starts a new day
+	require.Equal(t, []string{"access.log.20240101T100001.gz", "access.log.20240102T110001.gz"}, backups())
+
+	now = now.Add(time.Second)
+	require.NoError(t, rf.Rotate())
+	require.Equal(t, []string{"access.log.20240102T110001.gz", "access.log.20240102T110002.gz"}, backups())
+
+	f, err := os.Open(filepath.Join(dir, "access.log.20240102T110001.gz"))
+	require.NoError(t, err)
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	require.NoError(t, err)
+	b, err := io.ReadAll(zr)
+	require.NoError(t, err)
+	require.Equal(t, "next\nsame day\n", string(b))
+}
+
+func TestAccessLogFormats(t *testing.T) {
+	e := gemini.AccessLogEntry{
+		Time:       time.Date(2024, 3, 4, 5, 6, 7, 0, time.UTC),
+		RemoteAddr: "192.0.2.1:5000",
+		URL:        "gemini://example.org/",
+		Status:     gemini.StatusSuccess,
+		Meta:       "text/gemini",
+		Bytes:      1024,
+		Duration:   1500 * time.Microsecond,
+		Err:        io.ErrUnexpectedEOF,
+	}
+	var b strings.Builder
+	gemini.NewCombinedLog(&b).LogAccess(e)
+	require.Equal(t, `192.0.2.1 - - [04/Mar/2024:05:06:07 +0000] "gemini://example.org/" 20 1024 "text/gemini" "-"`+"\n", b.String())
+
+	b = strings.Builder{}
+	gemini.NewJSONLog(&b).LogAccess(e)
+	require.JSONEq(t, `{"time":"2024-03-04T05:06:07Z","remote_addr":"192.0.2.1:5000","url":"gemini://example.org/",`+
+		`"status":20,"meta":"text/gemini","bytes":1024,"duration_ms":1.5,"error":"unexpected EOF"}`, b.String())
+}
@@ -0,0 +1,171 @@
+package gemini
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Schedule is middleware changing how requests are handled during time
+// windows, such as turning off expensive endpoints at busy hours or
+// answering everything with a maintenance notice during a planned
+// outage:
+//
+//	sched := &gemini.Schedule{Rules: []gemini.ScheduleRule{
+//		{Prefix: "/search", From: "18:00", To: "22:00"},
+//		{Start: start, End: start.Add(2 * time.Hour), Handler: maintenancePage},
+//	}}
+//	srv.Handler = sched.Middleware(mux)
+//
+// The first matching rule applies; requests matching none are passed on.
+type Schedule struct {
+	Rules []ScheduleRule
+
+	// Location is the time zone of daily windows. Defaults to time.Local.
+	Location *time.Location
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+
+	once sync.Once
+}
+
+// ScheduleRule is a time window during which matching requests are
+// answered with Status and Meta, or by Handler.
+type ScheduleRule struct {
+	// Prefix limits the rule to request paths starting with it.
+	Prefix string
+
+	// From and To are the daily window, as "15:04" times of day; a window
+	// ending before it starts spans midnight. Days optionally limits the
+	// window to the days it starts on.
+	From, To string
+	Days     []time.Weekday
+
+	// Start and End, when set, make the rule apply from Start until End
+	// instead of daily. Either may be left zero for an open interval.
+	Start, End time.Time
+
+	// Handler serves matching requests if set.
+	Handler Handler
+
+	// Status and Meta answer matching requests when Handler is nil.
+	// Status defaults to 41 and Meta to a message saying when the
+	// window ends.
+	Status StatusCode
+	Meta   string
+
+	from, to int // minutes after midnight
+}
+
+// Middleware returns next wrapped in the schedule. It panics if a rule
+// has an invalid time of day.
+func (s *Schedule) Middleware(next Handler) Handler {
+	s.once.Do(func() {
+		for i := range s.Rules {
+			rule := &s.Rules[i]
+			if !rule.Start.IsZero() || !rule.End.IsZero() {
+				continue
+			}
+			var err error
+			if rule.from, err = parseTimeOfDay(rule.From); err == nil {
+				rule.to, err = parseTimeOfDay(rule.To)
+			}
+			if err != nil {
+				panic(fmt.Sprintf("gemini: schedule rule %d: %v", i, err))
+			}
+		}
+	})
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			now := time.Now
+			if s.Now != nil {
+				now = s.Now
+			}
+			loc := s.Location
+			if loc == nil {
+				loc = time.Local
+			}
+			t := now().In(loc)
+			for i := range s.Rules {
+				rule := &s.Rules[i]
+				if !strings.HasPrefix(r.URL.Path, rule.Prefix) {
+					continue
+				}
+				end, ok := rule.active(t)
+				if !ok {
+					continue
+				}
+				switch {
+				case rule.Handler != nil:
+					rule.Handler.ServeGemini(w, r)
+				case rule.Meta != "":
+					w.WriteStatusMsg(rule.status(), rule.Meta)
+				case end.IsZero():
+					w.WriteStatusMsg(rule.status(), Message(r, "Temporarily unavailable"))
+				default:
+					w.WriteStatusMsg(rule.status(), messagef(r, "Unavailable until %s", end.In(loc).Format("2006-01-02 15:04 MST")))
+				}
+				return
+			}
+			next.ServeGemini(w, r)
+		}
+	})
+}
+
+func (rule *ScheduleRule) status() StatusCode {
+	if rule.Status == 0 {
+		return StatusServerUnavalable
+	}
+	return rule.Status
+}
+
+// active reports whether the rule applies at t and when its window ends;
+// the end is zero for open intervals.
+func (rule *ScheduleRule) active(t time.Time) (time.Time, bool) {
+	if !rule.Start.IsZero() || !rule.End.IsZero() {
+		if (!rule.Start.IsZero() && t.Before(rule.Start)) || (!rule.End.IsZero() && !t.Before(rule.End)) {
+			return time.Time{}, false
+		}
+		return rule.End, true
+	}
+	// Check the window starting today and the one starting yesterday,
+	// which may span midnight.
+	for _, back := range []int{0, 1} {
+		day := time.Date(t.Year(), t.Month(), t.Day()-back, 0, 0, 0, 0, t.Location())
+		if !rule.onDay(day.Weekday()) {
+			continue
+		}
+		start := time.Date(day.Year(), day.Month(), day.Day(), 0, rule.from, 0, 0, day.Location())
+		end := time.Date(day.Year(), day.Month(), day.Day(), 0, rule.to, 0, 0, day.Location())
+		if rule.to <= rule.from {
+			end = end.AddDate(0, 0, 1)
+		}
+		if !t.Before(start) && t.Before(end) {
+			return end, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func (rule *ScheduleRule) onDay(d time.Weekday) bool {
+	if len(rule.Days) == 0 {
+		return true
+	}
+	for _, day := range rule.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeOfDay returns the minutes after midnight of s.
+func parseTimeOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,49 @@
+package gemini_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2024, 3, 1, 19, 0, 0, 0, time.UTC) // a Friday
+	maintenance := time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)
+	sched := &gemini.Schedule{
+		Rules: []gemini.ScheduleRule{
+			{Start: maintenance, End: maintenance.Add(time.Hour), Handler: text("maintenance")},
+			{Prefix: "/search", From: "18:00", To: "22:00", Status: gemini.StatusSlowDown, Meta: "60"},
+			{Prefix: "/night", From: "23:00", To: "01:00", Days: []time.Weekday{time.Friday}},
+		},
+		Location: time.UTC,
+		Now:      func() time.Time { return now },
+	}
+	h := sched.Middleware(text("ok"))
+
+	w := serve(t, h, "gemini://localhost/search")
+	require.Equal(t, gemini.StatusSlowDown, w.status)
+	require.Equal(t, "60", w.meta)
+	require.Equal(t, "ok", serve(t, h, "gemini://localhost/").body.String())
+	require.Equal(t, "ok", serve(t, h, "gemini://localhost/night").body.String())
+
+	now = time.Date(2024, 3, 2, 0, 30, 0, 0, time.UTC) // Saturday, in Friday's window
+	w = serve(t, h, "gemini://localhost/night")
+	require.Equal(t, gemini.StatusServerUnavalable, w.status)
+	require.Equal(t, "Unavailable until 2024-03-02 01:00 UTC", w.meta)
+	require.Equal(t, "ok", serve(t, h, "gemini://localhost/search").body.String())
+
+	now = time.Date(2024, 3, 2, 23, 30, 0, 0, time.UTC) // Saturday night
+	require.Equal(t, "ok", serve(t, h, "gemini://localhost/night").body.String())
+
+	now = maintenance.Add(30 * time.Minute)
+	require.Equal(t, "maintenance", serve(t, h, "gemini://localhost/search").body.String())
+	now = maintenance.Add(time.Hour)
+	require.Equal(t, "ok", serve(t, h, "gemini://localhost/").body.String())
+}
+
+func TestScheduleInvalidRule(t *testing.T) {
+	sched := &gemini.Schedule{Rules: []gemini.ScheduleRule{{From: "25:00", To: "01:00"}}}
+	require.Panics(t, func() { sched.Middleware(text("ok")) })
+}
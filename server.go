@@ -1,79 +1,536 @@
 package gemini
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/url"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// Server defines parameters for running a Gemini server.
+// The zero value for Server is a valid configuration.
+type Server struct {
+	// Addr optionally specifies the TCP address for the server to listen on,
+	// in the form "host:port". If empty, "127.0.0.1:1965" is used.
+	Addr string
+
+	// Handler to invoke for each request. Titan requests are passed to
+	// its ServeTitan method if it implements TitanHandler.
+	Handler Handler
+
+	// AccessLog optionally receives an entry for every handled request.
+	// If nil, requests are not logged.
+	AccessLog AccessLogger
+
+	// GeoLocator optionally enriches access log entries with the client
+	// country.
+	GeoLocator GeoLocator
+
+	// ErrorLog specifies an optional logger for errors accepting
+	// connections and unexpected behavior from handlers.
+	// If nil, logging is done via the log package's standard logger.
+	ErrorLog *log.Logger
+
+	// ConnState specifies an optional callback function that is
+	// called when a client connection changes state. See the
+	// ConnState type and associated constants for details.
+	ConnState func(net.Conn, ConnState)
+
+	// LoadShedder optionally rejects requests while the server is
+	// overloaded.
+	LoadShedder *LoadShedder
+
+	// Hosts optionally lists the host names the server serves, either as
+	// "host" matching any port or "host:port". Requests for other hosts are
+	// answered with 53 PROXY REQUEST REFUSED. If empty, any host is served.
+	Hosts []string
+
+	// HostPolicy optionally decides whether a request for host and port is
+	// served. The port is "1965" when the URL does not specify one.
+	// It is consulted instead of Hosts when set.
+	HostPolicy func(host, port string) bool
+
+	// Messages optionally translates the messages generated by the
+	// server and the handlers of the package, see Localize.
+	Messages Messages
+
+	// TLSConfig optionally provides a TLS configuration for use by
+	// ListenAndServeTLS. Certificates are loaded from the files given to
+	// ListenAndServeTLS. ClientAuth defaults to tls.RequestClientCert.
+	TLSConfig *tls.Config
+
+	// AutoCert makes ListenAndServeTLS generate a self-signed certificate
+	// when neither the certificate nor the key file exists, and save it to
+	// them so it stays the same across restarts. The certificate is valid
+	// for the host names of Hosts, or of Addr if Hosts is empty, and
+	// localhost if neither names one.
+	AutoCert bool
+
+	// VerifyClientCertificate optionally authorizes a request before the
+	// handler runs. cert is nil when the client did not present one.
+	// A non-nil error rejects the request: ErrCertificateRequired,
+	// ErrCertificateNotValid and errors wrapping them map to 60 and 62,
+	// any other error to 61. The error text is sent as the meta.
+	VerifyClientCertificate func(r *Request, cert *x509.Certificate) error
+
+	// HandshakeTimeout is the maximum duration for completing the TLS
+	// handshake. Zero means no timeout.
+	HandshakeTimeout time.Duration
+
+	// HandshakeError optionally receives failed TLS handshakes. The
+	// connection then moves to StateHandshakeFailed and StateClosed.
+	HandshakeError func(net.Conn, *HandshakeError)
+
+	// WriteTimeout is the maximum duration of a single response write.
+	// Zero means no timeout.
+	WriteTimeout time.Duration
+
+	// WriteRate optionally limits response body throughput, in bytes
+	// per second.
+	WriteRate int64
+
+	// KeepAlive specifies the TCP keep-alive period for accepted
+	// connections. Zero uses the operating system default; a negative
+	// value disables keep-alive probes.
+	KeepAlive time.Duration
+
+	// MaxUploadSize optionally limits the size a titan request may
+	// declare. Larger uploads are answered with 59 BAD REQUEST before
+	// the handler runs and are logged with ErrUploadTooLarge.
+	// Zero means no limit.
+	MaxUploadSize int64
+
+	// UploadTypes optionally lists the MIME types titan requests may
+	// declare, such as "text/gemini" or "image/*". Other uploads are
+	// answered with 59 BAD REQUEST before the handler runs and are logged
+	// with ErrUploadTypeNotAllowed. Uploads without a mime parameter are
+	// text/gemini; deletes are always allowed.
+	UploadTypes []string
+
+	// UploadProgress optionally receives the progress of titan uploads as
+	// handlers read them, see TitanRequest.OnProgress. A non-nil error
+	// aborts the upload.
+	UploadProgress func(r *Request, n, total int64) error
+
+	mu                sync.Mutex
+	handshakeFailures map[HandshakeFailure]uint64
+	listeners         map[net.Listener]struct{}
+	conns             map[net.Conn]struct{}
+	inShutdown        int32
+}
+
+// ErrServerClosed is returned by the Server's Serve and ListenAndServeTLS
+// methods after a call to Shutdown or Close.
+var ErrServerClosed = errors.New("gemini: Server closed")
+
+// ErrUploadTooLarge is reported in the access log for titan requests
+// rejected because of Server.MaxUploadSize.
+var ErrUploadTooLarge = errors.New("gemini: upload too large")
+
+// ErrUploadTypeNotAllowed is reported in the access log for titan requests
+// rejected because of Server.UploadTypes.
+var ErrUploadTypeNotAllowed = errors.New("gemini: upload type not allowed")
+
+// maxUploadDiscard bounds how much of an unread upload is drained so the
+// client gets to read the response instead of a connection reset.
+const maxUploadDiscard = 64 << 10
+
+// A ConnState represents the state of a client connection to a server.
+// It's used by the optional Server.ConnState hook.
+type ConnState int
+
+const (
+	// StateNew represents a new connection that is expected to
+	// send a request immediately.
+	StateNew ConnState = iota
+
+	// StateActive represents a connection that has read the request
+	// header and is being served by the handler.
+	StateActive
+
+	// StateClosed represents a closed connection.
+	// This is a terminal state.
+	StateClosed
+
+	// StateHijacked represents a connection taken over by the handler.
+	// This is a terminal state; the server does not close the connection.
+	StateHijacked
+
+	// StateHandshakeFailed represents a connection whose TLS handshake
+	// failed. It is followed by StateClosed.
+	StateHandshakeFailed
+)
+
+var stateName = map[ConnState]string{
+	StateNew:             "new",
+	StateActive:          "active",
+	StateClosed:          "closed",
+	StateHijacked:        "hijacked",
+	StateHandshakeFailed: "handshake failed",
+}
+
+func (c ConnState) String() string {
+	return stateName[c]
+}
+
 // ListenAndServe create a TCP server on the specified address and pass
 // new connections to the given handler.
 // Each request is handled in a separate goroutine.
 func ListenAndServe(addr, certFile, keyFile string, handler Handler) error {
+	srv := &Server{Addr: addr, Handler: handler}
+	return srv.ListenAndServeTLS(certFile, keyFile)
+}
+
+// ListenAndServeTLS listens on srv.Addr using the certificate and matching
+// private key files and then calls Serve to handle incoming connections.
+func (srv *Server) ListenAndServeTLS(certFile, keyFile string) error {
+	addr := srv.Addr
 	if addr == "" {
 		addr = "127.0.0.1:1965"
 	}
 
-	listener, err := listen(addr, certFile, keyFile)
-	if err != nil {
-		return err
-	}
-
-	err = serve(listener, handler)
+	listener, err := srv.listen(addr, certFile, keyFile)
 	if err != nil {
 		return err
 	}
 
-	err = listener.Close()
-	if err != nil {
-		return fmt.Errorf("failed to close the listener: %v", err)
-	}
-
-	return nil
+	return srv.Serve(listener)
 }
 
-func listen(addr, certFile, keyFile string) (net.Listener, error) {
+func (srv *Server) listen(addr, certFile, keyFile string) (net.Listener, error) {
+	if srv.AutoCert {
+		if err := srv.createCert(addr, certFile, keyFile); err != nil {
+			return nil, fmt.Errorf("failed to create certificate: %v", err)
+		}
+	}
 	cer, err := tls.LoadX509KeyPair(certFile, keyFile)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load certificates: %v", err)
 	}
 
 	config := &tls.Config{
-		Certificates:       []tls.Certificate{cer},
 		InsecureSkipVerify: true,
 		ClientAuth:         tls.RequestClientCert,
 	}
-	ln, err := tls.Listen("tcp", addr, config)
+	if srv.TLSConfig != nil {
+		config = srv.TLSConfig.Clone()
+	}
+	config.Certificates = []tls.Certificate{cer}
+	lc := net.ListenConfig{KeepAlive: srv.KeepAlive}
+	ln, err := lc.Listen(context.Background(), "tcp", addr)
 	if err != nil {
 		return nil, fmt.Errorf("failed to listen: %v", err)
 	}
 
-	return ln, nil
+	return tls.NewListener(ln, config), nil
 }
 
-func serve(listener net.Listener, handler Handler) error {
+// Serve accepts incoming connections on the TLS listener, creating a new
+// service goroutine for each. Serve closes the listener when it returns.
+// Serve always returns a non-nil error; after Shutdown or Close it is
+// ErrServerClosed.
+func (srv *Server) Serve(listener net.Listener) error {
+	defer listener.Close()
+	if !srv.trackListener(listener, true) {
+		return ErrServerClosed
+	}
+	defer srv.trackListener(listener, false)
+
+	var tempDelay time.Duration
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
+			if srv.shuttingDown() {
+				return ErrServerClosed
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return err
+			}
+			if tempDelay == 0 {
+				tempDelay = 5 * time.Millisecond
+			} else if tempDelay *= 2; tempDelay > time.Second {
+				tempDelay = time.Second
+			}
+			srv.logf("gemini: Accept error: %v; retrying in %v", err, tempDelay)
+			time.Sleep(tempDelay)
 			continue
 		}
+		tempDelay = 0
 		tlsConn := conn.(*tls.Conn)
-		go handleConnection(tlsConn, handler)
+		srv.trackConn(tlsConn, true)
+		srv.setState(tlsConn, StateNew)
+		go srv.handleConnection(tlsConn)
+	}
+}
+
+// Close immediately closes all listeners and connections.
+func (srv *Server) Close() error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	err := srv.closeListenersLocked()
+	for c := range srv.conns {
+		c.Close()
+		delete(srv.conns, c)
+	}
+	return err
+}
+
+// Shutdown gracefully shuts down the server: it closes all listeners and
+// then waits for active connections to finish. If ctx expires first, the
+// context's error is returned and remaining connections are left open;
+// call Close to drop them.
+func (srv *Server) Shutdown(ctx context.Context) error {
+	atomic.StoreInt32(&srv.inShutdown, 1)
+	srv.mu.Lock()
+	err := srv.closeListenersLocked()
+	srv.mu.Unlock()
+
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		srv.mu.Lock()
+		idle := len(srv.conns) == 0
+		srv.mu.Unlock()
+		if idle {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
 	}
 }
 
-func handleConnection(conn *tls.Conn, handler Handler) {
-	defer conn.Close()
+func (srv *Server) shuttingDown() bool {
+	return atomic.LoadInt32(&srv.inShutdown) != 0
+}
+
+func (srv *Server) closeListenersLocked() error {
+	var err error
+	for ln := range srv.listeners {
+		if cerr := ln.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// trackListener adds or removes ln from the set of listeners closed by
+// Shutdown. Adding reports false if the server is shutting down.
+func (srv *Server) trackListener(ln net.Listener, add bool) bool {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.shuttingDown() {
+			return false
+		}
+		if srv.listeners == nil {
+			srv.listeners = make(map[net.Listener]struct{})
+		}
+		srv.listeners[ln] = struct{}{}
+	} else {
+		delete(srv.listeners, ln)
+	}
+	return true
+}
+
+func (srv *Server) trackConn(c net.Conn, add bool) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if add {
+		if srv.conns == nil {
+			srv.conns = make(map[net.Conn]struct{})
+		}
+		srv.conns[c] = struct{}{}
+	} else {
+		delete(srv.conns, c)
+	}
+}
+
+func (srv *Server) handleConnection(conn *tls.Conn) {
+	var r *response
+	defer func() {
+		srv.trackConn(conn, false)
+		if r != nil && r.hijacked {
+			srv.setState(conn, StateHijacked)
+			return
+		}
+		conn.Close()
+		srv.setState(conn, StateClosed)
+	}()
+	start := time.Now()
+	if err := srv.handshake(conn); err != nil {
+		return
+	}
+	if ls := srv.LoadShedder; ls != nil && ls.Overloaded() && !ls.prioritized(conn) {
+		ls.reject(&response{conn: conn})
+		return
+	}
 	request, err := getRequest(conn)
 	if err != nil {
 		return
 	}
-	r := &response{conn: conn}
+	srv.setState(conn, StateActive)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	request.ctx = ctx
+	if srv.Messages != nil {
+		request.ctx = context.WithValue(ctx, messagesContextKey{}, srv.Messages)
+	}
+	r = &response{conn: conn, writeTimeout: srv.WriteTimeout, rate: srv.WriteRate}
+	defer func() {
+		if request.URL.Scheme == SchemaTitan && !r.hijacked {
+			discardUpload(conn, request.Titan.Remaining())
+		}
+	}()
+	defer srv.logAccess(request, r, start)
+	defer func() {
+		if v := recover(); v != nil {
+			srv.logf("gemini: panic serving %s: %v\n%s", request.RemoteAddr, v, debug.Stack())
+			if !r.headerWritten && !r.hijacked {
+				_ = r.WriteStatusMsg(StatusUnspecified, Message(request, "Internal Server Error"))
+			}
+		}
+	}()
+
+	if !srv.servesHost(request.URL) {
+		_ = r.WriteStatusMsg(StatusProxyRefused, Message(request, "Proxy Request Refused"))
+		return
+	}
+	if err := checkUpload(request, srv.MaxUploadSize, srv.UploadTypes); err != nil {
+		if rejectUpload(r, request, err, srv.MaxUploadSize) == nil {
+			r.err = err
+		}
+		return
+	}
+	if progress := srv.UploadProgress; progress != nil && request.URL.Scheme == SchemaTitan && request.Titan.Size > 0 {
+		request.Titan.OnProgress(func(n, total int64) error {
+			return progress(request, n, total)
+		})
+	}
+	if verify := srv.VerifyClientCertificate; verify != nil {
+		if err := verify(request, request.Certificate()); err != nil {
+			_ = r.WriteStatusMsg(certErrorStatus(err), Message(request, err.Error()))
+			return
+		}
+	}
+	if th, ok := srv.Handler.(TitanHandler); ok && request.URL.Scheme == SchemaTitan {
+		th.ServeTitan(r, request)
+	} else {
+		srv.Handler.ServeGemini(r, request)
+	}
+	if ls := srv.LoadShedder; ls != nil {
+		ls.observe(time.Since(start))
+	}
+}
+
+func (srv *Server) handshake(conn *tls.Conn) error {
+	if srv.HandshakeTimeout > 0 {
+		conn.SetDeadline(time.Now().Add(srv.HandshakeTimeout))
+		defer conn.SetDeadline(time.Time{})
+	}
+	err := conn.Handshake()
+	if err == nil {
+		return nil
+	}
+	herr := newHandshakeError(err)
+	srv.mu.Lock()
+	if srv.handshakeFailures == nil {
+		srv.handshakeFailures = make(map[HandshakeFailure]uint64)
+	}
+	srv.handshakeFailures[herr.Reason]++
+	srv.mu.Unlock()
+	if srv.HandshakeError != nil {
+		srv.HandshakeError(conn, herr)
+	}
+	srv.setState(conn, StateHandshakeFailed)
+	return herr
+}
+
+// HandshakeFailures returns the number of failed TLS handshakes by reason.
+func (srv *Server) HandshakeFailures() map[HandshakeFailure]uint64 {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	counts := make(map[HandshakeFailure]uint64, len(srv.handshakeFailures))
+	for k, v := range srv.handshakeFailures {
+		counts[k] = v
+	}
+	return counts
+}
+
+func (srv *Server) servesHost(u *url.URL) bool {
+	if len(srv.Hosts) == 0 && srv.HostPolicy == nil {
+		return true
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "1965"
+	}
+	if srv.HostPolicy != nil {
+		return srv.HostPolicy(host, port)
+	}
+	for _, h := range srv.Hosts {
+		if strings.EqualFold(h, host) || strings.EqualFold(h, net.JoinHostPort(host, port)) {
+			return true
+		}
+	}
+	return false
+}
+
+// discardUpload reads and drops up to maxUploadDiscard bytes of an upload
+// the handler did not read, giving up after a second.
+func discardUpload(conn net.Conn, size int64) {
+	if size <= 0 {
+		return
+	}
+	if size > maxUploadDiscard {
+		size = maxUploadDiscard
+	}
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	_, _ = io.CopyN(io.Discard, conn, size)
+}
+
+func (srv *Server) setState(conn net.Conn, state ConnState) {
+	if hook := srv.ConnState; hook != nil {
+		hook(conn, state)
+	}
+}
 
-	handler.ServeGemini(r, request)
+func (srv *Server) logAccess(request *Request, r *response, start time.Time) {
+	if srv.AccessLog == nil {
+		return
+	}
+	srv.AccessLog.LogAccess(AccessLogEntry{
+		Time:       start,
+		RemoteAddr: request.RemoteAddr,
+		URL:        request.URL.String(),
+		Status:     r.Status(),
+		Meta:       r.Meta(),
+		Bytes:      r.BytesWritten(),
+		Duration:   time.Since(start),
+		Country:    lookupCountry(srv.GeoLocator, RemoteIP(request)),
+		Err:        r.err,
+	})
+}
+
+func (srv *Server) logf(format string, args ...interface{}) {
+	if srv.ErrorLog != nil {
+		srv.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
 }
 
 func getRequest(conn *tls.Conn) (*Request, error) {
@@ -81,23 +538,62 @@ func getRequest(conn *tls.Conn) (*Request, error) {
 	if err != nil {
 		return nil, err
 	}
-	header := string(headerBytes)
-	decodedHeader, err := url.QueryUnescape(header)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode URL: %s, error: %v", header, err)
-	}
-	log.Printf("raw request: %s, decoded: %s", header, decodedHeader)
-	r := &Request{}
-	return r, r.Reset(conn, decodedHeader)
+	r := &Request{RemoteAddr: conn.RemoteAddr().String()}
+	return r, r.Reset(conn, string(headerBytes))
 }
 
 type response struct {
 	headerWritten bool
 	conn          net.Conn
 	err           error
+
+	status  StatusCode
+	meta    string
+	written int64
+
+	writeTimeout time.Duration
+	rate         int64
+	bodyStart    time.Time
+	hijacked     bool
+}
+
+var (
+	_ Writer        = (*response)(nil)
+	_ Flusher       = (*response)(nil)
+	_ ResponseInfo  = (*response)(nil)
+	_ Hijacker      = (*response)(nil)
+	_ ErrorRecorder = (*response)(nil)
+)
+
+// Hijack implements Hijacker.
+func (w *response) Hijack() (*tls.Conn, error) {
+	if w.hijacked {
+		return nil, ErrHijacked
+	}
+	conn, ok := w.conn.(*tls.Conn)
+	if !ok {
+		return nil, errors.New("gemini: connection can not be hijacked")
+	}
+	w.hijacked = true
+	w.conn.SetWriteDeadline(time.Time{})
+	return conn, nil
 }
 
-var _ ResponseWriter = (*response)(nil)
+// Status implements ResponseInfo.
+func (w *response) Status() StatusCode { return w.status }
+
+// Meta implements ResponseInfo.
+func (w *response) Meta() string { return w.meta }
+
+// BytesWritten implements ResponseInfo.
+func (w *response) BytesWritten() int64 { return w.written }
+
+// RecordError implements ErrorRecorder. The first error is kept.
+func (w *response) RecordError(err error) {
+	if w.err == nil {
+		w.err = err
+	}
+}
 
 func (w *response) WriteRequest(req *url.URL) error {
 	if w.headerWritten {
@@ -113,6 +609,9 @@ func (w *response) WriteRequest(req *url.URL) error {
 }
 
 func (w *response) WriteStatusMsg(status StatusCode, msg string) error {
+	if w.hijacked {
+		return ErrHijacked
+	}
 	if w.headerWritten {
 		return errors.New("status has been sent already")
 	}
@@ -122,26 +621,56 @@ func (w *response) WriteStatusMsg(status StatusCode, msg string) error {
 		return w.err
 	}
 	w.headerWritten = true
+	w.status = status
+	w.meta = msg
 	return nil
 }
 
 func (w *response) WriteBody(body []byte) (int, error) {
+	if w.hijacked {
+		return 0, ErrHijacked
+	}
 	if !w.headerWritten {
 		return 0, errors.New("status message is not written")
 	}
 	if w.err != nil {
 		return 0, w.err
 	}
+	if w.writeTimeout > 0 {
+		w.conn.SetWriteDeadline(time.Now().Add(w.writeTimeout))
+	}
+	if w.bodyStart.IsZero() {
+		w.bodyStart = time.Now()
+	}
 	var written int
 	written, w.err = w.conn.Write(body)
+	w.written += int64(written)
 	if w.err != nil {
-		w.err = fmt.Errorf("failed to write response body: %v", w.err)
+		w.err = &PartialWriteError{Written: w.written, Err: w.err}
+		return written, w.err
 	}
-	return written, w.err
+	w.throttle()
+	return written, nil
 }
 
-// Write provides raw write and is for internal use only.
-// It provides io.Copy compatible interface.
+// throttle sleeps until the body written so far fits the write rate.
+func (w *response) throttle() {
+	if w.rate <= 0 {
+		return
+	}
+	due := time.Duration(float64(w.written) / float64(w.rate) * float64(time.Second))
+	if wait := due - time.Since(w.bodyStart); wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// Write implements io.Writer by writing the response body.
 func (w *response) Write(body []byte) (int, error) {
 	return w.WriteBody(body)
 }
+
+// Flush implements Flusher. Responses are not buffered, so it only reports
+// an earlier write error.
+func (w *response) Flush() error {
+	return w.err
+}
@@ -0,0 +1,468 @@
+package gemini_test
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/certs"
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer runs srv on a random local port and returns its address.
+func startServer(t *testing.T, srv *gemini.Server) string {
+	t.Helper()
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testcert.Leaf().TLSCertificate()},
+		ClientAuth:   tls.RequestClientCert,
+	})
+	require.NoError(t, err)
+	go srv.Serve(ln)
+	return ln.Addr().String()
+}
+
+// roundTrip sends a request line and returns the response header and body.
+func roundTrip(t *testing.T, addr, request string, certs ...tls.Certificate) (string, string) {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{
+		InsecureSkipVerify: true,
+		Certificates:       certs,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, request+"\r\n")
+	require.NoError(t, err)
+	br := bufio.NewReader(conn)
+	header, err := br.ReadString('\n')
+	if err == io.EOF {
+		return header, ""
+	}
+	require.NoError(t, err)
+	body, err := io.ReadAll(br)
+	require.NoError(t, err)
+	return header, string(body)
+}
+
+func TestServerRecoversPanic(t *testing.T) {
+	var mu sync.Mutex
+	var entries []gemini.AccessLogEntry
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			panic("boom")
+		}),
+		AccessLog: gemini.AccessLogFunc(func(e gemini.AccessLogEntry) {
+			mu.Lock()
+			entries = append(entries, e)
+			mu.Unlock()
+		}),
+		ErrorLog: discardLog,
+	}
+	addr := startServer(t, srv)
+
+	header, _ := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "40 Internal Server Error\r\n", header)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, entries, 1)
+	require.Equal(t, gemini.StatusUnspecified, entries[0].Status)
+	require.Equal(t, "gemini://localhost/", entries[0].URL)
+	_, _, err := net.SplitHostPort(entries[0].RemoteAddr)
+	require.NoError(t, err)
+}
+
+var discardLog = log.New(io.Discard, "", 0)
+
+func TestServerConnState(t *testing.T) {
+	states := make(chan gemini.ConnState, 3)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		}),
+		ConnState: func(_ net.Conn, s gemini.ConnState) { states <- s },
+	}
+	addr := startServer(t, srv)
+	roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, gemini.StateNew, <-states)
+	require.Equal(t, gemini.StateActive, <-states)
+	require.Equal(t, gemini.StateClosed, <-states)
+}
+
+func TestServerLoadShedding(t *testing.T) {
+	ls := &gemini.LoadShedder{MaxGoroutines: 1, RetryAfter: 30 * time.Second}
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			t.Error("handler must not run while shedding")
+		}),
+		LoadShedder: ls,
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "44 30\r\n", header)
+	require.Equal(t, uint64(1), ls.ShedCount())
+}
+
+func TestServerHosts(t *testing.T) {
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		}),
+		Hosts: []string{"example.com", "localhost:1966"},
+	}
+	addr := startServer(t, srv)
+	for req, want := range map[string]string{
+		"gemini://example.com/":       "20 text/gemini\r\n",
+		"gemini://EXAMPLE.com:7/":     "20 text/gemini\r\n",
+		"gemini://localhost:1966/":    "20 text/gemini\r\n",
+		"gemini://localhost/":         "53 Proxy Request Refused\r\n",
+		"gemini://other.example.com/": "53 Proxy Request Refused\r\n",
+	} {
+		header, _ := roundTrip(t, addr, req)
+		require.Equal(t, want, header, req)
+	}
+}
+
+func TestServerVerifyClientCertificate(t *testing.T) {
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		}),
+		VerifyClientCertificate: func(r *gemini.Request, cert *x509.Certificate) error {
+			if cert == nil {
+				return gemini.ErrCertificateRequired
+			}
+			if cert.Subject.CommonName != "client" {
+				return errors.New("Unknown Identity")
+			}
+			return nil
+		},
+	}
+	addr := startServer(t, srv)
+
+	header, _ := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "60 Certificate Required\r\n", header)
+	header, _ = roundTrip(t, addr, "gemini://localhost/", testcert.ExpiredClient().TLSCertificate())
+	require.Equal(t, "61 Unknown Identity\r\n", header)
+	header, _ = roundTrip(t, addr, "gemini://localhost/", testcert.Client().TLSCertificate())
+	require.Equal(t, "20 text/gemini\r\n", header)
+}
+
+func TestServerLoadSheddingPriority(t *testing.T) {
+	ls := &gemini.LoadShedder{
+		MaxGoroutines: 1,
+		Priority:      []string{testcert.Client().Fingerprint()},
+	}
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		}),
+		LoadShedder: ls,
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "gemini://localhost/", testcert.Client().TLSCertificate())
+	require.Equal(t, "20 text/gemini\r\n", header)
+	header, _ = roundTrip(t, addr, "gemini://localhost/", testcert.ExpiredClient().TLSCertificate())
+	require.Equal(t, "44 10\r\n", header)
+}
+
+func TestServerLoadSheddingHandshakeTimeout(t *testing.T) {
+	failures := make(chan *gemini.HandshakeError, 1)
+	srv := &gemini.Server{
+		Handler:          text("unreachable"),
+		LoadShedder:      &gemini.LoadShedder{MaxGoroutines: 1, Priority: []string{testcert.Client().Fingerprint()}},
+		HandshakeTimeout: 50 * time.Millisecond,
+		HandshakeError:   func(_ net.Conn, err *gemini.HandshakeError) { failures <- err },
+	}
+	addr := startServer(t, srv)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	select {
+	case herr := <-failures:
+		require.Equal(t, gemini.HandshakeTimeout, herr.Reason)
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled handshake was not timed out")
+	}
+}
+
+func TestServerHandshakeError(t *testing.T) {
+	failures := make(chan *gemini.HandshakeError, 1)
+	srv := &gemini.Server{
+		Handler:        text("unreachable"),
+		HandshakeError: func(_ net.Conn, err *gemini.HandshakeError) { failures <- err },
+	}
+	addr := startServer(t, srv)
+
+	conn, err := net.Dial("tcp", addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	_, err = io.WriteString(conn, "GET / HTTP/1.0\r\n\r\n")
+	require.NoError(t, err)
+
+	herr := <-failures
+	require.Equal(t, gemini.HandshakeNotTLS, herr.Reason)
+	require.Equal(t, uint64(1), srv.HandshakeFailures()[gemini.HandshakeNotTLS])
+}
+
+func TestRequireCertificate(t *testing.T) {
+	srv := &gemini.Server{
+		Handler: gemini.RequireCertificate(gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+			w.WriteBody([]byte(gemini.ContextCertificate(r.Context()).Subject.CommonName + " " + gemini.ContextFingerprint(r.Context())))
+		})),
+	}
+	addr := startServer(t, srv)
+
+	header, _ := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "60 Certificate Required\r\n", header)
+
+	client := testcert.Client()
+	header, body := roundTrip(t, addr, "gemini://localhost/", client.TLSCertificate())
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.Equal(t, "client "+client.Fingerprint(), body)
+}
+
+func TestServerHijack(t *testing.T) {
+	states := make(chan gemini.ConnState, 4)
+	writeErrs := make(chan error, 1)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			conn, err := gemini.Hijack(w)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			_, err = w.WriteBody([]byte("x"))
+			writeErrs <- err
+			go func() {
+				defer conn.Close()
+				time.Sleep(10 * time.Millisecond)
+				io.WriteString(conn, "20 text/plain\r\nhijacked")
+			}()
+		}),
+		ConnState: func(_ net.Conn, s gemini.ConnState) { states <- s },
+	}
+	addr := startServer(t, srv)
+	header, body := roundTrip(t, addr, "gemini://localhost/")
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.Equal(t, "hijacked", body)
+	require.Equal(t, gemini.ErrHijacked, <-writeErrs)
+	require.Equal(t, gemini.StateNew, <-states)
+	require.Equal(t, gemini.StateActive, <-states)
+	require.Equal(t, gemini.StateHijacked, <-states)
+}
+
+func TestServerMaxUploadSize(t *testing.T) {
+	entries := make(chan gemini.AccessLogEntry, 1)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			t.Error("handler called for oversized upload")
+		}),
+		AccessLog:     gemini.AccessLogFunc(func(e gemini.AccessLogEntry) { entries <- e }),
+		MaxUploadSize: 4,
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "titan://localhost/upload;mime=text/plain;size=1099511627776")
+	require.Equal(t, "59 Upload exceeds 4 bytes\r\n", header)
+	e := <-entries
+	require.Equal(t, gemini.StatusBadRequest, e.Status)
+	require.Equal(t, gemini.ErrUploadTooLarge, e.Err)
+}
+
+func TestServerUploadTypes(t *testing.T) {
+	entries := make(chan gemini.AccessLogEntry, 1)
+	srv := &gemini.Server{
+		Handler:     text("ok"),
+		AccessLog:   gemini.AccessLogFunc(func(e gemini.AccessLogEntry) { entries <- e }),
+		UploadTypes: []string{"text/gemini", "image/*"},
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "titan://localhost/upload;mime=application/zip;size=3\r\nzip")
+	require.Equal(t, "59 Upload type application/zip not allowed\r\n", header)
+	e := <-entries
+	require.Equal(t, gemini.ErrUploadTypeNotAllowed, e.Err)
+
+	header, _ = roundTrip(t, addr, "titan://localhost/upload;mime=image/png;size=3\r\npng")
+	require.Equal(t, "20 text/gemini\r\n", header)
+	<-entries
+	header, _ = roundTrip(t, addr, "titan://localhost/upload;size=2\r\n# ")
+	require.Equal(t, "20 text/gemini\r\n", header)
+}
+
+func TestServerTitanHandler(t *testing.T) {
+	addr := startServer(t, &gemini.Server{Handler: readWrite{}})
+	_, body := roundTrip(t, addr, "gemini://localhost/page")
+	require.Equal(t, "read /page", body)
+	_, body = roundTrip(t, addr, "titan://localhost/page;size=2\r\nhi")
+	require.Equal(t, "write /page", body)
+}
+
+func TestServerUploadProgress(t *testing.T) {
+	progress := make(chan int64, 10)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			if _, err := r.ReadTitanPayload(); err != nil {
+				w.WriteStatusMsg(gemini.StatusBadRequest, err.Error())
+				return
+			}
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		}),
+		UploadProgress: func(r *gemini.Request, n, total int64) error {
+			progress <- n
+			if total > 5 {
+				return errors.New("upload too slow")
+			}
+			return nil
+		},
+	}
+	addr := startServer(t, srv)
+	header, _ := roundTrip(t, addr, "titan://localhost/a;size=5;sha256=2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824\r\nhello")
+	require.Equal(t, "20 text/gemini\r\n", header)
+	var n int64
+	for n < 5 {
+		n = <-progress
+	}
+	header, _ = roundTrip(t, addr, "titan://localhost/a;size=6\r\nhello!")
+	require.Equal(t, "59 upload too slow\r\n", header)
+}
+
+func TestServerTitanRemaining(t *testing.T) {
+	errs := make(chan error, 2)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			head := make([]byte, 2)
+			_, err := io.ReadFull(r.Titan.Body, head)
+			errs <- err
+			before := r.Titan.Remaining()
+			n, err := r.Titan.Discard()
+			errs <- err
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+			w.WriteBody([]byte(fmt.Sprintf("%s %d %d %d", head, before, n, r.Titan.Remaining())))
+		}),
+	}
+	addr := startServer(t, srv)
+	_, body := roundTrip(t, addr, "titan://localhost/a;size=5\r\nhello")
+	require.Equal(t, "he 3 3 0", body)
+	require.NoError(t, <-errs)
+	require.NoError(t, <-errs)
+}
+
+func TestServerAutoCert(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls", "cert.pem"), filepath.Join(dir, "tls", "key.pem")
+	srv := &gemini.Server{
+		Addr:     "127.0.0.1:0",
+		Handler:  text("ok"),
+		Hosts:    []string{"example.org:1965", "localhost"},
+		AutoCert: true,
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+	done := make(chan error, 1)
+	go func() { done <- srv.ListenAndServeTLS(certFile, keyFile) }()
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(certFile)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, srv.Close())
+	require.Equal(t, gemini.ErrServerClosed, <-done)
+
+	cert, err := certs.Load(certFile, keyFile)
+	require.NoError(t, err)
+	require.Equal(t, []string{"example.org", "localhost"}, cert.Leaf.DNSNames)
+
+	// Existing files are kept, a lone certificate is an error.
+	srv = &gemini.Server{Addr: "127.0.0.1:0", AutoCert: true}
+	require.NoError(t, os.Remove(keyFile))
+	require.Error(t, srv.ListenAndServeTLS(certFile, keyFile))
+}
+
+func TestServerEscapedRequest(t *testing.T) {
+	addr := startServer(t, &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+			w.WriteBody([]byte(fmt.Sprintf("%s|%s|%s|%s", r.URL.Path, r.URL.RawQuery, r.Titan.Token, r.Titan.Params["note"])))
+		}),
+	})
+	_, body := roundTrip(t, addr, "titan://localhost/a%3Bb;token=a%3Bb%3Dc;note=x%3Dy%3Bz;size=0")
+	require.Equal(t, "/a;b||a;b=c|x=y;z", body)
+	_, body = roundTrip(t, addr, "gemini://localhost/a%3Fb?x%26y")
+	require.Equal(t, "/a?b|x%26y||", body)
+}
+
+func TestServerQuery(t *testing.T) {
+	addr := startServer(t, &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			q, err := r.Query()
+			if err != nil {
+				w.WriteStatusMsg(gemini.StatusBadRequest, err.Error())
+				return
+			}
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+			w.WriteBody([]byte(q))
+		}),
+	})
+	_, body := roundTrip(t, addr, "gemini://localhost/?c%2B%2B%20x")
+	require.Equal(t, "c++ x", body)
+	_, body = roundTrip(t, addr, "gemini://localhost/?c%2B%2B+x")
+	require.Equal(t, "c+++x", body)
+	_, body = roundTrip(t, addr, "gemini://localhost/?100%25%20sure")
+	require.Equal(t, "100% sure", body)
+}
+
+func TestServerTitanChecksum(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" // sha256("hello")
+	errs := make(chan error, 1)
+	srv := &gemini.Server{
+		Handler: gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+			payload, err := r.ReadTitanPayload()
+			errs <- err
+			if err != nil {
+				gemini.Error(w, gemini.StatusBadRequest, "Checksum mismatch")
+				return
+			}
+			w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+			w.WriteBody(payload)
+		}),
+	}
+	addr := startServer(t, srv)
+
+	header, body := roundTrip(t, addr, "titan://localhost/up;size=5;sha256="+sum+"\r\nhello")
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.Equal(t, "hello", body)
+	require.NoError(t, <-errs)
+
+	header, _ = roundTrip(t, addr, "titan://localhost/up;size=5;sha256="+sum+"\r\nhellO")
+	require.Equal(t, "59 Checksum mismatch\r\n", header)
+	require.ErrorIs(t, <-errs, gemini.ErrChecksumMismatch)
+
+	header, _ = roundTrip(t, addr, "titan://localhost/up;size=0;sha256="+sum)
+	require.Equal(t, "59 Checksum mismatch\r\n", header)
+	require.ErrorIs(t, <-errs, gemini.ErrChecksumMismatch)
+	const empty = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855" // sha256("")
+	header, _ = roundTrip(t, addr, "titan://localhost/up;size=0;sha256="+empty)
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.NoError(t, <-errs)
+}
+
+func TestRequireCertificateTitan(t *testing.T) {
+	mux := gemini.NewServeMux()
+	mux.Handle("/", gemini.RequireCertificate(readWrite{}))
+	addr := startServer(t, &gemini.Server{Handler: mux})
+	header, _ := roundTrip(t, addr, "titan://localhost/a;size=2\r\nhi")
+	require.Equal(t, "60 Certificate Required\r\n", header)
+	_, body := roundTrip(t, addr, "titan://localhost/a;size=2\r\nhi", testcert.Client().TLSCertificate())
+	require.Equal(t, "write /a", body)
+}
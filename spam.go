@@ -0,0 +1,275 @@
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// Submission is user input offered to a SpamScorer: the query of a
+// request answering a 10 INPUT prompt or the body of a textual Titan
+// upload.
+type Submission struct {
+	Request *Request
+	Text    string
+}
+
+// SpamScorer rates submissions. Scores are summed across the scorers of
+// a SpamFilter; higher scores are more likely spam.
+type SpamScorer interface {
+	Score(s *Submission) (float64, error)
+}
+
+// SpamScorerFunc adapts a function to a SpamScorer.
+type SpamScorerFunc func(s *Submission) (float64, error)
+
+// Score calls f(s).
+func (f SpamScorerFunc) Score(s *Submission) (float64, error) {
+	return f(s)
+}
+
+// SpamFilter is middleware scoring submissions with its Scorers before
+// they reach the handler:
+//
+//	filter := &gemini.SpamFilter{
+//		Scorers: []gemini.SpamScorer{
+//			&gemini.SpamRate{Rate: 1.0 / 60, Burst: 3, Penalty: 5},
+//			&gemini.SpamHeuristics{MaxLinks: 2, Words: []string{"casino"}},
+//		},
+//		Challenge: 3,
+//		Reject:    5,
+//	}
+//	mux.Handle("/guestbook", filter.Middleware(guestbook))
+//
+// Submissions scoring below Challenge are accepted, those scoring at
+// least Reject are answered with 50 and those in between are passed to
+// OnChallenge. Requests without input and Titan uploads of other than
+// text types are passed on unscored. Scorers failing with an error count
+// as zero, so an unreachable service does not block submissions.
+type SpamFilter struct {
+	Scorers []SpamScorer
+
+	// Challenge and Reject are the score thresholds. Zero thresholds are
+	// disabled.
+	Challenge float64
+	Reject    float64
+
+	// OnChallenge serves challenged submissions. Defaults to rejecting
+	// them.
+	OnChallenge Handler
+
+	// MaxTitanSize is the largest Titan upload scored. Larger textual
+	// uploads are rejected with 50. Defaults to 64 KiB.
+	MaxTitanSize int64
+}
+
+// Middleware returns next wrapped in the filter.
+func (f *SpamFilter) Middleware(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			if r.URL.Scheme == SchemaTitan && r.Titan.Size > f.maxTitanSize() && isTextType(r.Titan.Mime) {
+				w.WriteStatusMsg(StatusGeneralPermFail, messagef(r, "Upload exceeds %d bytes", f.maxTitanSize()))
+				return
+			}
+			s, err := f.submission(r)
+			if err != nil {
+				w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+				return
+			}
+			if s == nil {
+				next.ServeGemini(w, r)
+				return
+			}
+			var score float64
+			for _, sc := range f.Scorers {
+				if v, err := sc.Score(s); err == nil {
+					score += v
+				}
+			}
+			switch {
+			case f.Reject > 0 && score >= f.Reject:
+				w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Submission rejected"))
+			case f.Challenge > 0 && score >= f.Challenge:
+				if f.OnChallenge == nil {
+					w.WriteStatusMsg(StatusGeneralPermFail, Message(r, "Submission rejected"))
+					return
+				}
+				f.OnChallenge.ServeGemini(w, r)
+			default:
+				next.ServeGemini(w, r)
+			}
+		}
+	})
+}
+
+// submission returns the input of r, or nil if it has none to score.
+// Textual Titan bodies are read and replaced so next can read them again.
+func (f *SpamFilter) submission(r *Request) (*Submission, error) {
+	if r.URL.Scheme != SchemaTitan {
+		if r.URL.RawQuery == "" {
+			return nil, nil
+		}
+		text, err := r.Query()
+		if err != nil {
+			return nil, err
+		}
+		return &Submission{Request: r, Text: text}, nil
+	}
+	if r.Titan.Body == nil || r.Titan.Size <= 0 || !isTextType(r.Titan.Mime) {
+		return nil, nil
+	}
+	body, err := r.ReadTitanPayload()
+	if err != nil {
+		return nil, err
+	}
+	r.Titan.Body = readCloser{bytes.NewReader(body), r.Titan.Body}
+	return &Submission{Request: r, Text: string(body)}, nil
+}
+
+func (f *SpamFilter) maxTitanSize() int64 {
+	if f.MaxTitanSize <= 0 {
+		return 64 << 10
+	}
+	return f.MaxTitanSize
+}
+
+// isTextType reports whether a Titan mime parameter, which defaults to
+// text/gemini, names a text type.
+func isTextType(mimeType string) bool {
+	if mimeType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(mimeType)
+	return err == nil && strings.HasPrefix(mt, "text/")
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// SpamRate scores clients submitting more often than Rate per second
+// with Penalty. It shares RateLimit's token buckets.
+type SpamRate struct {
+	Rate  float64
+	Burst int
+
+	// Penalty is the score of submissions exceeding the rate.
+	Penalty float64
+
+	// Key identifies the client of a request. Defaults to
+	// KeyByCertificate.
+	Key func(*Request) string
+
+	// Store keeps the buckets. Defaults to a MemoryRateLimitStore.
+	Store RateLimitStore
+
+	once sync.Once
+}
+
+// Score implements SpamScorer.
+func (sr *SpamRate) Score(s *Submission) (float64, error) {
+	sr.once.Do(func() {
+		if sr.Store == nil {
+			sr.Store = NewMemoryRateLimitStore()
+		}
+		if sr.Key == nil {
+			sr.Key = KeyByCertificate
+		}
+		if sr.Burst <= 0 {
+			sr.Burst = 1
+		}
+	})
+	if ok, _ := sr.Store.Take("spam:"+sr.Key(s.Request), sr.Rate, sr.Burst); ok {
+		return 0, nil
+	}
+	return sr.Penalty, nil
+}
+
+// SpamHeuristics scores submissions on their content: one point for each
+// link beyond MaxLinks, for each occurrence of a blocked word and for
+// texts in capital letters only.
+type SpamHeuristics struct {
+	// MaxLinks is the number of URLs a submission may contain. Negative
+	// values allow any number.
+	MaxLinks int
+
+	// Words are blocked words or phrases, matched case-insensitively.
+	Words []string
+}
+
+// Score implements SpamScorer.
+func (h *SpamHeuristics) Score(s *Submission) (float64, error) {
+	var score float64
+	if h.MaxLinks >= 0 {
+		if n := strings.Count(s.Text, "://"); n > h.MaxLinks {
+			score += float64(n - h.MaxLinks)
+		}
+	}
+	lower := strings.ToLower(s.Text)
+	for _, w := range h.Words {
+		if w != "" {
+			score += float64(strings.Count(lower, strings.ToLower(w)))
+		}
+	}
+	if len(s.Text) >= 16 && s.Text == strings.ToUpper(s.Text) && s.Text != lower {
+		score++
+	}
+	return score, nil
+}
+
+// SpamService scores submissions with an external HTTP service. The
+// service is sent a POST request with a JSON object holding the "text",
+// "ip" and "fingerprint" of the submission and answers with an object
+// holding its "score".
+type SpamService struct {
+	URL string
+
+	// Client makes the requests. Defaults to http.DefaultClient; set a
+	// client with a timeout to bound the delay of slow services.
+	Client *http.Client
+}
+
+// Score implements SpamScorer.
+func (ss *SpamService) Score(s *Submission) (float64, error) {
+	in := struct {
+		Text        string `json:"text"`
+		IP          string `json:"ip,omitempty"`
+		Fingerprint string `json:"fingerprint,omitempty"`
+	}{Text: s.Text}
+	if ip := RemoteIP(s.Request); ip != nil {
+		in.IP = ip.String()
+	}
+	if cert := s.Request.Certificate(); cert != nil {
+		in.Fingerprint = Fingerprint(cert)
+	}
+	body, err := json.Marshal(in)
+	if err != nil {
+		return 0, err
+	}
+	client := ss.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(ss.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return 0, fmt.Errorf("gemini: spam service: %s", resp.Status)
+	}
+	var out struct {
+		Score float64 `json:"score"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return 0, fmt.Errorf("gemini: spam service: %w", err)
+	}
+	return out.Score, nil
+}
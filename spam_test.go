@@ -0,0 +1,106 @@
+package gemini_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSpamFilter(t *testing.T) {
+	challenge := gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		w.WriteStatusMsg(gemini.StatusPlainInput, "Are you human?")
+	})
+	filter := &gemini.SpamFilter{
+		Scorers: []gemini.SpamScorer{
+			&gemini.SpamHeuristics{MaxLinks: 1, Words: []string{"casino"}},
+		},
+		Challenge:   1,
+		Reject:      2,
+		OnChallenge: challenge,
+	}
+	h := filter.Middleware(text("ok"))
+
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/").status)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/?hello%20gemini%3A%2F%2Fexample.org").status)
+	require.Equal(t, gemini.StatusPlainInput, serve(t, h, "gemini://localhost/?Visit%20our%20Casino").status)
+	w := serve(t, h, "gemini://localhost/?casino%20CASINO")
+	require.Equal(t, gemini.StatusGeneralPermFail, w.status)
+	require.Equal(t, "Submission rejected", w.meta)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/?ok").status)
+
+	filter.OnChallenge = nil
+	require.Equal(t, gemini.StatusGeneralPermFail, serve(t, h, "gemini://localhost/?casino").status)
+}
+
+func TestSpamFilterTitan(t *testing.T) {
+	filter := &gemini.SpamFilter{
+		Scorers: []gemini.SpamScorer{
+			&gemini.SpamHeuristics{Words: []string{"spam"}},
+		},
+		Reject:       1,
+		MaxTitanSize: 16,
+	}
+	h := filter.Middleware(gemini.HandlerFunc(func(w gemini.ResponseWriter, r *gemini.Request) {
+		body, err := r.ReadTitanPayload()
+		require.NoError(t, err)
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+		w.WriteBody(body)
+	}))
+	addr := startServer(t, &gemini.Server{Handler: h})
+	upload := func(params, body string) (string, string) {
+		t.Helper()
+		return roundTrip(t, addr, "titan://localhost/f;size="+strconv.Itoa(len(body))+params+"\r\n"+body)
+	}
+
+	header, body := upload("", "hello")
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.Equal(t, "hello", body)
+	header, _ = upload(";mime=text/plain", "more spam")
+	require.Equal(t, "50 Submission rejected\r\n", header)
+	header, body = upload(";mime=application/octet-stream", "spam")
+	require.Equal(t, "20 text/plain\r\n", header)
+	require.Equal(t, "spam", body)
+	header, _ = upload("", "seventeen bytes!!")
+	require.Equal(t, "50 Upload exceeds 16 bytes\r\n", header)
+}
+
+func TestSpamRate(t *testing.T) {
+	filter := &gemini.SpamFilter{
+		Scorers: []gemini.SpamScorer{&gemini.SpamRate{Rate: 0.001, Burst: 2, Penalty: 5}},
+		Reject:  5,
+	}
+	h := filter.Middleware(text("ok"))
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/?a").status)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/?b").status)
+	require.Equal(t, gemini.StatusGeneralPermFail, serve(t, h, "gemini://localhost/?c").status)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/").status)
+}
+
+func TestSpamService(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct{ Text string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&in))
+		if in.Text == "fail" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]float64{"score": float64(len(in.Text))})
+	}))
+	defer srv.Close()
+
+	ss := &gemini.SpamService{URL: srv.URL}
+	score, err := ss.Score(&gemini.Submission{Request: &gemini.Request{}, Text: "four"})
+	require.NoError(t, err)
+	require.Equal(t, 4.0, score)
+	_, err = ss.Score(&gemini.Submission{Request: &gemini.Request{}, Text: "fail"})
+	require.Error(t, err)
+
+	h := (&gemini.SpamFilter{Scorers: []gemini.SpamScorer{ss}, Reject: 4}).Middleware(text("ok"))
+	require.Equal(t, gemini.StatusGeneralPermFail, serve(t, h, "gemini://localhost/?four").status)
+	require.Equal(t, gemini.StatusSuccess, serve(t, h, "gemini://localhost/?fail").status)
+}
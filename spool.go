@@ -0,0 +1,73 @@
+package gemini
+
+import (
+	"bytes"
+	"io"
+	"os"
+)
+
+// SpooledPayload is a titan payload read by SpoolTitanPayload, held in
+// memory or in a temporary file. Close releases it, removing the file.
+type SpooledPayload struct {
+	io.ReadSeeker
+	size int64
+	file *os.File
+}
+
+// Size returns the size of the payload in bytes.
+func (p *SpooledPayload) Size() int64 {
+	return p.size
+}
+
+// OnDisk reports whether the payload is held in a temporary file.
+func (p *SpooledPayload) OnDisk() bool {
+	return p.file != nil
+}
+
+// Close releases the payload.
+func (p *SpooledPayload) Close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.file.Close()
+	if rerr := os.Remove(p.file.Name()); err == nil {
+		err = rerr
+	}
+	p.file = nil
+	return err
+}
+
+// SpoolTitanPayload reads the titan payload like ReadTitanPayload, for
+// handlers that need random access to it. Payloads of up to threshold
+// bytes are held in memory; larger ones are written to a temporary file
+// in the default directory for temporary files, so big uploads do not
+// have to fit in memory. The caller must close the returned payload.
+func (r *Request) SpoolTitanPayload(threshold int64) (*SpooledPayload, error) {
+	if r.Titan.Size <= threshold {
+		buf, err := r.ReadTitanPayload()
+		if err != nil {
+			return nil, err
+		}
+		return &SpooledPayload{ReadSeeker: bytes.NewReader(buf), size: int64(len(buf))}, nil
+	}
+	f, err := os.CreateTemp("", "gemini-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	p := &SpooledPayload{ReadSeeker: f, file: f}
+	p.size, err = io.Copy(f, r.Titan.Reader())
+	if err == nil && p.size < r.Titan.Size {
+		err = io.ErrUnexpectedEOF
+	}
+	if c, ok := r.Titan.Body.(*checksumReader); ok && err == nil {
+		err = c.err
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	return p, nil
+}
@@ -0,0 +1,109 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Supervisor runs several Servers together, for example a public capsule
+// and an admin listener sharing one handler tree. It starts them at once,
+// shuts all of them down when any fails or the Run context is canceled,
+// and reports every failure.
+type Supervisor struct {
+	// ShutdownTimeout bounds graceful shutdown of each server before its
+	// remaining connections are closed. Defaults to 5 seconds.
+	ShutdownTimeout time.Duration
+
+	units []unit
+}
+
+type unit struct {
+	srv   *Server
+	start func() error
+}
+
+// Add registers srv with a function starting it, typically a closure
+// calling one of its Serve methods.
+func (s *Supervisor) Add(srv *Server, start func() error) {
+	s.units = append(s.units, unit{srv: srv, start: start})
+}
+
+// AddTLS registers srv to run with ListenAndServeTLS.
+func (s *Supervisor) AddTLS(srv *Server, certFile, keyFile string) {
+	s.Add(srv, func() error { return srv.ListenAndServeTLS(certFile, keyFile) })
+}
+
+// AddListener registers srv to run with Serve on ln.
+func (s *Supervisor) AddListener(srv *Server, ln net.Listener) {
+	s.Add(srv, func() error { return srv.Serve(ln) })
+}
+
+// Run starts all servers and blocks until they have stopped. Servers are
+// shut down when ctx is done or any of them stops with an error.
+// Run returns nil after a clean shutdown, otherwise ServeErrors.
+func (s *Supervisor) Run(ctx context.Context) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs ServeErrors
+	)
+	record := func(err error) {
+		if err == nil || errors.Is(err, ErrServerClosed) {
+			return
+		}
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+	for _, u := range s.units {
+		wg.Add(1)
+		go func(u unit) {
+			defer wg.Done()
+			err := u.start()
+			record(err)
+			cancel()
+		}(u)
+	}
+
+	<-ctx.Done()
+	timeout := s.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	for _, u := range s.units {
+		wg.Add(1)
+		go func(srv *Server) {
+			defer wg.Done()
+			sctx, scancel := context.WithTimeout(context.Background(), timeout)
+			defer scancel()
+			if err := srv.Shutdown(sctx); err != nil {
+				record(err)
+				record(srv.Close())
+			}
+		}(u.srv)
+	}
+	wg.Wait()
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// ServeErrors aggregates errors of supervised servers.
+type ServeErrors []error
+
+func (e ServeErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
@@ -0,0 +1,67 @@
+package gemini_test
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisor(t *testing.T) {
+	handler := text("shared")
+	public, admin := &gemini.Server{Handler: handler}, &gemini.Server{Handler: handler}
+
+	var sup gemini.Supervisor
+	addrs := make([]string, 0, 2)
+	for _, srv := range []*gemini.Server{public, admin} {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{testcert.Leaf().TLSCertificate()},
+		})
+		require.NoError(t, err)
+		addrs = append(addrs, ln.Addr().String())
+		sup.AddListener(srv, ln)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- sup.Run(ctx) }()
+
+	for _, addr := range addrs {
+		_, body := roundTrip(t, addr, "gemini://localhost/")
+		require.Equal(t, "shared", body)
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("supervisor did not stop")
+	}
+}
+
+func TestSupervisorFailure(t *testing.T) {
+	var sup gemini.Supervisor
+	boom := errors.New("boom")
+	sup.Add(&gemini.Server{}, func() error { return boom })
+	ok := &gemini.Server{Handler: text("ok")}
+	sup.Add(ok, func() error {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+			Certificates: []tls.Certificate{testcert.Leaf().TLSCertificate()},
+		})
+		if err != nil {
+			return err
+		}
+		return ok.Serve(ln)
+	})
+
+	err := sup.Run(context.Background())
+	var errs gemini.ServeErrors
+	require.True(t, errors.As(err, &errs))
+	require.Equal(t, gemini.ServeErrors{boom}, errs)
+}
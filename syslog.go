@@ -0,0 +1,144 @@
+package gemini
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog is an io.Writer sending every write as an RFC 5424 syslog
+// message, for use as the output of Server.ErrorLog or of an access log:
+//
+//	sl, err := gemini.DialSyslog("udp", "logs.example.org:514", "capsule")
+//	if err != nil {
+//		log.Fatal(err)
+//	}
+//	srv.ErrorLog = log.New(sl, "", 0)
+//	srv.AccessLog = gemini.NewJSONLog(sl)
+//
+// Stream connections use octet counting framing (RFC 6587). A failed
+// write reconnects once before it is reported. It is safe for concurrent
+// use.
+type Syslog struct {
+	// Facility is the syslog facility. DialSyslog sets it to 3, daemon.
+	Facility int
+
+	// Severity is the syslog severity. DialSyslog sets it to 6,
+	// informational.
+	Severity int
+
+	// Hostname and AppName identify the sender. DialSyslog sets them to
+	// the host name and to appName or the program name.
+	Hostname string
+	AppName  string
+
+	network, addr string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// DialSyslog connects to the syslog daemon at addr on network. An empty
+// network connects to the local daemon through /dev/log or
+// /var/run/syslog.
+func DialSyslog(network, addr, appName string) (*Syslog, error) {
+	if appName == "" {
+		appName = filepath.Base(os.Args[0])
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	s := &Syslog{Facility: 3, Severity: 6, Hostname: hostname, AppName: appName, network: network, addr: addr}
+	if err := s.connect(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Syslog) connect() error {
+	if s.network != "" {
+		conn, err := net.Dial(s.network, s.addr)
+		if err != nil {
+			return err
+		}
+		s.conn = conn
+		return nil
+	}
+	var err error
+	for _, path := range []string{"/dev/log", "/var/run/syslog"} {
+		for _, network := range []string{"unixgram", "unix"} {
+			var conn net.Conn
+			if conn, err = net.Dial(network, path); err == nil {
+				s.conn, s.network = conn, network
+				return nil
+			}
+		}
+	}
+	return err
+}
+
+// Write sends p, without a trailing line break, as one message.
+func (s *Syslog) Write(p []byte) (int, error) {
+	msg := s.format(strings.TrimRight(string(p), "\n"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		if err := s.connect(); err != nil {
+			return 0, err
+		}
+	}
+	if _, err := s.conn.Write(msg); err != nil {
+		s.conn.Close()
+		s.conn = nil
+		if err := s.connect(); err != nil {
+			return 0, err
+		}
+		if _, err := s.conn.Write(msg); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// Close closes the connection.
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
+
+func (s *Syslog) format(text string) []byte {
+	msg := fmt.Sprintf("<%d>1 %s %s %s %d - - %s",
+		s.Facility*8+s.Severity, time.Now().Format("2006-01-02T15:04:05.000000Z07:00"),
+		syslogField(s.Hostname), syslogField(s.AppName), os.Getpid(), text)
+	switch s.network {
+	case "tcp", "tcp4", "tcp6", "unix":
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	return []byte(msg)
+}
+
+// syslogField returns s as a header field: printable ASCII without
+// spaces, or "-" if empty.
+func syslogField(s string) string {
+	s = strings.Map(func(r rune) rune {
+		if r <= ' ' || r > '~' {
+			return -1
+		}
+		return r
+	}, s)
+	if s == "" {
+		return "-"
+	}
+	return s
+}
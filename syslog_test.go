@@ -0,0 +1,105 @@
+package gemini_test
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	sl, err := gemini.DialSyslog("udp", pc.LocalAddr().String(), "my app")
+	require.NoError(t, err)
+	defer sl.Close()
+	sl.Hostname = "host"
+	_, err = sl.Write([]byte("hello world\n"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(`^<30>1 \d{4}-\d\d-\d\dT\d\d:\d\d:\d\d\.\d{6}\S+ host myapp \d+ - - hello world$`), string(buf[:n]))
+}
+
+func TestSyslogTCPFraming(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		br := bufio.NewReader(conn)
+		n, _ := br.ReadString(' ')
+		size, err := strconv.Atoi(strings.TrimSpace(n))
+		if err != nil {
+			return
+		}
+		msg := make([]byte, size)
+		io.ReadFull(br, msg)
+		got <- string(msg)
+	}()
+
+	sl, err := gemini.DialSyslog("tcp", ln.Addr().String(), "app")
+	require.NoError(t, err)
+	defer sl.Close()
+	gemini.NewCombinedLog(sl).LogAccess(gemini.AccessLogEntry{URL: "gemini://example.org/", Status: gemini.StatusSuccess})
+
+	select {
+	case msg := <-got:
+		require.True(t, strings.HasPrefix(msg, "<30>1 "), msg)
+		require.True(t, strings.HasSuffix(msg, `"gemini://example.org/" 20 0 "" "-"`), msg)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message")
+	}
+}
+
+func TestJournal(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	pc, err := net.ListenPacket("unixgram", socket)
+	require.NoError(t, err)
+	defer pc.Close()
+
+	j := &gemini.Journal{Identifier: "capsule", Socket: socket}
+	defer j.Close()
+	j.LogAccess(gemini.AccessLogEntry{
+		RemoteAddr: "192.0.2.1:5000",
+		URL:        "gemini://example.org/",
+		Status:     gemini.StatusNotFound,
+		Meta:       "Not\nFound",
+		Duration:   2 * time.Millisecond,
+	})
+
+	buf := make([]byte, 4096)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	require.NoError(t, err)
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len("Not\nFound")))
+	require.Equal(t, "MESSAGE=51 gemini://example.org/\n"+
+		"PRIORITY=4\n"+
+		"GEMINI_URL=gemini://example.org/\n"+
+		"GEMINI_STATUS=51\n"+
+		"GEMINI_META\n"+string(size[:])+"Not\nFound\n"+
+		"GEMINI_BYTES=0\n"+
+		"GEMINI_DURATION_MS=2\n"+
+		"REMOTE_ADDR=192.0.2.1:5000\n"+
+		"SYSLOG_IDENTIFIER=capsule\n", string(buf[:n]))
+}
@@ -0,0 +1,204 @@
+package gemini
+
+import (
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kulak/gemini/gemtext"
+)
+
+// TagIndex serves tag index pages built from the front matter tags of
+// the .gmi, .gemini and .md files of a content tree. Create it with
+// NewTagIndex and mount it under the desired prefix:
+//
+//	mux.Mount("/tags/", gemini.NewTagIndex(os.DirFS("/var/gemini")))
+//
+// The root lists all tags. Each tag page lists the tagged documents
+// newest first as date prefixed link lines, so clients can subscribe to
+// it as a Gemini feed. Draft documents and documents dated in the future
+// are left out.
+//
+// The tree is checked for changes on every request; only files whose
+// modification time changed are parsed again.
+type TagIndex struct {
+	// ContentPrefix is prepended to document paths in links.
+	// Defaults to "/".
+	ContentPrefix string
+
+	// Now returns the current time for scheduled publishing. Defaults to
+	// time.Now.
+	Now func() time.Time
+
+	// Preview lists the fingerprints (see Fingerprint) of client
+	// certificates that also see drafts and future-dated documents.
+	Preview []string
+
+	content contentIndex
+}
+
+// NewTagIndex returns a TagIndex for the content tree fsys.
+func NewTagIndex(fsys fs.FS) *TagIndex {
+	return &TagIndex{content: contentIndex{fsys: fsys}}
+}
+
+// ServeGemini serves the tag list or the page of the tag named by the
+// request path.
+func (t *TagIndex) ServeGemini(w ResponseWriter, r *Request) {
+	files, err := t.content.refresh(t.Now, hasFingerprint(r, t.Preview))
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	tag := strings.Trim(r.URL.Path, "/")
+	byTag := map[string][]string{}
+	for name, f := range files {
+		for _, tg := range f.meta.Tags {
+			byTag[tg] = append(byTag[tg], name)
+		}
+	}
+
+	var b strings.Builder
+	gw := gemtext.NewWriter(&b)
+	if tag == "" {
+		tags := make([]string, 0, len(byTag))
+		for tg := range byTag {
+			tags = append(tags, tg)
+		}
+		sort.Strings(tags)
+		gw.Heading(1, Message(r, "Tags"))
+		gw.Text("")
+		for _, tg := range tags {
+			gw.Link((&url.URL{Path: tg}).EscapedPath(), tg+" ("+strconv.Itoa(len(byTag[tg]))+")")
+		}
+	} else {
+		names, ok := byTag[tag]
+		if !ok {
+			NotFound(w, r)
+			return
+		}
+		gw.Heading(1, messagef(r, "Tag: %s", tag))
+		gw.Text("")
+		writeFeed(gw, t.ContentPrefix, files, names)
+	}
+	w.WriteStatusMsg(StatusSuccess, "text/gemini")
+	w.WriteBody([]byte(b.String()))
+}
+
+// writeFeed writes the named files newest first as date prefixed link
+// lines.
+func writeFeed(gw *gemtext.Writer, prefix string, files map[string]indexedFile, names []string) {
+	sort.Slice(names, func(i, j int) bool {
+		di, dj := files[names[i]].meta.Date, files[names[j]].meta.Date
+		if !di.Equal(dj) {
+			return di.After(dj)
+		}
+		return names[i] < names[j]
+	})
+	if prefix == "" {
+		prefix = "/"
+	}
+	for _, name := range names {
+		meta := files[name].meta
+		title := meta.Title
+		if title == "" {
+			title = path.Base(name)
+		}
+		if !meta.Date.IsZero() {
+			title = meta.Date.Format("2006-01-02") + " " + title
+		}
+		gw.Link(strings.TrimSuffix(prefix, "/")+(&url.URL{Path: "/" + name}).EscapedPath(), title)
+	}
+}
+
+// contentIndex caches the front matter of the .gmi, .gemini and .md files
+// of a content tree.
+type contentIndex struct {
+	fsys  fs.FS
+	mu    sync.Mutex
+	files map[string]indexedFile
+}
+
+type indexedFile struct {
+	modTime time.Time
+	meta    gemtext.Metadata
+}
+
+// refresh updates the metadata of changed files and returns a snapshot of
+// the published ones, or of all of them if preview is set.
+func (t *contentIndex) refresh(nowFunc func() time.Time, preview bool) (map[string]indexedFile, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.files == nil {
+		t.files = make(map[string]indexedFile)
+	}
+	seen := make(map[string]bool)
+	err := fs.WalkDir(t.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if name != "." && strings.HasPrefix(d.Name(), ".") {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		switch strings.ToLower(path.Ext(name)) {
+		case ".gmi", ".gemini", ".md":
+		default:
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		seen[name] = true
+		if f, ok := t.files[name]; ok && f.modTime.Equal(info.ModTime()) {
+			return nil
+		}
+		meta, err := readMetadata(t.fsys, name)
+		if err != nil {
+			return nil
+		}
+		t.files[name] = indexedFile{modTime: info.ModTime(), meta: meta}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now
+	if nowFunc != nil {
+		now = nowFunc
+	}
+	snapshot := make(map[string]indexedFile, len(seen))
+	for name, f := range t.files {
+		if !seen[name] {
+			delete(t.files, name)
+			continue
+		}
+		if preview || !f.meta.Draft && !f.meta.Date.After(now()) {
+			snapshot[name] = f
+		}
+	}
+	return snapshot, nil
+}
+
+func readMetadata(fsys fs.FS, name string) (gemtext.Metadata, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return gemtext.Metadata{}, err
+	}
+	defer f.Close()
+	src, err := io.ReadAll(f)
+	if err != nil {
+		return gemtext.Metadata{}, err
+	}
+	meta, _, err := gemtext.SplitFrontMatter(src)
+	return meta, err
+}
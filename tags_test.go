@@ -0,0 +1,42 @@
+package gemini_test
+
+import (
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagIndex(t *testing.T) {
+	fsys := fstest.MapFS{
+		"blog/one.gmi":   {Data: []byte("---\ntitle: One\ndate: 2024-01-01\ntags: [go, life]\n---\n")},
+		"blog/two.md":    {Data: []byte("+++\ntitle = \"Two\"\ndate = 2024-02-01\ntags = [\"go\"]\n+++\n")},
+		"blog/draft.gmi": {Data: []byte("---\ntags: [go]\ndraft: true\n---\n")},
+		"about.txt":      {Data: []byte("---\ntags: [go]\n---\n")},
+		"blog/future.md": {Data: []byte("---\ntags: [go]\ndate: 2030-01-01\n---\n")},
+	}
+	idx := gemini.NewTagIndex(fsys)
+	idx.Now = func() time.Time { return time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC) }
+
+	w := serve(t, idx, "gemini://localhost/")
+	require.Equal(t, "# Tags\n\n=> go go (2)\n=> life life (1)\n", w.body.String())
+
+	w = serve(t, idx, "gemini://localhost/go")
+	require.Equal(t, "# Tag: go\n\n"+
+		"=> /blog/two.md 2024-02-01 Two\n"+
+		"=> /blog/one.gmi 2024-01-01 One\n", w.body.String())
+
+	require.Equal(t, gemini.StatusNotFound, serve(t, idx, "gemini://localhost/rust").status)
+
+	fsys["blog/one.gmi"] = &fstest.MapFile{
+		Data:    []byte("---\ntitle: One\ntags: [rust]\n---\n"),
+		ModTime: time.Now(),
+	}
+	delete(fsys, "blog/two.md")
+	idx.ContentPrefix = "/posts/"
+	w = serve(t, idx, "gemini://localhost/rust")
+	require.Equal(t, "# Tag: rust\n\n=> /posts/blog/one.gmi One\n", w.body.String())
+	require.Equal(t, gemini.StatusNotFound, serve(t, idx, "gemini://localhost/go").status)
+}
@@ -0,0 +1,71 @@
+package gemini
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Tarpit is a handler for paths that only malicious scanners request.
+// It answers slowly, dripping a byte at a time, to tie up the scanner
+// while costing the server one goroutine per request.
+type Tarpit struct {
+	// Delay between bytes. Defaults to 1 second.
+	Delay time.Duration
+
+	// Duration is the total time a request is held. Defaults to 1 minute.
+	Duration time.Duration
+
+	// MaxConcurrent bounds the requests held at once; requests beyond
+	// it are answered with 51 immediately. Defaults to 16.
+	MaxConcurrent int
+
+	// Flag is optionally called with every trapped request, for example
+	// to record the client address with an abuse detection system.
+	Flag func(r *Request)
+
+	active int32
+}
+
+// ServeGemini holds the request for Duration while dripping bytes.
+func (t *Tarpit) ServeGemini(w ResponseWriter, r *Request) {
+	if t.Flag != nil {
+		t.Flag(r)
+	}
+	max := t.MaxConcurrent
+	if max <= 0 {
+		max = 16
+	}
+	if atomic.AddInt32(&t.active, 1) > int32(max) {
+		atomic.AddInt32(&t.active, -1)
+		NotFound(w, r)
+		return
+	}
+	defer atomic.AddInt32(&t.active, -1)
+
+	delay, duration := t.Delay, t.Duration
+	if delay <= 0 {
+		delay = time.Second
+	}
+	if duration <= 0 {
+		duration = time.Minute
+	}
+	if err := w.WriteStatusMsg(StatusSuccess, "text/gemini"); err != nil {
+		return
+	}
+	deadline := time.NewTimer(duration)
+	defer deadline.Stop()
+	tick := time.NewTicker(delay)
+	defer tick.Stop()
+	for {
+		select {
+		case <-deadline.C:
+			return
+		case <-r.Context().Done():
+			return
+		case <-tick.C:
+			if _, err := w.WriteBody([]byte{'.'}); err != nil {
+				return
+			}
+		}
+	}
+}
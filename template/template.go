@@ -0,0 +1,197 @@
+// Package template implements data-driven text/gemini templates on top of
+// text/template.
+//
+// Values interpolated by actions are escaped like text lines written by
+// gemtext.Writer: a line of a value that would otherwise read as a link,
+// heading, list item, quote or preformat toggle is prefixed with a space.
+// The first line of a value is only escaped when the action starts a line
+// of the template. Values of type Gemtext, such as the results of the
+// link, heading, item, quote and pre template functions, are written
+// unescaped.
+//
+//	t := template.Must(template.New("post").Parse(
+//		"{{heading 1 .Title}}\n\n{{.Body}}\n\n{{link .Home \"Home\"}}\n"))
+//	err := t.ExecuteResponse(w, post)
+package template
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/gemtext"
+)
+
+// Gemtext is text/gemini markup written by templates as it is.
+type Gemtext string
+
+// FuncMap is the type of the map defining the mapping from names to
+// functions, as in text/template.
+type FuncMap = template.FuncMap
+
+const escapeFunc = "_gemtext_escape"
+
+// Template is a text/gemini template. Its methods mirror the ones of
+// text/template.Template.
+type Template struct {
+	text    *template.Template
+	escaped map[*parse.Tree]bool
+}
+
+// New allocates a new template with the given name and the functions
+// below:
+//
+//	link URL LABEL      a link line; an empty label omits it
+//	heading LEVEL TEXT  a heading line, level clamped to 1 to 3
+//	item TEXT           a list item line
+//	quote TEXT          a quote line
+//	pre ALT BODY        a preformatted block
+//	raw TEXT            TEXT as Gemtext, written unescaped
+func New(name string) *Template {
+	t := &Template{text: template.New(name), escaped: map[*parse.Tree]bool{}}
+	t.text.Funcs(FuncMap{
+		escapeFunc: escape,
+		"link":     func(url, label string) Gemtext { return write(func(w *gemtext.Writer) { w.Link(url, label) }) },
+		"heading":  func(level int, text string) Gemtext { return write(func(w *gemtext.Writer) { w.Heading(level, text) }) },
+		"item":     func(text string) Gemtext { return write(func(w *gemtext.Writer) { w.ListItem(text) }) },
+		"quote":    func(text string) Gemtext { return write(func(w *gemtext.Writer) { w.Quote(text) }) },
+		"pre":      func(alt, body string) Gemtext { return write(func(w *gemtext.Writer) { w.Pre(alt, body) }) },
+		"raw":      func(text string) Gemtext { return Gemtext(text) },
+	})
+	return t
+}
+
+// Must panics if err is not nil and returns t otherwise.
+func Must(t *Template, err error) *Template {
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+// Name returns the name of the template.
+func (t *Template) Name() string {
+	return t.text.Name()
+}
+
+// Funcs adds the functions of funcMap to the template's function map.
+func (t *Template) Funcs(funcMap FuncMap) *Template {
+	t.text.Funcs(funcMap)
+	return t
+}
+
+// Parse parses text as a template body for t. Templates defined in text
+// are associated with t.
+func (t *Template) Parse(text string) (*Template, error) {
+	if _, err := t.text.Parse(text); err != nil {
+		return nil, err
+	}
+	for _, tmpl := range t.text.Templates() {
+		if tree := tmpl.Tree; tree != nil && !t.escaped[tree] {
+			escapeList(tree.Root)
+			t.escaped[tree] = true
+		}
+	}
+	return t, nil
+}
+
+// Execute applies the template to data and writes the output to w.
+func (t *Template) Execute(w io.Writer, data interface{}) error {
+	return t.text.Execute(w, data)
+}
+
+// ExecuteTemplate applies the template associated with t that has the
+// given name to data and writes the output to w.
+func (t *Template) ExecuteTemplate(w io.Writer, name string, data interface{}) error {
+	return t.text.ExecuteTemplate(w, name, data)
+}
+
+// ExecuteResponse applies the template to data and sends the output as
+// a text/gemini response. Nothing is written to w if execution fails, so
+// the caller can still send an error status.
+func (t *Template) ExecuteResponse(w gemini.ResponseWriter, data interface{}) error {
+	var b bytes.Buffer
+	if err := t.Execute(&b, data); err != nil {
+		return err
+	}
+	if err := w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini"); err != nil {
+		return err
+	}
+	_, err := w.WriteBody(b.Bytes())
+	return err
+}
+
+func write(f func(*gemtext.Writer)) Gemtext {
+	var b strings.Builder
+	f(gemtext.NewWriter(&b))
+	return Gemtext(strings.TrimSuffix(b.String(), "\n"))
+}
+
+// escape escapes the lines of v that would read as markup. The first line
+// is only escaped if lineStart is set.
+func escape(lineStart bool, v interface{}) Gemtext {
+	switch v := v.(type) {
+	case Gemtext:
+		return v
+	case nil:
+		return ""
+	}
+	s := fmt.Sprint(v)
+	first := s
+	rest := ""
+	if i := strings.IndexByte(s, '\n'); i >= 0 {
+		first, rest = s[:i], s[i:]
+	}
+	if lineStart && first != "" {
+		first = string(write(func(w *gemtext.Writer) { w.Text(first) }))
+	}
+	if rest != "" {
+		// rest starts with a line break, so its first line is empty.
+		rest = string(write(func(w *gemtext.Writer) { w.Text(rest) }))
+	}
+	return Gemtext(first + rest)
+}
+
+// escapeList adds escaping to the output actions of list. An action
+// counts as starting a line unless it directly follows template text
+// that does not end in a line break.
+func escapeList(list *parse.ListNode) {
+	if list == nil {
+		return
+	}
+	lineStart := true
+	for _, n := range list.Nodes {
+		switch n := n.(type) {
+		case *parse.TextNode:
+			if len(n.Text) > 0 {
+				lineStart = n.Text[len(n.Text)-1] == '\n'
+			}
+			continue
+		case *parse.ActionNode:
+			if len(n.Pipe.Decl) == 0 {
+				n.Pipe.Cmds = append(n.Pipe.Cmds, &parse.CommandNode{
+					NodeType: parse.NodeCommand,
+					Pos:      n.Pos,
+					Args: []parse.Node{
+						parse.NewIdentifier(escapeFunc).SetPos(n.Pos),
+						&parse.BoolNode{NodeType: parse.NodeBool, Pos: n.Pos, True: lineStart},
+					},
+				})
+			}
+		case *parse.IfNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.RangeNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		case *parse.WithNode:
+			escapeList(n.List)
+			escapeList(n.ElseList)
+		}
+		lineStart = true
+	}
+}
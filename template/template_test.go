@@ -0,0 +1,68 @@
+package template_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/template"
+	"github.com/stretchr/testify/require"
+)
+
+type recorder struct {
+	status gemini.StatusCode
+	meta   string
+	body   bytes.Buffer
+}
+
+func (r *recorder) WriteStatusMsg(status gemini.StatusCode, msg string) error {
+	r.status, r.meta = status, msg
+	return nil
+}
+
+func (r *recorder) WriteBody(b []byte) (int, error) {
+	return r.body.Write(b)
+}
+
+func TestTemplateEscaping(t *testing.T) {
+	tmpl := template.Must(template.New("page").Parse(
+		"{{heading 1 .Title}}\n" +
+			"{{.Body}}\n" +
+			"Inline {{.Title}}\n" +
+			"{{range .Links}}{{link .URL .Label}}\n{{end}}" +
+			"{{pre \"code\" .Code}}\n" +
+			"{{raw \"=> raw.gmi\"}}\n" +
+			"{{template \"item\" .Body}}\n" +
+			"{{define \"item\"}}{{item .}}{{end}}"))
+
+	var b strings.Builder
+	err := tmpl.Execute(&b, map[string]interface{}{
+		"Title": "# Hi\n=> x",
+		"Body":  "=> evil.gmi\nplain\n* item\n```",
+		"Links": []map[string]string{{"URL": "a b.gmi", "Label": "A\nB"}},
+		"Code":  "```\nx",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "# # Hi => x\n"+
+		" => evil.gmi\nplain\n * item\n ```\n"+
+		"Inline # Hi\n => x\n"+
+		"=> a%20b.gmi A B\n"+
+		"```code\n ```\nx\n```\n"+
+		"=> raw.gmi\n"+
+		"* => evil.gmi plain * item ```\n", b.String())
+}
+
+func TestTemplateExecuteResponse(t *testing.T) {
+	tmpl := template.Must(template.New("t").Parse("Hello {{.}}\n"))
+	w := &recorder{}
+	require.NoError(t, tmpl.ExecuteResponse(w, "world"))
+	require.Equal(t, gemini.StatusSuccess, w.status)
+	require.Equal(t, "text/gemini", w.meta)
+	require.Equal(t, "Hello world\n", w.body.String())
+
+	tmpl = template.Must(template.New("t").Parse("{{.Missing.Field}}"))
+	w = &recorder{}
+	require.Error(t, tmpl.ExecuteResponse(w, struct{}{}))
+	require.Zero(t, w.status)
+}
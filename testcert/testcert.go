@@ -0,0 +1,192 @@
+// Package testcert provides certificate fixtures for testing Gemini servers
+// and clients.
+//
+// Fixtures are generated once per process on first use, so tests do not
+// need to ship or generate certificate material themselves.
+package testcert
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cert is a certificate fixture together with its private key.
+type Cert struct {
+	Certificate *x509.Certificate
+	PrivateKey  crypto.Signer
+	CertPEM     []byte
+	KeyPEM      []byte
+}
+
+// TLSCertificate returns c in the form accepted by tls.Config.
+func (c *Cert) TLSCertificate() tls.Certificate {
+	return tls.Certificate{
+		Certificate: [][]byte{c.Certificate.Raw},
+		PrivateKey:  c.PrivateKey,
+		Leaf:        c.Certificate,
+	}
+}
+
+// Fingerprint returns hex encoded SHA-256 hash of the DER certificate.
+func (c *Cert) Fingerprint() string {
+	sum := sha256.Sum256(c.Certificate.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// WriteFiles writes certificate and key PEM files into dir and returns
+// their names, suitable for Server.ListenAndServeTLS.
+func (c *Cert) WriteFiles(dir string) (certFile, keyFile string, err error) {
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	if err = os.WriteFile(certFile, c.CertPEM, 0600); err != nil {
+		return "", "", err
+	}
+	if err = os.WriteFile(keyFile, c.KeyPEM, 0600); err != nil {
+		return "", "", err
+	}
+	return certFile, keyFile, nil
+}
+
+// Hosts lists names the server fixtures are valid for.
+var Hosts = []string{"localhost", "127.0.0.1", "::1"}
+
+// WrongHostName is the only name WrongHost is valid for.
+const WrongHostName = "wrong.example.com"
+
+type fixtures struct {
+	ca, leaf, expiredLeaf, wrongHost, client, expiredClient *Cert
+}
+
+var (
+	once sync.Once
+	all  fixtures
+)
+
+func load() *fixtures {
+	once.Do(func() {
+		now := time.Now()
+		valid := [2]time.Time{now.Add(-time.Hour), now.AddDate(10, 0, 0)}
+		expired := [2]time.Time{now.AddDate(0, 0, -2), now.AddDate(0, 0, -1)}
+
+		all.ca = mustCreate(&x509.Certificate{
+			Subject:               pkix.Name{CommonName: "Gemini Test CA"},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		}, valid, nil)
+		all.leaf = mustCreate(serverTemplate(Hosts...), valid, all.ca)
+		all.expiredLeaf = mustCreate(serverTemplate(Hosts...), expired, all.ca)
+		all.wrongHost = mustCreate(serverTemplate(WrongHostName), valid, all.ca)
+		all.client = mustCreate(clientTemplate("client"), valid, nil)
+		all.expiredClient = mustCreate(clientTemplate("expired-client"), expired, nil)
+	})
+	return &all
+}
+
+// CA returns the certificate authority that signed the server fixtures.
+func CA() *Cert { return load().ca }
+
+// Leaf returns a valid server certificate for Hosts signed by CA.
+func Leaf() *Cert { return load().leaf }
+
+// ExpiredLeaf returns a server certificate for Hosts that has expired.
+func ExpiredLeaf() *Cert { return load().expiredLeaf }
+
+// WrongHost returns a valid server certificate for WrongHostName only.
+func WrongHost() *Cert { return load().wrongHost }
+
+// Client returns a valid self-signed client identity certificate.
+func Client() *Cert { return load().client }
+
+// ExpiredClient returns a self-signed client identity certificate that has
+// expired.
+func ExpiredClient() *Cert { return load().expiredClient }
+
+// CertPool returns a pool containing CA.
+func CertPool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(CA().Certificate)
+	return pool
+}
+
+func serverTemplate(hosts ...string) *x509.Certificate {
+	tmpl := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: hosts[0]},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+	return tmpl
+}
+
+func clientTemplate(cn string) *x509.Certificate {
+	return &x509.Certificate{
+		Subject:     pkix.Name{CommonName: cn},
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+}
+
+func mustCreate(tmpl *x509.Certificate, validity [2]time.Time, parent *Cert) *Cert {
+	c, err := create(tmpl, validity, parent)
+	if err != nil {
+		panic(fmt.Sprintf("testcert: %v", err))
+	}
+	return c
+}
+
+func create(tmpl *x509.Certificate, validity [2]time.Time, parent *Cert) (*Cert, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	tmpl.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, err
+	}
+	tmpl.NotBefore, tmpl.NotAfter = validity[0], validity[1]
+
+	parentCert, parentKey := tmpl, crypto.Signer(key)
+	if parent != nil {
+		parentCert, parentKey = parent.Certificate, parent.PrivateKey
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parentCert, key.Public(), parentKey)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &Cert{
+		Certificate: cert,
+		PrivateKey:  key,
+		CertPEM:     pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyPEM:      pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}),
+	}, nil
+}
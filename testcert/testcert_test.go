@@ -0,0 +1,34 @@
+package testcert_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+
+	"github.com/kulak/gemini/testcert"
+	"github.com/stretchr/testify/require"
+)
+
+func verify(c *testcert.Cert, host string) error {
+	_, err := c.Certificate.Verify(x509.VerifyOptions{
+		DNSName: host,
+		Roots:   testcert.CertPool(),
+	})
+	return err
+}
+
+func TestFixtures(t *testing.T) {
+	require.NoError(t, verify(testcert.Leaf(), "localhost"))
+	require.NoError(t, verify(testcert.Leaf(), "127.0.0.1"))
+	require.Error(t, verify(testcert.ExpiredLeaf(), "localhost"))
+	require.Error(t, verify(testcert.WrongHost(), "localhost"))
+	require.NoError(t, verify(testcert.WrongHost(), testcert.WrongHostName))
+	require.NotEqual(t, testcert.Client().Fingerprint(), testcert.ExpiredClient().Fingerprint())
+}
+
+func TestWriteFiles(t *testing.T) {
+	certFile, keyFile, err := testcert.Leaf().WriteFiles(t.TempDir())
+	require.NoError(t, err)
+	_, err = tls.LoadX509KeyPair(certFile, keyFile)
+	require.NoError(t, err)
+}
@@ -0,0 +1,236 @@
+// Package testkit runs declarative request/response scenarios against a
+// Gemini handler or a live server, so capsule authors can write black-box
+// tests of their capsules as YAML files.
+//
+// A scenario file lists requests and expectations:
+//
+//	scenarios:
+//	  - name: home page
+//	    url: gemini://localhost/
+//	    expect:
+//	      status: 20
+//	      meta: ^text/gemini
+//	      body: Welcome
+//	  - name: upload
+//	    url: titan://localhost/wiki/page;mime=text/plain;size=5
+//	    body: hello
+//	    expect:
+//	      status: 30
+//
+// Meta and body expectations are regular expressions; omitted
+// expectations are not checked.
+package testkit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kulak/gemini"
+	"gopkg.in/yaml.v3"
+)
+
+// Scenario is a single request and the response expected for it.
+type Scenario struct {
+	Name string `yaml:"name"`
+	URL  string `yaml:"url"`
+	// Body is sent after the request line, as a Titan payload.
+	Body   string      `yaml:"body"`
+	Expect Expectation `yaml:"expect"`
+}
+
+// Expectation describes the expected response.
+type Expectation struct {
+	// Status is the expected status code; zero is not checked.
+	Status int `yaml:"status"`
+	// Meta is a regular expression matched against the meta.
+	Meta string `yaml:"meta"`
+	// Body is a regular expression matched against the body.
+	Body string `yaml:"body"`
+}
+
+// Result is the outcome of running a Scenario.
+type Result struct {
+	Scenario Scenario
+	Status   int
+	Meta     string
+	Body     string
+	// Err is nil when the response met the expectation.
+	Err error
+}
+
+type file struct {
+	Scenarios []Scenario `yaml:"scenarios"`
+}
+
+// Parse decodes scenarios from YAML.
+func Parse(data []byte) ([]Scenario, error) {
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to parse scenarios: %v", err)
+	}
+	for i, s := range f.Scenarios {
+		if s.URL == "" {
+			return nil, fmt.Errorf("scenario %d (%s) has no url", i+1, s.Name)
+		}
+		if s.Name == "" {
+			f.Scenarios[i].Name = s.URL
+		}
+	}
+	return f.Scenarios, nil
+}
+
+// Load reads scenarios from a YAML file.
+func Load(name string) ([]Scenario, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Check compares a response with the expectation.
+func (e Expectation) Check(status int, meta, body string) error {
+	if e.Status != 0 && e.Status != status {
+		return fmt.Errorf("status: got %d, want %d", status, e.Status)
+	}
+	if e.Meta != "" {
+		if err := match("meta", e.Meta, meta); err != nil {
+			return err
+		}
+	}
+	if e.Body != "" {
+		if err := match("body", e.Body, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func match(what, expr, s string) error {
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return fmt.Errorf("%s: invalid expectation: %v", what, err)
+	}
+	if !re.MatchString(s) {
+		return fmt.Errorf("%s: %q does not match %q", what, s, expr)
+	}
+	return nil
+}
+
+// RunHandler runs scenarios against h in process.
+func RunHandler(h gemini.Handler, scenarios []Scenario) []Result {
+	results := make([]Result, len(scenarios))
+	for i, s := range scenarios {
+		res := Result{Scenario: s}
+		r := &gemini.Request{}
+		if err := r.Reset(nil, s.URL); err != nil {
+			res.Err = err
+			results[i] = res
+			continue
+		}
+		r.RemoteAddr = "127.0.0.1:0"
+		r.Titan.Body = io.NopCloser(strings.NewReader(s.Body))
+		if r.Titan.Size == 0 {
+			r.Titan.Size = int64(len(s.Body))
+		}
+		w := NewRecorder()
+		h.ServeGemini(w, r)
+		res.Status, res.Meta, res.Body = int(w.Status), w.Meta, w.Body.String()
+		res.Err = s.Expect.Check(res.Status, res.Meta, res.Body)
+		results[i] = res
+	}
+	return results
+}
+
+// RunServer runs scenarios against a live server listening on addr.
+// Server certificates are not verified.
+func RunServer(addr string, scenarios []Scenario) []Result {
+	results := make([]Result, len(scenarios))
+	for i, s := range scenarios {
+		res := Result{Scenario: s}
+		res.Status, res.Meta, res.Body, res.Err = fetch(addr, s)
+		if res.Err == nil {
+			res.Err = s.Expect.Check(res.Status, res.Meta, res.Body)
+		}
+		results[i] = res
+	}
+	return results
+}
+
+func fetch(addr string, s Scenario) (int, string, string, error) {
+	conn, err := tls.Dial("tcp", addr, &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		return 0, "", "", err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+	if _, err = io.WriteString(conn, s.URL+"\r\n"+s.Body); err != nil {
+		return 0, "", "", err
+	}
+	br := bufio.NewReader(conn)
+	header, err := br.ReadString('\n')
+	if err != nil {
+		return 0, "", "", fmt.Errorf("failed to read response header: %v", err)
+	}
+	header = strings.TrimRight(header, "\r\n")
+	code, meta := header, ""
+	if i := strings.IndexByte(header, ' '); i >= 0 {
+		code, meta = header[:i], header[i+1:]
+	}
+	status, err := strconv.Atoi(code)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid response header: %q", header)
+	}
+	body, err := io.ReadAll(br)
+	if err != nil && len(body) == 0 {
+		return status, meta, "", err
+	}
+	return status, meta, string(body), nil
+}
+
+// Report fails t for every failed result.
+func Report(t testing.TB, results []Result) {
+	t.Helper()
+	for _, r := range results {
+		if r.Err != nil {
+			t.Errorf("%s: %v", r.Scenario.Name, r.Err)
+		}
+	}
+}
+
+// Recorder is a ResponseWriter recording the response in memory.
+type Recorder struct {
+	Status gemini.StatusCode
+	Meta   string
+	Body   bytes.Buffer
+}
+
+// NewRecorder returns an empty Recorder.
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+// WriteStatusMsg records the status line.
+func (r *Recorder) WriteStatusMsg(status gemini.StatusCode, msg string) error {
+	r.Status, r.Meta = status, msg
+	return nil
+}
+
+// WriteBody records body bytes.
+func (r *Recorder) WriteBody(b []byte) (int, error) {
+	return r.Body.Write(b)
+}
+
+// Write implements io.Writer.
+func (r *Recorder) Write(b []byte) (int, error) {
+	return r.Body.Write(b)
+}
@@ -0,0 +1,79 @@
+package testkit_test
+
+import (
+	"crypto/tls"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/kulak/gemini/testcert"
+	"github.com/kulak/gemini/testkit"
+	"github.com/stretchr/testify/require"
+)
+
+const scenarios = `
+scenarios:
+  - name: home
+    url: gemini://localhost/
+    expect:
+      status: 20
+      meta: ^text/gemini$
+      body: Welcome
+  - url: titan://localhost/upload;mime=text/plain;size=5
+    body: hello
+    expect:
+      status: 20
+      body: ^got hello$
+  - name: wrong
+    url: gemini://localhost/missing
+    expect:
+      status: 20
+`
+
+func capsule() gemini.Handler {
+	mux := gemini.NewServeMux()
+	mux.HandleFunc("/", func(w gemini.ResponseWriter, r *gemini.Request) {
+		if r.URL.Path != "/" {
+			gemini.NotFound(w, r)
+			return
+		}
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/gemini")
+		w.WriteBody([]byte("# Welcome"))
+	})
+	mux.HandleTitanFunc("/upload", func(w gemini.ResponseWriter, r *gemini.Request) {
+		payload, err := r.ReadTitanPayload()
+		if err != nil {
+			gemini.Error(w, gemini.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteStatusMsg(gemini.StatusSuccess, "text/plain")
+		w.WriteBody(append([]byte("got "), payload...))
+	})
+	return mux
+}
+
+func check(t *testing.T, results []testkit.Result) {
+	require.Len(t, results, 3)
+	require.NoError(t, results[0].Err)
+	require.NoError(t, results[1].Err)
+	require.EqualError(t, results[2].Err, "status: got 51, want 20")
+	require.Equal(t, "titan://localhost/upload;mime=text/plain;size=5", results[1].Scenario.Name)
+}
+
+func TestRunHandler(t *testing.T) {
+	s, err := testkit.Parse([]byte(scenarios))
+	require.NoError(t, err)
+	check(t, testkit.RunHandler(capsule(), s))
+}
+
+func TestRunServer(t *testing.T) {
+	s, err := testkit.Parse([]byte(scenarios))
+	require.NoError(t, err)
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{testcert.Leaf().TLSCertificate()},
+	})
+	require.NoError(t, err)
+	srv := &gemini.Server{Handler: capsule()}
+	go srv.Serve(ln)
+	defer srv.Close()
+	check(t, testkit.RunServer(ln.Addr().String(), s))
+}
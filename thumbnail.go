@@ -0,0 +1,197 @@
+package gemini
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif" // register GIF decoding for sources
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	_ "golang.org/x/image/webp" // register WebP decoding for sources
+)
+
+// ThumbnailHandler serves images from a directory, resized on request.
+// Create it with Thumbnails.
+//
+// A query of the form "?w=320" selects an image at most 320 pixels wide,
+// keeping the aspect ratio; images are never enlarged. Adding
+// "&format=jpeg", "&format=png" or "&format=webp" transcodes the image,
+// otherwise JPEG and WebP sources keep their format and others are
+// served as PNG. WebP output is lossless. Requests without a query, and
+// files that are not images, are served unchanged.
+//
+// Resized variants are generated on first request and cached on disk,
+// keyed by path, modification time, width and format.
+type ThumbnailHandler struct {
+	// MaxWidth limits the requested width. Defaults to 2048.
+	MaxWidth int
+
+	root     string
+	cacheDir string
+}
+
+// Thumbnails returns a ThumbnailHandler serving images from root and
+// caching resized variants in cacheDir. With an empty cacheDir variants
+// are generated for every request.
+func Thumbnails(root, cacheDir string) *ThumbnailHandler {
+	return &ThumbnailHandler{root: root, cacheDir: cacheDir}
+}
+
+// ServeGemini serves the image named by the request path.
+func (h *ThumbnailHandler) ServeGemini(w ResponseWriter, r *Request) {
+	if hasDotDot(r.URL.Path) {
+		w.WriteStatusMsg(StatusBadRequest, Message(r, "Bad Request"))
+		return
+	}
+	name := filepath.Join(h.root, filepath.FromSlash(path.Clean("/"+r.URL.Path)))
+	f, err := os.Open(name)
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		fileError(w, r, err)
+		return
+	}
+	if fi.IsDir() {
+		NotFound(w, r)
+		return
+	}
+
+	q := r.URL.Query()
+	if q.Get("w") == "" {
+		ServeContent(w, r, name, "", f)
+		return
+	}
+	width, err := strconv.Atoi(q.Get("w"))
+	if err != nil || width <= 0 || width > h.maxWidth() {
+		w.WriteStatusMsg(StatusBadRequest, messagef(r, "Width must be between 1 and %d", h.maxWidth()))
+		return
+	}
+	format := strings.ToLower(q.Get("format"))
+	switch format {
+	case "":
+		switch strings.ToLower(path.Ext(name)) {
+		case ".jpg", ".jpeg":
+			format = "jpeg"
+		case ".webp":
+			format = "webp"
+		default:
+			format = "png"
+		}
+	case "jpg":
+		format = "jpeg"
+	case "jpeg", "png", "webp":
+	default:
+		w.WriteStatusMsg(StatusBadRequest, messagef(r, "Unsupported format %s", format))
+		return
+	}
+	mimeType := "image/" + format
+
+	key := fmt.Sprintf("%s\x00%d\x00%d\x00%s", name, fi.ModTime().UnixNano(), width, format)
+	sum := sha256.Sum256([]byte(key))
+	cached := ""
+	if h.cacheDir != "" {
+		cached = filepath.Join(h.cacheDir, hex.EncodeToString(sum[:])+"."+format)
+		if c, err := os.Open(cached); err == nil {
+			defer c.Close()
+			ServeContent(w, r, cached, mimeType, c)
+			return
+		}
+	}
+
+	src, _, err := image.Decode(f)
+	if err != nil {
+		// Not an image we can decode; serve it unchanged.
+		ServeContent(w, r, name, "", f)
+		return
+	}
+	var buf bytes.Buffer
+	dst := resizeImage(src, width)
+	switch format {
+	case "jpeg":
+		err = jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80})
+	case "webp":
+		err = encodeWebP(&buf, dst)
+	default:
+		err = png.Encode(&buf, dst)
+	}
+	if err != nil {
+		w.WriteStatusMsg(StatusUnspecified, Message(r, "Internal Server Error"))
+		return
+	}
+	if cached != "" {
+		_ = writeFileAtomic(cached, buf.Bytes())
+	}
+	ServeContent(w, r, name, mimeType, bytes.NewReader(buf.Bytes()))
+}
+
+func (h *ThumbnailHandler) maxWidth() int {
+	if h.MaxWidth > 0 {
+		return h.MaxWidth
+	}
+	return 2048
+}
+
+// resizeImage scales src down to width pixels with a box filter.
+func resizeImage(src image.Image, width int) image.Image {
+	b := src.Bounds()
+	if width >= b.Dx() {
+		return src
+	}
+	height := b.Dy() * width / b.Dx()
+	if height < 1 {
+		height = 1
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := b.Min.Y + y*b.Dy()/height
+		y1 := b.Min.Y + (y+1)*b.Dy()/height
+		for x := 0; x < width; x++ {
+			x0 := b.Min.X + x*b.Dx()/width
+			x1 := b.Min.X + (x+1)*b.Dx()/width
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r, g, bl, a = r+uint64(cr), g+uint64(cg), bl+uint64(cb), a+uint64(ca)
+					n++
+				}
+			}
+			dst.SetRGBA64(x, y, color.RGBA64{
+				R: uint16(r / n), G: uint16(g / n), B: uint16(bl / n), A: uint16(a / n),
+			})
+		}
+	}
+	return dst
+}
+
+// writeFileAtomic writes data to a temporary file next to name and renames
+// it into place, so concurrent readers never see a partial file.
+func writeFileAtomic(name string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(name), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
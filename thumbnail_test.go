@@ -0,0 +1,98 @@
+package gemini_test
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/webp"
+)
+
+func TestThumbnails(t *testing.T) {
+	root, cache := t.TempDir(), t.TempDir()
+	img := image.NewRGBA(image.Rect(0, 0, 100, 50))
+	for i := range img.Pix {
+		img.Pix[i] = 0xff
+	}
+	img.Set(0, 0, color.Black)
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pic.png"), buf.Bytes(), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "notes.txt"), []byte("text"), 0644))
+	h := gemini.Thumbnails(root, cache)
+
+	w := serve(t, h, "gemini://localhost/pic.png")
+	require.Equal(t, "image/png", w.meta)
+	require.Equal(t, buf.Bytes(), w.body.Bytes())
+
+	w = serve(t, h, "gemini://localhost/pic.png?w=20")
+	require.Equal(t, "image/png", w.meta)
+	small, err := png.Decode(&w.body)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 20, 10), small.Bounds())
+
+	cached, err := os.ReadDir(cache)
+	require.NoError(t, err)
+	require.Len(t, cached, 1)
+	w = serve(t, h, "gemini://localhost/pic.png?w=20")
+	require.Equal(t, gemini.StatusSuccess, w.status)
+
+	w = serve(t, h, "gemini://localhost/pic.png?w=500&format=jpeg")
+	require.Equal(t, "image/jpeg", w.meta)
+	big, err := jpeg.Decode(&w.body)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 100, 50), big.Bounds())
+
+	w = serve(t, h, "gemini://localhost/pic.png?w=50&format=webp")
+	require.Equal(t, "image/webp", w.meta)
+	webpImg, err := webp.Decode(bytes.NewReader(w.body.Bytes()))
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 50, 25), webpImg.Bounds())
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pic.webp"), w.body.Bytes(), 0644))
+	w = serve(t, h, "gemini://localhost/pic.webp?w=10")
+	require.Equal(t, "image/webp", w.meta)
+	webpImg, err = webp.Decode(&w.body)
+	require.NoError(t, err)
+	require.Equal(t, image.Rect(0, 0, 10, 5), webpImg.Bounds())
+
+	w = serve(t, h, "gemini://localhost/notes.txt?w=20")
+	require.Equal(t, "text", w.body.String())
+
+	for _, q := range []string{"w=0", "w=abc", "w=99999", "w=20&format=gif"} {
+		w = serve(t, h, "gemini://localhost/pic.png?"+q)
+		require.Equal(t, gemini.StatusBadRequest, w.status, q)
+	}
+	w = serve(t, h, "gemini://localhost/missing.png?w=20")
+	require.Equal(t, gemini.StatusNotFound, w.status)
+}
+
+func TestThumbnailsWebP(t *testing.T) {
+	root := t.TempDir()
+	img := image.NewNRGBA(image.Rect(0, 0, 300, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x), G: uint8(x * y), B: uint8(y), A: uint8(255 - x%7)})
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "pic.png"), buf.Bytes(), 0644))
+
+	w := serve(t, gemini.Thumbnails(root, ""), "gemini://localhost/pic.png?w=300&format=webp")
+	require.Equal(t, "image/webp", w.meta)
+	got, err := webp.Decode(&w.body)
+	require.NoError(t, err)
+	require.Equal(t, img.Bounds(), got.Bounds())
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 300; x++ {
+			require.Equal(t, img.NRGBAAt(x, y), color.NRGBAModel.Convert(got.At(x, y)), "%d,%d", x, y)
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package gemini
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrHandlerTimeout is returned on ResponseWriter writes in handlers which
+// have timed out.
+var ErrHandlerTimeout = errors.New("gemini: handler timeout")
+
+// TimeoutHandler returns a Handler that runs h with the given time limit.
+//
+// If h does not return within dt, the request context is canceled and,
+// unless h has already sent its status line, the client receives
+// 41 SERVER UNAVAILABLE with msg as the meta. After the timeout any write
+// by h returns ErrHandlerTimeout.
+func TimeoutHandler(h Handler, dt time.Duration, msg string) Handler {
+	return TimeoutStatusHandler(h, dt, StatusServerUnavalable, msg)
+}
+
+// TimeoutStatusHandler is like TimeoutHandler but replies with status on
+// timeout.
+func TimeoutStatusHandler(h Handler, dt time.Duration, status StatusCode, msg string) Handler {
+	th := &timeoutHandler{dt: dt, status: status, msg: msg}
+	return wrapTitan(h, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			th.serve(next, w, r)
+		}
+	})
+}
+
+type timeoutHandler struct {
+	dt     time.Duration
+	status StatusCode
+	msg    string
+}
+
+func (h *timeoutHandler) serve(next Handler, w ResponseWriter, r *Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.dt)
+	defer cancel()
+	r = r.WithContext(ctx)
+
+	tw := &timeoutWriter{w: w, ctx: ctx}
+	done := make(chan struct{})
+	panicChan := make(chan interface{}, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicChan <- p
+			}
+		}()
+		next.ServeGemini(tw, r)
+		close(done)
+	}()
+	select {
+	case p := <-panicChan:
+		panic(p)
+	case <-done:
+	case <-ctx.Done():
+		tw.mu.Lock()
+		tw.timedOut = true
+		wroteHeader := tw.wroteHeader
+		tw.mu.Unlock()
+		if !wroteHeader {
+			w.WriteStatusMsg(h.status, h.msg)
+		}
+	}
+}
+
+// timeoutWriter passes writes through until the handler times out.
+type timeoutWriter struct {
+	w   ResponseWriter
+	ctx context.Context
+
+	// writeMu serializes the handler's writes. The timeout path never
+	// waits for it, so a write blocked on a slow client does not hold up
+	// the timeout.
+	writeMu sync.Mutex
+
+	mu          sync.Mutex
+	timedOut    bool
+	wroteHeader bool
+}
+
+// claim reports whether the handler may still write, marking the status
+// line as written if header is set. Once a status line is claimed the
+// timeout path no longer writes its own.
+func (tw *timeoutWriter) claim(header bool) error {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.ctx.Err() == context.DeadlineExceeded {
+		return ErrHandlerTimeout
+	}
+	if header {
+		tw.wroteHeader = true
+	} else if !tw.wroteHeader {
+		return errors.New("status message is not written")
+	}
+	return nil
+}
+
+func (tw *timeoutWriter) WriteStatusMsg(status StatusCode, msg string) error {
+	tw.writeMu.Lock()
+	defer tw.writeMu.Unlock()
+	if err := tw.claim(true); err != nil {
+		return err
+	}
+	return tw.w.WriteStatusMsg(status, msg)
+}
+
+func (tw *timeoutWriter) WriteBody(b []byte) (int, error) {
+	tw.writeMu.Lock()
+	defer tw.writeMu.Unlock()
+	if err := tw.claim(false); err != nil {
+		return 0, err
+	}
+	return tw.w.WriteBody(b)
+}
+
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	return tw.WriteBody(b)
+}
+
+func (tw *timeoutWriter) Unwrap() ResponseWriter {
+	return tw.w
+}
@@ -0,0 +1,127 @@
+package gemini
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken is returned by TokenValidator implementations for a
+// missing, unknown or expired titan token.
+var ErrInvalidToken = errors.New("Invalid Token")
+
+// TokenValidator authorizes titan uploads by their token parameter.
+type TokenValidator interface {
+	// ValidateToken returns nil when token authorizes the upload to path.
+	ValidateToken(path, token string) error
+}
+
+// StaticTokens accepts any of its tokens for every path.
+type StaticTokens []string
+
+// ValidateToken implements TokenValidator.
+func (s StaticTokens) ValidateToken(path, token string) error {
+	if token == "" {
+		return ErrInvalidToken
+	}
+	for _, t := range s {
+		if subtle.ConstantTimeCompare([]byte(t), []byte(token)) == 1 {
+			return nil
+		}
+	}
+	return ErrInvalidToken
+}
+
+// PathTokens maps paths to the tokens accepted for them. A key ending in
+// a slash covers the subtree below it; the longest matching key wins.
+type PathTokens map[string]StaticTokens
+
+// ValidateToken implements TokenValidator.
+func (p PathTokens) ValidateToken(path, token string) error {
+	if tokens, ok := p[path]; ok {
+		return tokens.ValidateToken(path, token)
+	}
+	best := ""
+	for prefix := range p {
+		if strings.HasSuffix(prefix, "/") && strings.HasPrefix(path, prefix) && len(prefix) > len(best) {
+			best = prefix
+		}
+	}
+	if best == "" {
+		return ErrInvalidToken
+	}
+	return p[best].ValidateToken(path, token)
+}
+
+// HMACTokens issues and validates expiring tokens scoped to a single
+// path, so capsules can hand out upload credentials without storing
+// them. Tokens have the form "<unix expiry>.<hex HMAC-SHA256 of path and
+// expiry>".
+type HMACTokens struct {
+	Key []byte
+
+	// Now returns the current time. Defaults to time.Now.
+	Now func() time.Time
+}
+
+// Token returns a token authorizing uploads to path until expiry.
+func (h *HMACTokens) Token(path string, expiry time.Time) string {
+	exp := strconv.FormatInt(expiry.Unix(), 10)
+	return exp + "." + hex.EncodeToString(h.sum(path, exp))
+}
+
+// ValidateToken implements TokenValidator.
+func (h *HMACTokens) ValidateToken(path, token string) error {
+	i := strings.IndexByte(token, '.')
+	if i < 0 {
+		return ErrInvalidToken
+	}
+	exp, mac := token[:i], token[i+1:]
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	got, err := hex.DecodeString(mac)
+	if err != nil || !hmac.Equal(got, h.sum(path, exp)) {
+		return ErrInvalidToken
+	}
+	now := time.Now
+	if h.Now != nil {
+		now = h.Now
+	}
+	if !now().Before(time.Unix(unix, 0)) {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (h *HMACTokens) sum(path, exp string) []byte {
+	m := hmac.New(sha256.New, h.Key)
+	m.Write([]byte(path))
+	m.Write([]byte{0})
+	m.Write([]byte(exp))
+	return m.Sum(nil)
+}
+
+// RequireToken returns Middleware answering titan requests whose token
+// v rejects with 59 and the error text. Gemini requests pass through.
+func RequireToken(v TokenValidator) Middleware {
+	return func(next Handler) Handler {
+		return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+			return func(w ResponseWriter, r *Request) {
+				if r.URL.Scheme == SchemaTitan {
+					if err := v.ValidateToken(r.URL.Path, r.Titan.Token); err != nil {
+						w.WriteStatusMsg(StatusBadRequest, Message(r, err.Error()))
+						return
+					}
+				}
+				next.ServeGemini(w, r)
+			}
+		})
+	}
+}
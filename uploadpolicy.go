@@ -0,0 +1,85 @@
+package gemini
+
+import (
+	"mime"
+	"strings"
+)
+
+// UploadPolicy is middleware limiting the titan uploads accepted by a
+// part of a capsule, like Server.MaxUploadSize and Server.UploadTypes do
+// for the whole server:
+//
+//	images := &gemini.UploadPolicy{MaxSize: 5 << 20, Types: []string{"image/*"}}
+//	mux.HandleTitan("/images/", images.Middleware(imageUpload))
+//
+// Rejected uploads are answered with 59 BAD REQUEST before any of the
+// payload is read.
+type UploadPolicy struct {
+	// MaxSize limits the size an upload may declare. Zero means no limit.
+	MaxSize int64
+
+	// Types optionally lists the allowed MIME types. A type ending in
+	// "/*" allows all its subtypes. Uploads without a mime parameter are
+	// text/gemini; deletes are always allowed.
+	Types []string
+}
+
+// Middleware returns next wrapped in the policy.
+func (p *UploadPolicy) Middleware(next Handler) Handler {
+	return wrapTitan(next, func(next HandlerFunc) HandlerFunc {
+		return func(w ResponseWriter, r *Request) {
+			if err := checkUpload(r, p.MaxSize, p.Types); err != nil {
+				rejectUpload(w, r, err, p.MaxSize)
+				return
+			}
+			next.ServeGemini(w, r)
+		}
+	})
+}
+
+// checkUpload returns ErrUploadTooLarge or ErrUploadTypeNotAllowed if r is
+// a titan upload exceeding maxSize or of a type not in types.
+func checkUpload(r *Request, maxSize int64, types []string) error {
+	if r.URL.Scheme != SchemaTitan || r.Titan.Edit {
+		return nil
+	}
+	if maxSize > 0 && r.Titan.Size > maxSize {
+		return ErrUploadTooLarge
+	}
+	if len(types) > 0 && !r.Titan.IsDelete() && !typeAllowed(uploadType(r), types) {
+		return ErrUploadTypeNotAllowed
+	}
+	return nil
+}
+
+// rejectUpload answers an upload rejected by checkUpload.
+func rejectUpload(w ResponseWriter, r *Request, err error, maxSize int64) error {
+	if err == ErrUploadTooLarge {
+		return w.WriteStatusMsg(StatusBadRequest, messagef(r, "Upload exceeds %d bytes", maxSize))
+	}
+	return w.WriteStatusMsg(StatusBadRequest, messagef(r, "Upload type %s not allowed", uploadType(r)))
+}
+
+// uploadType returns the media type of a titan upload without parameters.
+func uploadType(r *Request) string {
+	if r.Titan.Mime == "" {
+		return "text/gemini"
+	}
+	mt, _, err := mime.ParseMediaType(r.Titan.Mime)
+	if err != nil {
+		return r.Titan.Mime
+	}
+	return mt
+}
+
+func typeAllowed(mt string, types []string) bool {
+	for _, t := range types {
+		if strings.EqualFold(t, mt) {
+			return true
+		}
+		if prefix := strings.TrimSuffix(t, "*"); prefix != t && strings.HasPrefix(mt, strings.ToLower(prefix)) {
+			return true
+		}
+	}
+	return false
+}
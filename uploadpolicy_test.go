@@ -0,0 +1,34 @@
+package gemini_test
+
+import (
+	"testing"
+
+	"github.com/kulak/gemini"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadPolicy(t *testing.T) {
+	p := &gemini.UploadPolicy{MaxSize: 10, Types: []string{"text/*", "image/png"}}
+	h := p.Middleware(text("ok"))
+	upload := func(rawurl string) *recorder {
+		t.Helper()
+		r := &gemini.Request{}
+		require.NoError(t, r.Reset(nil, rawurl))
+		w := &recorder{}
+		h.ServeGemini(w, r)
+		return w
+	}
+
+	require.Equal(t, gemini.StatusSuccess, upload("titan://localhost/a;mime=text/plain;charset=utf-8;size=5").status)
+	require.Equal(t, gemini.StatusSuccess, upload("titan://localhost/a;size=5").status)
+	require.Equal(t, gemini.StatusSuccess, upload("titan://localhost/a;mime=IMAGE/PNG;size=5").status)
+	require.Equal(t, gemini.StatusSuccess, upload("titan://localhost/a;mime=video/mp4;size=0").status)
+	require.Equal(t, gemini.StatusSuccess, upload("gemini://localhost/a").status)
+
+	w := upload("titan://localhost/a;mime=image/jpeg;size=5")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Upload type image/jpeg not allowed", w.meta)
+	w = upload("titan://localhost/a;mime=text/plain;size=11")
+	require.Equal(t, gemini.StatusBadRequest, w.status)
+	require.Equal(t, "Upload exceeds 10 bytes", w.meta)
+}
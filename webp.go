@@ -0,0 +1,281 @@
+package gemini
+
+import (
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"io"
+)
+
+// encodeWebP writes m to w as a lossless WebP image. It uses the VP8L
+// bitstream with the subtract green transform and one Huffman code per
+// channel, without backward references: simple, and good enough for
+// thumbnails.
+func encodeWebP(w io.Writer, m image.Image) error {
+	b := m.Bounds()
+	width, height := b.Dx(), b.Dy()
+	if width < 1 || height < 1 || width > 1<<14 || height > 1<<14 {
+		return errors.New("gemini: webp: invalid image size")
+	}
+
+	// Pixels in decoding order: green, red - green, blue - green, alpha.
+	n := width * height
+	pix := make([][4]uint8, 0, n)
+	var hist [4][]int
+	hist[0] = make([]int, 256+24) // green also codes length prefixes
+	for i := 1; i < 4; i++ {
+		hist[i] = make([]int, 256)
+	}
+	opaque := true
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			c := color.NRGBAModel.Convert(m.At(x, y)).(color.NRGBA)
+			p := [4]uint8{c.G, c.R - c.G, c.B - c.G, c.A}
+			for i, v := range p {
+				hist[i][v]++
+			}
+			opaque = opaque && c.A == 0xff
+			pix = append(pix, p)
+		}
+	}
+
+	var bw bitWriter
+	bw.write(0x2f, 8) // signature
+	bw.write(uint32(width-1), 14)
+	bw.write(uint32(height-1), 14)
+	if opaque {
+		bw.write(0, 1)
+	} else {
+		bw.write(1, 1)
+	}
+	bw.write(0, 3) // version
+	bw.write(1, 1) // transform present:
+	bw.write(2, 2) // subtract green
+	bw.write(0, 1) // no more transforms
+	bw.write(0, 1) // no color cache
+	bw.write(0, 1) // no meta prefix codes
+	var codes [4]huffmanCode
+	for i := range codes {
+		codes[i] = writePrefixCode(&bw, hist[i])
+	}
+	writePrefixCode(&bw, make([]int, 40)) // distance, unused
+	for _, p := range pix {
+		for i, v := range p {
+			codes[i].write(&bw, int(v))
+		}
+	}
+	bw.flush()
+
+	data := bw.buf
+	pad := len(data) & 1
+	hdr := make([]byte, 20)
+	copy(hdr, "RIFF")
+	binary.LittleEndian.PutUint32(hdr[4:], uint32(12+len(data)+pad))
+	copy(hdr[8:], "WEBPVP8L")
+	binary.LittleEndian.PutUint32(hdr[16:], uint32(len(data)))
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	if pad != 0 {
+		data = append(data, 0)
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// bitWriter packs values least significant bit first, as VP8L reads them.
+type bitWriter struct {
+	buf  []byte
+	acc  uint64
+	nacc uint
+}
+
+func (bw *bitWriter) write(v uint32, n uint) {
+	bw.acc |= uint64(v) << bw.nacc
+	bw.nacc += n
+	for bw.nacc >= 8 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc >>= 8
+		bw.nacc -= 8
+	}
+}
+
+func (bw *bitWriter) flush() {
+	if bw.nacc > 0 {
+		bw.buf = append(bw.buf, byte(bw.acc))
+		bw.acc, bw.nacc = 0, 0
+	}
+}
+
+// huffmanCode holds the bit reversed code and its length per symbol. A
+// code with a single symbol has length zero.
+type huffmanCode struct {
+	codes   []uint32
+	lengths []uint8
+}
+
+func (c huffmanCode) write(bw *bitWriter, sym int) {
+	bw.write(c.codes[sym], uint(c.lengths[sym]))
+}
+
+// codeLengthOrder is the order code length code lengths are stored in.
+var codeLengthOrder = [19]int{17, 18, 0, 1, 2, 3, 4, 5, 16, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+
+// writePrefixCode writes a prefix code for the symbol frequencies freq
+// and returns it.
+func writePrefixCode(bw *bitWriter, freq []int) huffmanCode {
+	var used []int
+	for s, f := range freq {
+		if f > 0 {
+			used = append(used, s)
+		}
+	}
+	if len(used) <= 1 && (len(used) == 0 || used[0] < 256) {
+		// Simple code with one symbol, coded in zero bits.
+		sym := 0
+		if len(used) == 1 {
+			sym = used[0]
+		}
+		bw.write(1, 1)
+		bw.write(0, 1) // one symbol
+		if sym < 2 {
+			bw.write(0, 1)
+			bw.write(uint32(sym), 1)
+		} else {
+			bw.write(1, 1)
+			bw.write(uint32(sym), 8)
+		}
+		return huffmanCode{codes: make([]uint32, len(freq)), lengths: make([]uint8, len(freq))}
+	}
+
+	lengths := huffmanLengths(freq, 15)
+	clFreq := make([]int, 19)
+	for _, l := range lengths {
+		clFreq[l]++
+	}
+	clLengths := huffmanLengths(clFreq, 7)
+	nCodes := 4
+	for i, s := range codeLengthOrder {
+		if clLengths[s] != 0 && i+1 > nCodes {
+			nCodes = i + 1
+		}
+	}
+	bw.write(0, 1) // normal code
+	bw.write(uint32(nCodes-4), 4)
+	for _, s := range codeLengthOrder[:nCodes] {
+		bw.write(uint32(clLengths[s]), 3)
+	}
+	bw.write(0, 1) // lengths for the whole alphabet follow
+	cl := canonicalCode(clLengths)
+	for _, l := range lengths {
+		cl.write(bw, int(l))
+	}
+	return canonicalCode(lengths)
+}
+
+// huffmanLengths returns code lengths of at most maxLen bits for the
+// symbol frequencies freq. A single used symbol gets length 1.
+func huffmanLengths(freq []int, maxLen int) []uint8 {
+	f := append([]int(nil), freq...)
+	for {
+		lengths, ok := buildLengths(f, maxLen)
+		if ok {
+			return lengths
+		}
+		// Flatten the distribution until the code fits.
+		for i, v := range f {
+			if v > 0 {
+				f[i] = v>>1 | 1
+			}
+		}
+	}
+}
+
+func buildLengths(freq []int, maxLen int) ([]uint8, bool) {
+	type node struct {
+		freq   int
+		parent int
+	}
+	var nodes []node
+	var active []int
+	var syms []int
+	for s, f := range freq {
+		if f > 0 {
+			nodes = append(nodes, node{f, -1})
+			active = append(active, len(nodes)-1)
+			syms = append(syms, s)
+		}
+	}
+	lengths := make([]uint8, len(freq))
+	if len(syms) == 1 {
+		lengths[syms[0]] = 1
+		return lengths, true
+	}
+	for len(active) > 1 {
+		// Pick the two least frequent nodes.
+		var pick [2]int
+		for k := range pick {
+			min := 0
+			for i := range active {
+				if nodes[active[i]].freq < nodes[active[min]].freq {
+					min = i
+				}
+			}
+			pick[k] = active[min]
+			active = append(active[:min], active[min+1:]...)
+		}
+		nodes = append(nodes, node{nodes[pick[0]].freq + nodes[pick[1]].freq, -1})
+		nodes[pick[0]].parent = len(nodes) - 1
+		nodes[pick[1]].parent = len(nodes) - 1
+		active = append(active, len(nodes)-1)
+	}
+	for i, s := range syms {
+		depth := 0
+		for p := nodes[i].parent; p != -1; p = nodes[p].parent {
+			depth++
+		}
+		if depth > maxLen {
+			return nil, false
+		}
+		lengths[s] = uint8(depth)
+	}
+	return lengths, true
+}
+
+// canonicalCode assigns canonical codes to lengths, bit reversed for
+// bitWriter.
+func canonicalCode(lengths []uint8) huffmanCode {
+	c := huffmanCode{codes: make([]uint32, len(lengths)), lengths: make([]uint8, len(lengths))}
+	var count [16]int
+	used := 0
+	for _, l := range lengths {
+		if l > 0 {
+			count[l]++
+			used++
+		}
+	}
+	if used == 1 {
+		// The decoder reads no bits for a single symbol.
+		return c
+	}
+	var next [16]uint32
+	code := uint32(0)
+	for l := 1; l < 16; l++ {
+		code = (code + uint32(count[l-1])) << 1
+		next[l] = code
+	}
+	for s, l := range lengths {
+		if l == 0 {
+			continue
+		}
+		v := next[l]
+		next[l]++
+		var rev uint32
+		for i := uint8(0); i < l; i++ {
+			rev = rev<<1 | v>>i&1
+		}
+		c.codes[s], c.lengths[s] = rev, l
+	}
+	return c
+}
@@ -0,0 +1,191 @@
+package gemini
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+)
+
+// Writer is a ResponseWriter that can also be used as an io.Writer of the
+// response body, for example as the destination of io.Copy. The server's
+// ResponseWriter implements it; middleware wrapping a ResponseWriter
+// should implement it as well.
+type Writer interface {
+	ResponseWriter
+	io.Writer
+}
+
+// Flusher is implemented by ResponseWriters that can send buffered body
+// data to the client before the handler returns.
+type Flusher interface {
+	Flush() error
+}
+
+// ResponseWriterUnwrapper is implemented by middleware ResponseWriters to
+// expose the writer they wrap, so optional interfaces of the underlying
+// writer can be reached.
+type ResponseWriterUnwrapper interface {
+	Unwrap() ResponseWriter
+}
+
+// ResponseInfo is implemented by ResponseWriters reporting what has been
+// sent, for access logging and metrics middleware.
+type ResponseInfo interface {
+	// Status returns the status code written, or 0 if none yet.
+	Status() StatusCode
+	// Meta returns the meta sent with the status code.
+	Meta() string
+	// BytesWritten returns the number of body bytes sent.
+	BytesWritten() int64
+}
+
+// ErrorRecorder is implemented by ResponseWriters that keep the error
+// that ended a response early, such as the server's ResponseWriter, which
+// reports it as AccessLogEntry.Err.
+type ErrorRecorder interface {
+	RecordError(err error)
+}
+
+// RecordError records err on w, or the first writer it wraps implementing
+// ErrorRecorder. Handlers call it when they give up on a response body,
+// typically with the *PartialWriteError of CopyBody. It is a no-op for
+// writers that do not record errors.
+func RecordError(w ResponseWriter, err error) {
+	for w != nil {
+		if e, ok := w.(ErrorRecorder); ok {
+			e.RecordError(err)
+			return
+		}
+		u, ok := w.(ResponseWriterUnwrapper)
+		if !ok {
+			return
+		}
+		w = u.Unwrap()
+	}
+}
+
+// StatusRecorder wraps a ResponseWriter and implements ResponseInfo for
+// writers that do not.
+type StatusRecorder struct {
+	ResponseWriter
+	status  StatusCode
+	meta    string
+	written int64
+	err     error
+}
+
+// NewStatusRecorder wraps w.
+func NewStatusRecorder(w ResponseWriter) *StatusRecorder {
+	return &StatusRecorder{ResponseWriter: w}
+}
+
+// WriteStatusMsg writes and records the status line.
+func (s *StatusRecorder) WriteStatusMsg(status StatusCode, msg string) error {
+	err := s.ResponseWriter.WriteStatusMsg(status, msg)
+	if err == nil {
+		s.status, s.meta = status, msg
+	}
+	return err
+}
+
+// WriteBody writes and counts body bytes.
+func (s *StatusRecorder) WriteBody(b []byte) (int, error) {
+	n, err := s.ResponseWriter.WriteBody(b)
+	s.written += int64(n)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// Write implements io.Writer.
+func (s *StatusRecorder) Write(b []byte) (int, error) {
+	return s.WriteBody(b)
+}
+
+// Unwrap returns the wrapped ResponseWriter.
+func (s *StatusRecorder) Unwrap() ResponseWriter {
+	return s.ResponseWriter
+}
+
+// Status implements ResponseInfo.
+func (s *StatusRecorder) Status() StatusCode { return s.status }
+
+// Meta implements ResponseInfo.
+func (s *StatusRecorder) Meta() string { return s.meta }
+
+// BytesWritten implements ResponseInfo.
+func (s *StatusRecorder) BytesWritten() int64 { return s.written }
+
+// Err returns the first error writing the body or recorded with
+// RecordError.
+func (s *StatusRecorder) Err() error { return s.err }
+
+// RecordError implements ErrorRecorder. It keeps the first error and
+// passes err on to the wrapped writer.
+func (s *StatusRecorder) RecordError(err error) {
+	if s.err == nil {
+		s.err = err
+	}
+	RecordError(s.ResponseWriter, err)
+}
+
+// ErrHijacked is returned by ResponseWriter methods after the connection has
+// been hijacked.
+var ErrHijacked = errors.New("gemini: connection has been hijacked")
+
+// Hijacker is implemented by the server's ResponseWriter to let a handler
+// take over the connection, for streaming experiments, tunnels or custom
+// protocols. After a successful Hijack the server neither writes to nor
+// closes the connection; the handler owns it, including closing it.
+type Hijacker interface {
+	Hijack() (*tls.Conn, error)
+}
+
+// Hijack takes over the connection of w, or of the first writer it wraps
+// implementing Hijacker.
+func Hijack(w ResponseWriter) (*tls.Conn, error) {
+	for w != nil {
+		if h, ok := w.(Hijacker); ok {
+			return h.Hijack()
+		}
+		u, ok := w.(ResponseWriterUnwrapper)
+		if !ok {
+			break
+		}
+		w = u.Unwrap()
+	}
+	return nil, errors.New("gemini: ResponseWriter does not support hijacking")
+}
+
+// AsWriter returns w as an io.Writer writing the response body.
+func AsWriter(w ResponseWriter) io.Writer {
+	if ww, ok := w.(io.Writer); ok {
+		return ww
+	}
+	return bodyWriter{w}
+}
+
+type bodyWriter struct {
+	ResponseWriter
+}
+
+func (b bodyWriter) Write(p []byte) (int, error) {
+	return b.WriteBody(p)
+}
+
+// Flush flushes w, or the first writer it wraps implementing Flusher.
+// It is a no-op for writers that do not buffer.
+func Flush(w ResponseWriter) error {
+	for w != nil {
+		if f, ok := w.(Flusher); ok {
+			return f.Flush()
+		}
+		u, ok := w.(ResponseWriterUnwrapper)
+		if !ok {
+			return nil
+		}
+		w = u.Unwrap()
+	}
+	return nil
+}